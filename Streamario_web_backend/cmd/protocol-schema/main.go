@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"streamerrio-backend/pkg/protocol"
+)
+
+// Unity 向けに WebSocket プロトコルの JSON Schema を出力する (-o 未指定なら標準出力)
+func main() {
+	out := flag.String("o", "", "Output file (default: stdout)")
+	flag.Parse()
+
+	schema, err := protocol.JSONSchema()
+	if err != nil {
+		log.Fatalf("generate schema: %v", err)
+	}
+	schema = append(schema, '\n')
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*out, schema, 0o644); err != nil {
+		log.Fatalf("write schema: %v", err)
+	}
+}
@@ -82,26 +82,75 @@ func main() {
 	redisCounter := counter.NewRedisCounter(rdb, appLogger.With(slog.String("component", "redis_counter")))
 
 	// 6. Pub/Sub 初期化 (REST API → WebSocket サーバーへのイベント配信)
-	ps := pubsub.NewRedisPubSub(rdb, appLogger.With(slog.String("component", "pubsub")))
+	ps, err := pubsub.NewRedisBackend(cfg.PubSubBackend, rdb, pubsub.StreamOptions{
+		MaxLen: int64(cfg.PubSubStreamLen),
+		TTL:    cfg.PubSubStreamTTL,
+	}, appLogger.With(slog.String("component", "pubsub")))
+	if err != nil {
+		log.Error("failed to init pubsub", slog.String("backend", cfg.PubSubBackend), slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	// 7. リポジトリ (永続層) 準備
 	repoLogger := appLogger.With(slog.String("component", "repository"))
 	eventRepo := repository.NewEventRepository(db, repoLogger.With(slog.String("repository", "event")))
 	roomRepo := repository.NewRoomRepository(db, repoLogger.With(slog.String("repository", "room")))
 	viewerRepo := repository.NewViewerRepository(db, repoLogger.With(slog.String("repository", "viewer")))
+	catalogRepo := repository.NewEventCatalogRepository(db, repoLogger.With(slog.String("repository", "event_catalog")))
+	rateLimitLogRepo := repository.NewRateLimitLogRepository(db, repoLogger.With(slog.String("repository", "rate_limit_log")))
+	triggerRepo := repository.NewTriggerRepository(db, repoLogger.With(slog.String("repository", "trigger")))
+	gameSessionRepo := repository.NewGameSessionRepository(db, repoLogger.With(slog.String("repository", "game_session")))
+	leaseRepo := repository.NewLeaseRepository(db, repoLogger.With(slog.String("repository", "lease")))
+	streamerRepo := repository.NewStreamerRepository(db, repoLogger.With(slog.String("repository", "streamer")))
 
 	// リポジトリのリソース解放（Prepared Statement）
 	defer eventRepo.Close()
 	defer roomRepo.Close()
 	defer viewerRepo.Close()
+	defer catalogRepo.Close()
+	defer rateLimitLogRepo.Close()
+	defer triggerRepo.Close()
+	defer gameSessionRepo.Close()
+	defer leaseRepo.Close()
+	defer streamerRepo.Close()
 
 	// 8. サービス層生成
+	eventCatalog, err := service.LoadEventCatalog(cfg.EventCatalogPath, catalogRepo, appLogger.With(slog.String("component", "event_catalog")))
+	if err != nil {
+		log.Error("failed to load event catalog", slog.Any("error", err))
+		os.Exit(1)
+	}
 	roomService := service.NewRoomService(roomRepo, cfg)
 	eventLogger := appLogger.With(slog.String("component", "event_service"))
 	sessionLogger := appLogger.With(slog.String("component", "session_service"))
-	eventService := service.NewEventService(redisCounter, eventRepo, ps, eventLogger)
-	sessionService := service.NewGameSessionService(roomService, eventRepo, viewerRepo, redisCounter, nil, sessionLogger)
+	eventWriter := service.NewEventWriter(eventRepo, redisCounter, service.EventWriterConfig{
+		QueueSize:     cfg.EventWriteQueueSize,
+		BatchSize:     cfg.EventWriteBatchSize,
+		FlushInterval: cfg.EventWriteFlushInterval,
+		MaxRetries:    cfg.EventWriteMaxRetries,
+	}, appLogger.With(slog.String("component", "event_writer")))
+	eventService := service.NewEventService(redisCounter, eventWriter, ps, eventCatalog, eventLogger)
+	eventService.SetTriggerRepository(triggerRepo)
+	sessionService := service.NewGameSessionService(roomService, eventRepo, viewerRepo, redisCounter, eventCatalog, nil, sessionLogger)
+	sessionService.SetTriggerRepository(triggerRepo)
+	sessionService.SetGameSessionRepository(gameSessionRepo)
+	sessionService.SetEventService(eventService)
+	sessionService.SetEventFlusher(eventWriter, cfg.EventFlushTimeout)
+
+	// ゲーム終了時 (Unity WebSocket サーバー) からのフラッシュ要求を購読
+	flushCtx, stopFlushListener := context.WithCancel(context.Background())
+	defer stopFlushListener()
+	go func() {
+		if err := eventWriter.ListenFlushRequests(flushCtx, ps); err != nil && flushCtx.Err() == nil {
+			log.Error("event flush subscription terminated", slog.Any("error", err))
+		}
+	}()
 	viewerService := service.NewViewerService(viewerRepo)
+	streamerService := service.NewStreamerService(streamerRepo, cfg.StreamerSignupToken)
+	rateLimiter := service.NewRateLimiter(redisCounter, rateLimitLogRepo, eventCatalog, service.RateLimitConfig{
+		Requests: counter.TokenBucket{Rate: cfg.RateLimitRequestsPerSec, Burst: int64(cfg.RateLimitRequestBurst)},
+		Pushes:   counter.TokenBucket{Rate: cfg.RateLimitPushesPerSec, Burst: int64(cfg.RateLimitPushBurst)},
+	}, appLogger.With(slog.String("component", "rate_limiter")))
 	logTokenService, err := service.NewLogTokenService(
 		cfg.LogRelayTokenSecret,
 		cfg.LogRelayTokenTTL,
@@ -112,11 +161,44 @@ func main() {
 		log.Error("failed to init log token service", slog.Any("error", err))
 		os.Exit(1)
 	}
-	apiHandler := handler.NewAPIHandler(roomService, eventService, sessionService, viewerService, logTokenService).WithLogger(appLogger.With(slog.String("component", "handler")))
+	// 視聴者向けライブ統計ストリーム (購読者のいるルームのチャネルを購読し、ルームごとに間引いて中継)
+	roomStream := service.NewRoomStreamHub(ps, roomService, eventService, cfg.RoomStreamInterval, appLogger.With(slog.String("component", "room_stream")))
+	streamCtx, stopRoomStream := context.WithCancel(context.Background())
+	defer stopRoomStream()
+	go func() {
+		if err := roomStream.Run(streamCtx); err != nil && streamCtx.Err() == nil {
+			log.Error("room stream subscription terminated", slog.Any("error", err))
+		}
+	}()
+	// ルームの期限切れ / 無操作 / Unity 不在の判定と終了ルームの Redis 掃除 (リースで1インスタンスのみ実行)
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	janitorDone := make(chan struct{})
+	if cfg.RoomJanitorInterval > 0 {
+		janitor := service.NewRoomJanitor(roomService, leaseRepo, redisCounter, eventCatalog, cfg.RoomJanitorInterval, cfg.RoomJanitorBatchSize, appLogger.With(slog.String("component", "room_janitor")))
+		go func() {
+			defer close(janitorDone)
+			janitor.Run(janitorCtx)
+		}()
+	} else {
+		close(janitorDone)
+	}
+	apiHandler := handler.NewAPIHandler(roomService, eventService, sessionService, viewerService, logTokenService).
+		WithLogger(appLogger.With(slog.String("component", "handler"))).
+		WithRateLimiter(rateLimiter).
+		WithRoomStream(roomStream).
+		WithStreamerService(streamerService)
 
 	// 10. Echo フレームワーク初期化 & ミドルウェア
 	e := echo.New()
 	e.Logger.SetLevel(elog.DEBUG)
+	// c.RealIP() (レート制限のキー / ログ) は信頼するプロキシ経由の X-Forwarded-For のみ採用する
+	ipExtractor, err := httpmiddleware.IPExtractor(cfg.TrustedProxies)
+	if err != nil {
+		log.Error("invalid trusted proxies", slog.Any("trusted_proxies", cfg.TrustedProxies), slog.Any("error", err))
+		os.Exit(1)
+	}
+	e.IPExtractor = ipExtractor
 	e.Use(httpmiddleware.StructuredLogger(appLogger.With(slog.String("component", "http"))))
 	e.Use(middleware.Recover()) // パニック回復
 
@@ -132,23 +214,41 @@ func main() {
 	}
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     allowOrigins,
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodOptions},
-		AllowHeaders:     []string{"ngrok-skip-browser-warning", echo.HeaderContentType},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowHeaders:     []string{"ngrok-skip-browser-warning", echo.HeaderContentType, echo.HeaderAuthorization},
 		AllowCredentials: allowCredentials,
 	}))
 
 	// 12. ルーティング定義
-	e.GET("/", healthCheck)
+	e.GET("/", healthCheck(eventWriter))
 	e.GET("/get_viewer_id", apiHandler.GetOrCreateViewerID)
 	// REST API
 	api := e.Group("/api")
+	api.GET("/event-types", apiHandler.ListEventTypes)
 	api.GET("/rooms/:id", apiHandler.GetRoom)
 	api.POST("/rooms/:id/join", apiHandler.JoinRoom)
 	api.POST("/rooms/:id/events", apiHandler.SendEvent)
 	api.GET("/rooms/:id/stats", apiHandler.GetRoomStats)
+	api.GET("/rooms/:id/triggers", apiHandler.ListRoomTriggers)
+	api.GET("/rooms/:id/history", apiHandler.ListRoomStateHistory)
+	api.GET("/rooms/:id/stream", apiHandler.StreamRoom)
+	api.PUT("/rooms/:id/settings", apiHandler.UpdateRoomSettings)
 	api.GET("/rooms/:id/results", apiHandler.GetRoomResult)
+	api.GET("/rooms/:id/rounds", apiHandler.ListRoomRounds)
+	api.GET("/rooms/:id/rounds/:round/results", apiHandler.GetRoundResult)
 	api.POST("/viewers/set_name", apiHandler.SetViewerName)
 	api.POST("/log-token", apiHandler.IssueLogToken)
+	// 配信者 (Authorization: Bearer <API キー>)
+	api.POST("/streamers", apiHandler.RegisterStreamer)
+	streamer := api.Group("/streamer", apiHandler.RequireStreamer)
+	streamer.GET("", apiHandler.GetStreamer)
+	streamer.GET("/keys", apiHandler.ListStreamerAPIKeys)
+	streamer.POST("/keys", apiHandler.CreateStreamerAPIKey)
+	streamer.DELETE("/keys/:key_id", apiHandler.RevokeStreamerAPIKey)
+	streamer.GET("/rooms", apiHandler.ListStreamerRooms)
+	streamer.GET("/rooms/:id", apiHandler.GetStreamerRoom)
+	streamer.GET("/rooms/:id/history", apiHandler.ListStreamerRoomHistory)
+	streamer.PUT("/rooms/:id/settings", apiHandler.UpdateStreamerRoomSettings)
 
 	// 13. サーバ起動
 	log.Info("starting http server", slog.String("port", cfg.Port))
@@ -164,21 +264,34 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	log.Info("shutting down http server")
+	// SSE 接続は Shutdown では閉じられないため先に購読を止める
+	stopRoomStream()
+	// リースを手放してから DB を閉じる
+	stopJanitor()
+	<-janitorDone
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
 		log.Error("server shutdown error", slog.Any("error", err))
 	}
+	// 受付停止後、書き込みキューの残りを DB へ書き切る
+	if err := eventWriter.Close(ctx); err != nil {
+		log.Error("event writer drain error", slog.Any("error", err))
+	}
 	// ここで main が return し、上部の defer Close() が必ず実行される
 }
 
-func healthCheck(c echo.Context) error {
-	return c.JSON(http.StatusOK, map[string]string{
-		"status":  "ok",
-		"service": "streamerrio",
-		"version": "1.0.0",
-	})
+// healthCheck: 稼働確認 (イベント書き込みキューの滞留数も返す)
+func healthCheck(writer *service.EventWriter) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"status":            "ok",
+			"service":           "streamerrio",
+			"version":           "1.0.0",
+			"event_queue_depth": writer.Depth(),
+		})
+	}
 }
 
 // extractConnInfo: DSN/URL から host/port/dbname/sslmode を抽出（ログ用途）
@@ -14,10 +14,12 @@ import (
 
 	"streamerrio-backend/internal/config"
 	"streamerrio-backend/internal/handler"
+	httpmiddleware "streamerrio-backend/internal/middleware"
 	"streamerrio-backend/internal/repository"
 	"streamerrio-backend/internal/service"
 	"streamerrio-backend/pkg/counter"
 	"streamerrio-backend/pkg/logger"
+	"streamerrio-backend/pkg/protocol"
 	"streamerrio-backend/pkg/pubsub"
 
 	// PostgreSQLドライバー
@@ -80,26 +82,80 @@ func main() {
 	redisCounter := counter.NewRedisCounter(rdb, appLogger.With(slog.String("component", "redis_counter")))
 
 	// 6. Pub/Sub 初期化
-	ps := pubsub.NewRedisPubSub(rdb, appLogger.With(slog.String("component", "pubsub")))
+	ps, err := pubsub.NewRedisBackend(cfg.PubSubBackend, rdb, pubsub.StreamOptions{
+		MaxLen: int64(cfg.PubSubStreamLen),
+		TTL:    cfg.PubSubStreamTTL,
+	}, appLogger.With(slog.String("component", "pubsub")))
+	if err != nil {
+		log.Error("failed to init pubsub", slog.String("backend", cfg.PubSubBackend), slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	// 7. リポジトリ
 	repoLogger := appLogger.With(slog.String("component", "repository"))
 	eventRepo := repository.NewEventRepository(db, repoLogger.With(slog.String("repository", "event")))
 	roomRepo := repository.NewRoomRepository(db, repoLogger.With(slog.String("repository", "room")))
 	viewerRepo := repository.NewViewerRepository(db, repoLogger.With(slog.String("repository", "viewer")))
+	catalogRepo := repository.NewEventCatalogRepository(db, repoLogger.With(slog.String("repository", "event_catalog")))
+	triggerRepo := repository.NewTriggerRepository(db, repoLogger.With(slog.String("repository", "trigger")))
+	gameSessionRepo := repository.NewGameSessionRepository(db, repoLogger.With(slog.String("repository", "game_session")))
+	relayAuditRepo := repository.NewRelayAuditRepository(db, repoLogger.With(slog.String("repository", "relay_audit")))
+	streamerRepo := repository.NewStreamerRepository(db, repoLogger.With(slog.String("repository", "streamer")))
 
 	defer eventRepo.Close()
 	defer roomRepo.Close()
 	defer viewerRepo.Close()
+	defer catalogRepo.Close()
+	defer triggerRepo.Close()
+	defer gameSessionRepo.Close()
+	defer relayAuditRepo.Close()
+	defer streamerRepo.Close()
 
 	// 8. サービス層
+	if _, err := service.NewThresholdStrategy(cfg.DefaultThresholdStrategy, nil); err != nil {
+		log.Error("invalid default threshold strategy", slog.String("strategy", cfg.DefaultThresholdStrategy), slog.Any("error", err))
+		os.Exit(1)
+	}
+	eventCatalog, err := service.LoadEventCatalog(cfg.EventCatalogPath, catalogRepo, appLogger.With(slog.String("component", "event_catalog")))
+	if err != nil {
+		log.Error("failed to load event catalog", slog.Any("error", err))
+		os.Exit(1)
+	}
 	roomService := service.NewRoomService(roomRepo, cfg)
 	wsHandlerLogger := appLogger.With(slog.String("component", "websocket_handler"))
 	wsHandler := handler.NewWebSocketHandler(ps, wsHandlerLogger)
 	wsHandler.SetRoomService(roomService)
+	wsHandler.SetTriggerRepository(triggerRepo)
+	wsHandler.SetReplayLimit(cfg.UnityReplayLimit)
+	wsHandler.SetAckPolicy(cfg.UnityAckTimeout, cfg.UnityMaxResends)
+	wsHandler.SetHeartbeat(cfg.UnityPingInterval, cfg.UnityIdleTimeout, cfg.UnityWriteTimeout)
+	var roomTokens *service.RoomTokenService
+	if cfg.UnityRoomTokenSecret == "" {
+		// 既定値を持たせると誰でもトークンを偽造できるため、未設定時は起動ごとのランダム鍵にする
+		log.Warn("UNITY_ROOM_TOKEN_SECRET is not set; using a random per-process secret (room tokens become invalid on restart and are not shared between instances)")
+		roomTokens, err = service.NewRandomRoomTokenService()
+	} else {
+		roomTokens, err = service.NewRoomTokenService(cfg.UnityRoomTokenSecret)
+	}
+	if err != nil {
+		log.Error("failed to init room token service", slog.Any("error", err))
+		os.Exit(1)
+	}
+	wsHandler.SetRoomTokenService(roomTokens)
+	wsHandler.SetAllowedOrigins(cfg.UnityAllowedOrigins)
+	wsHandler.SetStreamerAuth(service.NewStreamerService(streamerRepo, ""), cfg.UnityRequireAPIKey)
+	wsHandler.SetRelay(cfg.UnityRelayAdminToken, service.NewRelayMessageRegistry(eventCatalog), relayAuditRepo)
 	sender := webSocketAdapter{ws: wsHandler}
 	sessionLogger := appLogger.With(slog.String("component", "session_service"))
-	sessionService := service.NewGameSessionService(roomService, eventRepo, viewerRepo, redisCounter, sender, sessionLogger)
+	sessionService := service.NewGameSessionService(roomService, eventRepo, viewerRepo, redisCounter, eventCatalog, sender, sessionLogger)
+	// ゲーム終了時は API サーバーの書き込みキューをフラッシュさせてから集計する
+	sessionService.SetEventFlusher(service.NewRemoteEventFlusher(ps, redisCounter, appLogger.With(slog.String("component", "event_flusher"))), cfg.EventFlushTimeout)
+	sessionService.SetTriggerRepository(triggerRepo)
+	sessionService.SetGameSessionRepository(gameSessionRepo)
+	// 再開時に一時停止中の保留押下を反映する (押下の記録は API サーバーが行うため書き込みキューは持たない)
+	pausedEvents := service.NewEventService(redisCounter, nil, ps, eventCatalog, appLogger.With(slog.String("component", "event_service")))
+	pausedEvents.SetTriggerRepository(triggerRepo)
+	sessionService.SetEventService(pausedEvents)
 	wsHandler.SetGameSessionService(sessionService)
 
 	// 9. シグナルハンドリングと Pub/Sub 購読開始
@@ -111,6 +167,9 @@ func main() {
 			log.Error("pubsub subscription terminated", slog.Any("error", err))
 		}
 	}()
+	// 再接続猶予を過ぎた切断中ルームを終了させる
+	go wsHandler.ExpireDisconnectedRooms(ctx)
+	go wsHandler.ReportUnityPresence(ctx)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -119,12 +178,21 @@ func main() {
 	// 10. Echo 初期化
 	e := echo.New()
 	e.Logger.SetLevel(elog.DEBUG)
+	// c.RealIP() (relay の監査ログ) は信頼するプロキシ経由の X-Forwarded-For のみ採用する
+	ipExtractor, err := httpmiddleware.IPExtractor(cfg.TrustedProxies)
+	if err != nil {
+		log.Error("invalid trusted proxies", slog.Any("trusted_proxies", cfg.TrustedProxies), slog.Any("error", err))
+		os.Exit(1)
+	}
+	e.IPExtractor = ipExtractor
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
 	e.GET("/", healthCheck)
 	e.GET("/ws-unity", wsHandler.HandleUnityConnection)
 	e.GET("/clients", wsHandler.ListClients)
+	e.POST("/relay", wsHandler.RelayActionToUnity)
+	e.GET("/relay/audit/:room_id", wsHandler.ListRelayAudit)
 
 	log.Info("starting unity websocket server", slog.String("port", cfg.UnityWSPort))
 
@@ -160,8 +228,8 @@ func healthCheck(c echo.Context) error {
 // webSocketAdapter: WebSocketHandler をサービス側インタフェースに適合させる薄いアダプタ
 type webSocketAdapter struct{ ws *handler.WebSocketHandler }
 
-func (a webSocketAdapter) SendEventToUnity(roomID string, payload map[string]interface{}) error {
-	return a.ws.SendEventToUnity(roomID, payload)
+func (a webSocketAdapter) SendToUnity(roomID string, msg protocol.Message) error {
+	return a.ws.SendToUnity(roomID, msg)
 }
 
 func extractConnInfo(dsn string) (host, port, dbname, sslmode string) {
@@ -0,0 +1,52 @@
+-- 005_event_catalog.sql : イベント種別をデータ駆動にするためのカタログと汎用カウント列
+
+-- イベントカタログ (ボタン定義)
+-- 行を追加するだけで新しいイベント種別を増やせる（コード変更・マイグレーション不要）
+CREATE TABLE IF NOT EXISTS event_catalog (
+    event_type VARCHAR(64) PRIMARY KEY,
+    category VARCHAR(16) NOT NULL DEFAULT 'other' CHECK (category IN ('skill', 'enemy', 'other')),
+    display_name TEXT NOT NULL DEFAULT '',
+    sort_order INT NOT NULL DEFAULT 0,
+    base_threshold INT NOT NULL CHECK (base_threshold > 0),
+    min_threshold INT NOT NULL CHECK (min_threshold > 0),
+    max_threshold INT NOT NULL CHECK (max_threshold >= min_threshold),
+    level_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1.0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- 既存の6ボタンを初期データとして投入（既にあれば何もしない）
+INSERT INTO event_catalog (event_type, category, display_name, sort_order, base_threshold, min_threshold, max_threshold, level_multiplier)
+VALUES
+    ('skill1', 'skill', 'スキル1', 10, 5, 3, 50, 1.3),
+    ('skill2', 'skill', 'スキル2', 20, 8, 4, 60, 1.3),
+    ('skill3', 'skill', 'スキル3', 30, 8, 8, 100, 1.4),
+    ('enemy1', 'enemy', '敵1', 40, 5, 4, 45, 1.3),
+    ('enemy2', 'enemy', '敵2', 50, 10, 5, 55, 1.4),
+    ('enemy3', 'enemy', '敵3', 60, 17, 6, 80, 1.5)
+ON CONFLICT (event_type) DO NOTHING;
+
+-- イベント種別ごとの押下数を JSONB で保持する（例: {"skill1": 3, "enemy2": 1}）
+ALTER TABLE events ADD COLUMN IF NOT EXISTS counts JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- 旧スキーマ（種別ごとの *_count 列）が存在する環境ではデータを移し、以降の INSERT で列を省略できるようにする
+DO $$
+DECLARE
+    col TEXT;
+BEGIN
+    FOREACH col IN ARRAY ARRAY['skill1', 'skill2', 'skill3', 'enemy1', 'enemy2', 'enemy3'] LOOP
+        IF EXISTS (
+            SELECT 1
+            FROM information_schema.columns
+            WHERE table_name = 'events'
+              AND column_name = col || '_count'
+        ) THEN
+            EXECUTE format('ALTER TABLE events ALTER COLUMN %I SET DEFAULT 0', col || '_count');
+            EXECUTE format(
+                'UPDATE events SET counts = counts || jsonb_build_object(%L, %I) WHERE COALESCE(%I, 0) > 0 AND NOT counts ? %L',
+                col, col || '_count', col || '_count', col
+            );
+        END IF;
+    END LOOP;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_events_room_viewer ON events (room_id, viewer_id);
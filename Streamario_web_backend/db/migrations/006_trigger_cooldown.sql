@@ -0,0 +1,14 @@
+-- 006_trigger_cooldown.sql : イベント種別ごとの発動クールダウン
+
+-- 発動後、同じイベント種別が再発動できるまでの時間 (ミリ秒, 0 はクールダウンなし)
+-- クールダウン中の押下はカウントされ、クールダウン明けの次の押下で発動する
+ALTER TABLE event_catalog ADD COLUMN IF NOT EXISTS cooldown_ms INT NOT NULL DEFAULT 0;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'event_catalog_cooldown_ms_check'
+    ) THEN
+        ALTER TABLE event_catalog ADD CONSTRAINT event_catalog_cooldown_ms_check CHECK (cooldown_ms >= 0);
+    END IF;
+END $$;
@@ -0,0 +1,15 @@
+-- 007_rate_limited_pushes.sql : レート制限で削られた押下の記録 (後から確認するため)
+
+CREATE TABLE IF NOT EXISTS rate_limited_pushes (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(36) NOT NULL,
+    viewer_id VARCHAR(36),
+    client_key TEXT NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    requested BIGINT NOT NULL,
+    accepted BIGINT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limited_pushes_room_created ON rate_limited_pushes (room_id, created_at);
@@ -0,0 +1,64 @@
+-- 008_normalize_events.sql : イベント保存スキーマの整理 (counts JSONB に一本化し、旧列 / 旧 ENUM を削除)
+
+-- 1. 旧スキーマの名残を counts に寄せる
+DO $$
+DECLARE
+    col TEXT;
+BEGIN
+    -- 種別ごとの *_count 列: 005 で移し漏れた分を反映してから削除
+    FOREACH col IN ARRAY ARRAY['skill1', 'skill2', 'skill3', 'enemy1', 'enemy2', 'enemy3'] LOOP
+        IF EXISTS (
+            SELECT 1
+            FROM information_schema.columns
+            WHERE table_name = 'events'
+              AND column_name = col || '_count'
+        ) THEN
+            EXECUTE format(
+                'UPDATE events SET counts = counts || jsonb_build_object(%L, %I) WHERE COALESCE(%I, 0) > 0 AND NOT counts ? %L',
+                col, col || '_count', col || '_count', col
+            );
+            EXECUTE format('ALTER TABLE events DROP COLUMN %I', col || '_count');
+        END IF;
+    END LOOP;
+
+    -- 1行1種別だった頃の events.event_type (event_type_enum): 1回押下として counts に移してから削除
+    IF EXISTS (
+        SELECT 1
+        FROM information_schema.columns
+        WHERE table_name = 'events'
+          AND column_name = 'event_type'
+    ) THEN
+        UPDATE events SET counts = jsonb_build_object(event_type::text, 1)
+        WHERE counts = '{}'::jsonb AND event_type IS NOT NULL;
+        ALTER TABLE events DROP COLUMN event_type;
+    END IF;
+END$$;
+
+-- 2. counts は {"<event_type>": <押下数>} のオブジェクトに限定する
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'events_counts_object_check'
+    ) THEN
+        ALTER TABLE events ADD CONSTRAINT events_counts_object_check CHECK (jsonb_typeof(counts) = 'object');
+    END IF;
+END$$;
+
+-- 3. game_events.event_type を ENUM からカタログと同じ文字列型へ変更し、使われなくなった ENUM を削除
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM information_schema.columns
+        WHERE table_name = 'game_events'
+          AND column_name = 'event_type'
+          AND udt_name = 'event_type_enum'
+    ) THEN
+        ALTER TABLE game_events ALTER COLUMN event_type TYPE VARCHAR(64) USING event_type::text;
+    END IF;
+END$$;
+
+DROP TYPE IF EXISTS event_type_enum;
+
+-- 集計クエリ (room_id で絞って counts を展開) 用
+CREATE INDEX IF NOT EXISTS idx_events_room ON events (room_id);
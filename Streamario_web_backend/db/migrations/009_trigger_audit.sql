@@ -0,0 +1,22 @@
+-- 009_trigger_audit.sql : 閾値到達 (game_event 発動) の監査ログ
+
+-- 001 で作成したまま未使用だった game_events を発動履歴として使う
+CREATE TABLE IF NOT EXISTS game_events (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    trigger_count INT NOT NULL,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (room_id) REFERENCES rooms(id)
+);
+
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS multiplier INT NOT NULL DEFAULT 1;      -- 1回の処理で発動した回数
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS level INT NOT NULL DEFAULT 1;           -- 最後に発動したレベル
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS viewer_count INT NOT NULL DEFAULT 0;    -- 発動時のアクティブ視聴者数
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS viewer_id VARCHAR(36);                  -- 閾値を超える押下をした視聴者
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS viewer_name TEXT;
+-- pending: 記録直後 / published: Pub/Sub 配信済み / publish_failed: 配信失敗 / delivered: Unity へ送信済み
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS delivery_status VARCHAR(16) NOT NULL DEFAULT 'pending';
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_game_events_room_sent ON game_events (room_id, sent_at);
@@ -0,0 +1,31 @@
+-- 010_room_reconnect.sql : Unity 切断後の再接続猶予
+
+-- room_status に in_game (コードでは使用済みだが未定義だった) と disconnected を追加
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_enum e
+        JOIN pg_type t ON e.enumtypid = t.oid
+        WHERE t.typname = 'room_status'
+          AND e.enumlabel = 'in_game'
+    ) THEN
+        ALTER TYPE room_status ADD VALUE 'in_game';
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_enum e
+        JOIN pg_type t ON e.enumtypid = t.oid
+        WHERE t.typname = 'room_status'
+          AND e.enumlabel = 'disconnected'
+    ) THEN
+        ALTER TYPE room_status ADD VALUE 'disconnected';
+    END IF;
+END$$;
+
+-- 切断した時刻 (猶予の起点) と、再接続時に戻す状態
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS disconnected_at TIMESTAMP;
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS resume_status room_status;
+
+-- 猶予切れのルームを探すためのインデックス
+CREATE INDEX IF NOT EXISTS idx_rooms_status_disconnected ON rooms(status, disconnected_at);
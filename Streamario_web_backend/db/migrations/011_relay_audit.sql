@@ -0,0 +1,15 @@
+-- 011_relay_audit.sql : 管理者 / 配信者が Unity へ直接送ったメッセージ (relay) の監査ログ
+
+CREATE TABLE IF NOT EXISTS relay_audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(36) NOT NULL,
+    message_type VARCHAR(64) NOT NULL,
+    actor VARCHAR(16) NOT NULL,           -- admin / room_owner
+    remote_addr TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL,          -- sent / rejected / failed
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_relay_audit_logs_room_created ON relay_audit_logs (room_id, created_at);
@@ -0,0 +1,53 @@
+-- 012_room_state_machine.sql : ルーム状態の遷移を明示化し、遷移履歴を記録する
+-- 状態: created → waiting → in_game ⇄ paused → ended (+ disconnected / expired)
+
+-- room_status に created / waiting / paused を追加
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_enum e
+        JOIN pg_type t ON e.enumtypid = t.oid
+        WHERE t.typname = 'room_status'
+          AND e.enumlabel = 'created'
+    ) THEN
+        ALTER TYPE room_status ADD VALUE 'created';
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_enum e
+        JOIN pg_type t ON e.enumtypid = t.oid
+        WHERE t.typname = 'room_status'
+          AND e.enumlabel = 'waiting'
+    ) THEN
+        ALTER TYPE room_status ADD VALUE 'waiting';
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_enum e
+        JOIN pg_type t ON e.enumtypid = t.oid
+        WHERE t.typname = 'room_status'
+          AND e.enumlabel = 'paused'
+    ) THEN
+        ALTER TYPE room_status ADD VALUE 'paused';
+    END IF;
+END$$;
+
+-- 旧状態の移行 (active = Unity 接続済みでゲーム開始前、inactive は未使用だが終了扱い)
+-- ENUM から値は削除できないため active / inactive は定義に残るが、以後は書き込まない
+UPDATE rooms SET status = 'waiting' WHERE status = 'active';
+UPDATE rooms SET resume_status = 'waiting' WHERE resume_status = 'active';
+UPDATE rooms SET status = 'ended', ended_at = COALESCE(ended_at, created_at) WHERE status = 'inactive';
+ALTER TABLE rooms ALTER COLUMN status SET DEFAULT 'created';
+
+-- 状態遷移の履歴
+CREATE TABLE IF NOT EXISTS room_state_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(36) NOT NULL,
+    from_state room_status,               -- 作成時は NULL
+    to_state room_status NOT NULL,
+    reason VARCHAR(64) NOT NULL,          -- game_start / game_end / unity_disconnected など
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_state_transitions_room_created ON room_state_transitions (room_id, created_at);
@@ -0,0 +1,33 @@
+-- 013_game_sessions.sql : 1ルームで複数ラウンドのゲームを行う (ラウンドごとに押下・発動・結果を分ける)
+
+-- ラウンド (Unity の game_start で開始、game_end で終了)
+CREATE TABLE IF NOT EXISTS game_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    room_id VARCHAR(36) NOT NULL,
+    round INT NOT NULL,                   -- ルーム内で 1 から連番
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP,                   -- 進行中は NULL
+    FOREIGN KEY (room_id) REFERENCES rooms(id),
+    UNIQUE (room_id, round)
+);
+
+-- ルームの現在 (または直近) のラウンド。0 はまだゲームをしていないルーム
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS current_round INT NOT NULL DEFAULT 0;
+
+-- 押下 / 発動履歴がどのラウンドのものか
+ALTER TABLE events ADD COLUMN IF NOT EXISTS round INT NOT NULL DEFAULT 1;
+ALTER TABLE game_events ADD COLUMN IF NOT EXISTS round INT NOT NULL DEFAULT 1;
+
+-- 既存のゲーム済みルームは1ラウンド目として移行する
+INSERT INTO game_sessions (room_id, round, started_at, ended_at)
+SELECT id, 1, created_at, ended_at
+FROM rooms
+WHERE status IN ('in_game', 'paused', 'ended') OR resume_status IN ('in_game', 'paused')
+ON CONFLICT (room_id, round) DO NOTHING;
+
+UPDATE rooms SET current_round = 1
+WHERE current_round = 0 AND id IN (SELECT room_id FROM game_sessions);
+
+-- 集計クエリ (room_id + round で絞る) 用
+CREATE INDEX IF NOT EXISTS idx_events_room_round ON events (room_id, round);
+CREATE INDEX IF NOT EXISTS idx_game_events_room_round ON game_events (room_id, round, sent_at);
@@ -0,0 +1,32 @@
+-- 014_room_janitor.sql : ルームの有効期限 / 無操作タイムアウトと、放置ルームを片付けるジャニター
+
+-- 最後に押下 / 状態遷移があった時刻 (無操作タイムアウトの起点)
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP;
+UPDATE rooms r SET last_activity_at = GREATEST(
+        r.created_at,
+        COALESCE((SELECT MAX(t.created_at) FROM room_state_transitions t WHERE t.room_id = r.id), r.created_at)
+    )
+WHERE r.last_activity_at IS NULL;
+ALTER TABLE rooms ALTER COLUMN last_activity_at SET DEFAULT CURRENT_TIMESTAMP;
+
+-- Unity 接続を持つ WebSocket サーバーが最後に接続を確認した時刻 (サーバーごと落ちたルームの検出用)
+-- 進行中のルームは移行時点で確認済みとして扱い、移行直後に切断扱いにしない
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS unity_seen_at TIMESTAMP;
+UPDATE rooms SET unity_seen_at = CURRENT_TIMESTAMP
+WHERE unity_seen_at IS NULL AND status IN ('waiting', 'in_game', 'paused');
+
+-- 終了後に Redis のカウンタ類を削除した時刻 (NULL の終了済みルームはジャニターが削除する)
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS counters_cleared_at TIMESTAMP;
+
+-- 複数インスタンスで動くバックグラウンド処理の排他 (期限付きの保持者)
+CREATE TABLE IF NOT EXISTS leases (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- ジャニターの検索用
+CREATE INDEX IF NOT EXISTS idx_rooms_status_last_activity ON rooms (status, last_activity_at);
+CREATE INDEX IF NOT EXISTS idx_rooms_status_unity_seen ON rooms (status, unity_seen_at);
+CREATE INDEX IF NOT EXISTS idx_rooms_counters_uncleared ON rooms (ended_at)
+    WHERE counters_cleared_at IS NULL AND status IN ('ended', 'expired');
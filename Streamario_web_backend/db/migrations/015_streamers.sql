@@ -0,0 +1,26 @@
+-- 015_streamers.sql : 配信者アカウントと API キー、ルームの所有者
+
+CREATE TABLE IF NOT EXISTS streamers (
+    id VARCHAR(26) PRIMARY KEY,           -- ULID
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    disabled_at TIMESTAMP                 -- 設定されたアカウントは認証できない
+);
+
+-- API キーは平文を保存せず SHA-256 のみ保持する (発行時に一度だけ返す)
+CREATE TABLE IF NOT EXISTS streamer_api_keys (
+    id VARCHAR(26) PRIMARY KEY,           -- ULID
+    streamer_id VARCHAR(26) NOT NULL REFERENCES streamers(id) ON DELETE CASCADE,
+    key_hash CHAR(64) NOT NULL UNIQUE,    -- SHA-256 (hex)
+    key_prefix VARCHAR(16) NOT NULL,      -- 一覧で見分けるための先頭部分
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_streamer_api_keys_streamer ON streamer_api_keys (streamer_id, created_at);
+
+-- rooms.streamer_id は配信者の ID (従来どおり認証なしの Unity が作成したルームは 'unity')
+-- 既存値に streamers に無い ID があるため外部キーは張らない
+CREATE INDEX IF NOT EXISTS idx_rooms_streamer_created ON rooms (streamer_id, created_at DESC, id DESC);
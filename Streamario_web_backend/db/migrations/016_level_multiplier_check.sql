@@ -0,0 +1,14 @@
+-- 016_level_multiplier_check.sql : event_catalog.level_multiplier を 1 以上に制限 (カタログの読み込みと同じ条件)
+
+-- 0 以下はこれまで読み込み時に 1.0 として扱っていたため、その値で保存し直す
+-- 0 < level_multiplier < 1 の行は意図が分からないため補正しない (残っていれば制約の追加が失敗するので手で直す)
+UPDATE event_catalog SET level_multiplier = 1.0 WHERE level_multiplier <= 0;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'event_catalog_level_multiplier_check'
+    ) THEN
+        ALTER TABLE event_catalog ADD CONSTRAINT event_catalog_level_multiplier_check CHECK (level_multiplier >= 1);
+    END IF;
+END $$;
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,6 +25,62 @@ type Config struct {
 	LogRelayDefaultScopes []string
 	LogRelayAllowedScopes []string
 
+	// イベントカタログ (空なら DB の event_catalog テーブルを使用)
+	EventCatalogPath string
+	// 新規ルームに適用する閾値戦略名 (step/linear/log/fixed/rate)
+	DefaultThresholdStrategy string
+
+	// イベント送信のレート制限 (視聴者 / IP ごと、Rate が 0 なら無効)
+	RateLimitRequestsPerSec float64
+	RateLimitRequestBurst   int
+	RateLimitPushesPerSec   float64
+	RateLimitPushBurst      int
+
+	// イベント書き込みキュー (write-behind)
+	EventWriteQueueSize     int
+	EventWriteBatchSize     int
+	EventWriteFlushInterval time.Duration
+	EventWriteMaxRetries    int
+	EventFlushTimeout       time.Duration // ゲーム終了時に書き込み待ちを待つ上限
+
+	// API → WebSocket サーバー間の Pub/Sub
+	PubSubBackend    string        // stream (Redis Streams: 取りこぼし分を再送可能) / pubsub (Redis PUBLISH/SUBSCRIBE)
+	PubSubStreamLen  int           // ストリームごとの保持件数
+	PubSubStreamTTL  time.Duration // ストリームの保持期間 (最後の Publish から)
+	UnityReplayLimit int           // Unity 再接続時に再送する最大件数
+	UnityAckTimeout  time.Duration // Unity から ack が返らない場合に再送するまでの時間
+	UnityMaxResends  int           // ack 待ちの再送上限回数
+
+	// Unity WebSocket の死活確認
+	UnityPingInterval time.Duration // Unity へ ping を送る間隔
+	UnityIdleTimeout  time.Duration // この時間 Unity から何も受信しなければ切断 (ルームは終了扱い)
+	UnityWriteTimeout time.Duration // Unity への1回の書き込みの期限
+
+	// Unity 切断後、ルームを終了扱いにするまでの再接続猶予 (0 なら切断で即終了)
+	UnityReconnectGrace time.Duration
+
+	// Unity WebSocket の認証
+	UnityRoomTokenSecret string   // ルームトークン (再接続時の所有証明) の署名鍵 (空なら起動ごとにランダム生成)
+	UnityAllowedOrigins  []string // 接続を許可する Origin ("*" は全許可。Origin ヘッダの無い非ブラウザ接続は常に許可)
+	UnityRelayAdminToken string   // Unity へ直接メッセージを送る relay の管理者トークン (空なら管理者 relay は無効)
+	UnityRequireAPIKey   bool     // 配信者の API キーの無い Unity 接続を拒否する (false なら匿名ルームとして受け付ける)
+
+	// 配信者アカウント
+	StreamerSignupToken string // 配信者の新規登録 (POST /api/streamers) に必要なトークン (空なら登録を受け付けない)
+
+	// ルームの有効期限と放置ルームの片付け (ジャニター)
+	RoomTTL              time.Duration // 作成からルームを期限切れにするまでの時間 (0 なら無期限)
+	RoomIdleTimeout      time.Duration // 押下 / 状態遷移が無く Unity も接続していないルームを期限切れにするまでの時間 (0 なら無効)
+	UnityPresenceTimeout time.Duration // WebSocket サーバーからの Unity 接続の確認がこの時間途絶えたルームを切断扱いにする
+	RoomJanitorInterval  time.Duration // ジャニターの実行間隔 (0 なら API サーバーでジャニターを動かさない)
+	RoomJanitorBatchSize int           // ジャニターが1回の処理で扱うルーム数の上限
+
+	// 視聴者向けライブ統計ストリーム (SSE)
+	RoomStreamInterval time.Duration // ルームあたりの統計配信の最短間隔
+
+	// クライアント IP の判定 (レート制限 / relay の監査ログ)
+	TrustedProxies []string // X-Forwarded-For を信頼するリバースプロキシ (CIDR / IP。空なら接続元アドレスをクライアント IP とする)
+
 	// DB Connection Pool Settings
 	DBMaxOpenConns    int
 	DBMaxIdleConns    int
@@ -47,6 +104,9 @@ func Load() (*Config, error) {
 	// Frontend (CORS)
 	cfg.FrontendURL = getEnv("FRONTEND_URL", "*")
 
+	// Client IP (レート制限 / 監査ログ)
+	cfg.TrustedProxies = parseCSV(os.Getenv("TRUSTED_PROXIES"))
+
 	// Database URL (Supabase/Postgres)
 	// 優先順:
 	//  1. DATABASE_URL（Supabase推奨: ダッシュボードの接続文字列）
@@ -97,6 +157,68 @@ func Load() (*Config, error) {
 	cfg.LogRelayDefaultScopes = defaultScopes
 	cfg.LogRelayAllowedScopes = allowedScopes
 
+	// Event catalog
+	cfg.EventCatalogPath = os.Getenv("EVENT_CATALOG_PATH")
+	cfg.DefaultThresholdStrategy = getEnv("THRESHOLD_STRATEGY", "step")
+
+	// Rate limit
+	cfg.RateLimitRequestsPerSec = getEnvFloat("RATE_LIMIT_REQUESTS_PER_SEC", 5)
+	cfg.RateLimitRequestBurst = getEnvInt("RATE_LIMIT_REQUEST_BURST", 10)
+	cfg.RateLimitPushesPerSec = getEnvFloat("RATE_LIMIT_PUSHES_PER_SEC", 20)
+	cfg.RateLimitPushBurst = getEnvInt("RATE_LIMIT_PUSH_BURST", 40)
+
+	// Event write queue
+	cfg.EventWriteQueueSize = getEnvInt("EVENT_WRITE_QUEUE_SIZE", 10000)
+	cfg.EventWriteBatchSize = getEnvInt("EVENT_WRITE_BATCH_SIZE", 200)
+	cfg.EventWriteFlushInterval = parseDuration(getEnv("EVENT_WRITE_FLUSH_INTERVAL", "500ms"), 500*time.Millisecond)
+	cfg.EventWriteMaxRetries = getEnvInt("EVENT_WRITE_MAX_RETRIES", 5)
+	cfg.EventFlushTimeout = parseDuration(getEnv("EVENT_FLUSH_TIMEOUT", "5s"), 5*time.Second)
+
+	// Pub/Sub
+	cfg.PubSubBackend = getEnv("PUBSUB_BACKEND", "stream")
+	cfg.PubSubStreamLen = getEnvInt("PUBSUB_STREAM_MAXLEN", 1000)
+	cfg.PubSubStreamTTL = parseDuration(getEnv("PUBSUB_STREAM_TTL", "24h"), 24*time.Hour)
+	cfg.UnityReplayLimit = getEnvInt("UNITY_REPLAY_LIMIT", 100)
+	cfg.UnityAckTimeout = parseDuration(getEnv("UNITY_ACK_TIMEOUT", "3s"), 3*time.Second)
+	cfg.UnityMaxResends = getEnvInt("UNITY_MAX_RESENDS", 3)
+
+	// Unity heartbeat
+	cfg.UnityPingInterval = parseDuration(getEnv("UNITY_PING_INTERVAL", "15s"), 15*time.Second)
+	cfg.UnityIdleTimeout = parseDuration(getEnv("UNITY_IDLE_TIMEOUT", "45s"), 45*time.Second)
+	cfg.UnityWriteTimeout = parseDuration(getEnv("UNITY_WRITE_TIMEOUT", "10s"), 10*time.Second)
+	cfg.UnityReconnectGrace = parseDuration(getEnv("UNITY_RECONNECT_GRACE", "60s"), 60*time.Second)
+	if v := getEnv("UNITY_RECONNECT_GRACE", ""); v == "0" || v == "0s" {
+		cfg.UnityReconnectGrace = 0 // 猶予なし (切断で即終了)
+	}
+
+	// Unity auth
+	cfg.UnityRoomTokenSecret = os.Getenv("UNITY_ROOM_TOKEN_SECRET") // 空なら起動ごとにランダム生成 (公開された既定値で偽造されないように)
+	cfg.UnityAllowedOrigins = parseCSV(getEnv("UNITY_ALLOWED_ORIGINS", "*"))
+	cfg.UnityRelayAdminToken = os.Getenv("UNITY_RELAY_ADMIN_TOKEN")
+	cfg.UnityRequireAPIKey = getEnvBool("UNITY_REQUIRE_API_KEY", false)
+
+	// Streamer accounts
+	cfg.StreamerSignupToken = os.Getenv("STREAMER_SIGNUP_TOKEN")
+
+	// Room expiry / janitor
+	cfg.RoomTTL = parseDuration(getEnv("ROOM_TTL", "24h"), 24*time.Hour)
+	if v := getEnv("ROOM_TTL", ""); v == "0" || v == "0s" {
+		cfg.RoomTTL = 0 // 無期限
+	}
+	cfg.RoomIdleTimeout = parseDuration(getEnv("ROOM_IDLE_TIMEOUT", "2h"), 2*time.Hour)
+	if v := getEnv("ROOM_IDLE_TIMEOUT", ""); v == "0" || v == "0s" {
+		cfg.RoomIdleTimeout = 0 // 無操作での期限切れなし
+	}
+	cfg.UnityPresenceTimeout = parseDuration(getEnv("UNITY_PRESENCE_TIMEOUT", "2m"), 2*time.Minute)
+	cfg.RoomJanitorInterval = parseDuration(getEnv("ROOM_JANITOR_INTERVAL", "1m"), time.Minute)
+	if v := getEnv("ROOM_JANITOR_INTERVAL", ""); v == "0" || v == "0s" {
+		cfg.RoomJanitorInterval = 0 // このインスタンスではジャニターを動かさない
+	}
+	cfg.RoomJanitorBatchSize = getEnvInt("ROOM_JANITOR_BATCH_SIZE", 100)
+
+	// Room stream
+	cfg.RoomStreamInterval = parseDuration(getEnv("ROOM_STREAM_INTERVAL", "250ms"), 250*time.Millisecond)
+
 	// DB Connection Pool
 	cfg.DBMaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", 10)
 	cfg.DBMaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", 10)
@@ -115,6 +237,15 @@ func getEnvInt(key string, def int) int {
 	return def
 }
 
+func getEnvFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if val, err := strconv.ParseFloat(v, 64); err == nil {
+			return val
+		}
+	}
+	return def
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"streamerrio-backend/internal/model"
@@ -11,6 +14,12 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// 1リクエストで受け付ける押下数の上限 (超えたら 400。レート制限より前に検査し、合計の桁あふれも防ぐ)
+const (
+	maxPushCountPerEvent   = 1000 // push_events の1件あたり
+	maxPushCountPerRequest = 5000 // push_events の合計
+)
+
 // APIHandler: REST エンドポイント集約 (ルーム取得 / イベント送信 / 統計取得)
 type APIHandler struct {
 	roomService     *service.RoomService
@@ -18,6 +27,9 @@ type APIHandler struct {
 	sessionService  *service.GameSessionService
 	viewerService   *service.ViewerService
 	logTokenService *service.LogTokenService
+	rateLimiter     *service.RateLimiter
+	roomStream      *service.RoomStreamHub
+	streamers       *service.StreamerService
 	logger          *slog.Logger
 }
 
@@ -47,6 +59,24 @@ func (h *APIHandler) WithLogger(logger *slog.Logger) *APIHandler {
 	return h
 }
 
+// WithRateLimiter: イベント送信のレート制限を有効化 (未設定なら制限なし)
+func (h *APIHandler) WithRateLimiter(rl *service.RateLimiter) *APIHandler {
+	h.rateLimiter = rl
+	return h
+}
+
+// WithRoomStream: ライブ統計ストリーム (SSE) を有効化
+func (h *APIHandler) WithRoomStream(hub *service.RoomStreamHub) *APIHandler {
+	h.roomStream = hub
+	return h
+}
+
+// WithStreamerService: 配信者アカウント (API キー認証 / 所有ルームの管理) を有効化
+func (h *APIHandler) WithStreamerService(svc *service.StreamerService) *APIHandler {
+	h.streamers = svc
+	return h
+}
+
 // GetOrCreateViewerID: 視聴者端末識別用の ID を払い出す
 func (h *APIHandler) GetOrCreateViewerID(c echo.Context) error {
 	var existing string
@@ -223,6 +253,21 @@ func (h *APIHandler) SendEvent(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
 	}
 
+	// 押下数の範囲を検査 (1件ずつ上限以下なので合計は桁あふれしない)
+	totalPushCount := int64(0)
+	for _, event := range req.PushEvents {
+		if event.PushCount <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "push count must be greater than 0"})
+		}
+		if event.PushCount > maxPushCountPerEvent {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("push count must be at most %d", maxPushCountPerEvent)})
+		}
+		totalPushCount += event.PushCount
+		if totalPushCount > maxPushCountPerRequest {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("total push count must be at most %d", maxPushCountPerRequest)})
+		}
+	}
+
 	var viewerID *string
 	var viewerName *string
 	if req.ViewerID != "" {
@@ -232,38 +277,81 @@ func (h *APIHandler) SendEvent(c echo.Context) error {
 		viewerName = &req.ViewerName
 	}
 
-	// ゲーム終了済みの場合はサマリーを返す（既存の処理）
-	if room.Status == "ended" {
+	// 連打攻撃防止: IP ごとのリクエスト数を必ず制限し、確認できた視聴者は視聴者ごとにも制限する
+	// viewer_id は本文の値で付け替えられるため、それだけでは新しいバケットを得られないようにする
+	// 制限の記録にも確認できた viewer_id のみ残す (他人の ID を名乗った分をその視聴者に付けない)
+	var limitedViewerID *string
+	clientKeys := []string{"ip:" + c.RealIP()}
+	if viewerID != nil && h.verifiedViewer(c, *viewerID) {
+		limitedViewerID = viewerID
+		clientKeys = append(clientKeys, "viewer:"+*viewerID)
+	}
+	if h.rateLimiter != nil {
+		for _, clientKey := range clientKeys {
+			if ok, retryAfter := h.rateLimiter.AllowRequest(roomID, clientKey); !ok {
+				requested := make(map[model.EventType]int64, len(req.PushEvents))
+				for _, event := range req.PushEvents {
+					requested[model.EventType(event.ButtonName)] += event.PushCount
+				}
+				h.rateLimiter.Record(roomID, clientKey, limitedViewerID, model.RateLimitReasonRequest, requested, nil)
+				return rateLimited(c, retryAfter)
+			}
+		}
+	}
+
+	// ルーム終了済み / ラウンド終了後 (次のラウンドの開始待ち) の場合は直近のラウンドのサマリーを返す
+	// 次のラウンドが始まれば同じルームでそのまま押下を受け付ける
+	lastRound := h.sessionService.LastFinishedRound(room)
+	if room.Status == model.RoomStateEnded || (room.Status == model.RoomStateWaiting && lastRound > 0) {
 		if viewerID == nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "viewer_id is required after game end"})
 		}
-		summary, err := h.sessionService.GetViewerSummary(roomID, *viewerID)
+		summary, err := h.sessionService.GetViewerSummary(roomID, lastRound, *viewerID)
 		if err != nil {
 			h.logger.Error("viewer_summary_failed", slog.String("room_id", roomID), slog.String("viewer_id", *viewerID), slog.Any("error", err))
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"game_over":      true,
+			"round":          lastRound,
+			"room_status":    room.Status,
 			"viewer_summary": summary,
 		})
 	}
 
+	// 配信者 (Unity) の再接続待ちの間は受け付けない (猶予内に戻れば再開、戻らなければ終了)
+	if room.Status == model.RoomStateDisconnected {
+		return streamerReconnecting(c, h.roomService.ReconnectRemaining(room, time.Now()))
+	}
+
 	// ゲームが開始されていない場合はイベントを処理しない
-	if room.Status != "in_game" {
-		h.logger.Info("game not started yet, rejecting event", slog.String("room_id", roomID), slog.String("status", room.Status))
+	if room.Status != model.RoomStateInGame && room.Status != model.RoomStatePaused {
+		h.logger.Info("game not started yet, rejecting event", slog.String("room_id", roomID), slog.String("status", string(room.Status)))
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "game not started"})
 	}
 
-	// PushCount合計
-	totalPushCount := int64(0)
-	// PushEventMap: ボタン名とPushCountのマップ
-	PushEventMap := map[model.EventType]int64{
-		model.SKILL1: 0,
-		model.SKILL2: 0,
-		model.SKILL3: 0,
-		model.ENEMY1: 0,
-		model.ENEMY2: 0,
-		model.ENEMY3: 0,
+	// 一時停止中: pause_mode = reject なら受け付けない (buffer なら検証・レート制限の後で保留する)
+	pauseMode := ""
+	if room.Status == model.RoomStatePaused {
+		settings, err := model.ParseRoomSettings(room.Settings)
+		if err != nil {
+			h.logger.Warn("stored room settings invalid, using default pause mode", slog.String("room_id", roomID), slog.Any("error", err))
+		}
+		pauseMode = settings.EffectivePauseMode()
+		if pauseMode == model.PauseModeReject {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":      "game paused",
+				"status":     model.RoomStatePaused,
+				"pause_mode": pauseMode,
+			})
+		}
+	}
+
+	// PushEventMap: ボタン名とPushCountのマップ（イベントカタログに定義された種別のみ受け付ける）
+	catalog := h.eventService.Catalog()
+	PushEventMap := make(map[model.EventType]int64, len(catalog.Types()))
+	for _, et := range catalog.Types() {
+		PushEventMap[et] = 0
 	}
 
 	// PushEventsのバリデーション
@@ -274,26 +362,57 @@ func (h *APIHandler) SendEvent(c echo.Context) error {
 		if _, ok := PushEventMap[eventType]; !ok {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid event type"})
 		}
-		pushCount := event.PushCount
-		if pushCount <= 0 {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "push count must be greater than 0"})
+		PushEventMap[eventType] += event.PushCount
+	}
+
+	// 連打攻撃防止: 押下数をトークンバケットで制限し、超過分は記録して捨てる
+	// IP と視聴者の両方のバケットを順に適用する (前のバケットで受け付けた分だけを次に渡す)
+	var droppedPushes int64
+	if h.rateLimiter != nil {
+		for _, clientKey := range clientKeys {
+			limited := h.rateLimiter.LimitPushes(roomID, clientKey, PushEventMap)
+			if limited.Dropped == 0 {
+				continue
+			}
+			h.rateLimiter.Record(roomID, clientKey, limitedViewerID, model.RateLimitReasonPush, PushEventMap, limited.Accepted)
+			droppedPushes += limited.Dropped
+			if droppedPushes >= totalPushCount {
+				return rateLimited(c, limited.RetryAfter)
+			}
+			for et := range PushEventMap {
+				PushEventMap[et] = limited.Accepted[et]
+			}
+		}
+	}
+
+	// 一時停止中 (buffer): 押下は記録するがカウンタには加算せず、再開時にまとめて反映する
+	if pauseMode == model.PauseModeBuffer {
+		buffered, err := h.eventService.BufferEvent(room, PushEventMap, viewerID)
+		if err != nil {
+			h.logger.Error("buffer_event_failed", slog.String("room_id", roomID), slog.Any("error", err))
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
-		// 連打攻撃防止
-		// 1つのボタンごとに20回までしか押せないようにする
-		if pushCount > 20 {
-			pushCount = 20
+		stats, err := h.eventService.GetRoomStats(room)
+		if err != nil {
+			h.logger.Error("failed to get room stats after buffering", slog.String("room_id", roomID), slog.Any("error", err))
 		}
-		totalPushCount += pushCount
-		PushEventMap[eventType] = pushCount
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"buffered":        true,
+			"buffered_pushes": buffered,
+			"room_status":     room.Status,
+			"pause_mode":      pauseMode,
+			"stats":           stats,
+			"dropped_pushes":  droppedPushes,
+		})
 	}
 
-	responses, err := h.eventService.ProcessEvent(roomID, PushEventMap, viewerID, viewerName)
+	responses, err := h.eventService.ProcessEvent(room, PushEventMap, viewerID, viewerName)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
 	// 最新の統計情報を取得
-	stats, err := h.eventService.GetRoomStats(roomID)
+	stats, err := h.eventService.GetRoomStats(room)
 	if err != nil {
 		// 統計取得失敗はログに出すが、イベント送信自体は成功しているので続行するか、エラーにするか
 		// ここではフロントエンドが stats 依存になったため、不整合を防ぐためエラーログを出して stats は空にするか、500にする
@@ -311,54 +430,341 @@ func (h *APIHandler) SendEvent(c echo.Context) error {
 
 	// 配列として結果を返す
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"event_results": responses,
-		"viewer_count":  currentViewerCount, // フロントエンド向けに視聴者数を追加
-		"stats":         stats,
+		"event_results":  responses,
+		"viewer_count":   currentViewerCount, // フロントエンド向けに視聴者数を追加
+		"room_status":    room.Status,
+		"stats":          stats,
+		"dropped_pushes": droppedPushes, // レート制限で捨てた押下数
+	})
+}
+
+// verifiedViewer: 本文の viewer_id が本人のものと確認できるか (viewer_id Cookie と一致する場合のみ)
+// 登録済みの ID でも Cookie が無ければ信用しない (他人の ID を名乗ってその視聴者のバケットを消費させないため)。
+func (h *APIHandler) verifiedViewer(c echo.Context, viewerID string) bool {
+	cookie, err := c.Cookie("viewer_id")
+	return err == nil && cookie.Value == viewerID
+}
+
+// rateLimited: 429 と Retry-After (秒, 切り上げ) を返す
+func rateLimited(c echo.Context, retryAfter time.Duration) error {
+	secs := int64((retryAfter + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+		"error":          "rate limited",
+		"retry_after_ms": retryAfter.Milliseconds(),
+	})
+}
+
+// streamerReconnecting: 配信者の再接続待ちとして 503 と Retry-After (秒) を返す
+func streamerReconnecting(c echo.Context, remaining time.Duration) error {
+	retryAfter := remaining
+	if retryAfter <= 0 || retryAfter > 5*time.Second {
+		retryAfter = 5 * time.Second // 猶予の残りが長くても再接続は早く終わりうるため短めに再試行させる
+	}
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10))
+	return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+		"error":                "streamer reconnecting",
+		"status":               model.RoomStateDisconnected,
+		"reconnect_timeout_ms": remaining.Milliseconds(),
+	})
+}
+
+// ListEventTypes: イベントカタログ（ボタン定義・表示名・閾値設定）を返す
+func (h *APIHandler) ListEventTypes(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"event_types": h.eventService.Catalog().Entries(),
 	})
 }
 
 // GetRoomStats: 現在のイベント種別ごとのカウントと閾値を返す
 func (h *APIHandler) GetRoomStats(c echo.Context) error {
 	roomID := c.Param("id")
-	if _, err := h.roomService.GetRoom(roomID); err != nil {
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
 	}
-	stats, err := h.eventService.GetRoomStats(roomID)
+	stats, err := h.eventService.GetRoomStats(room)
 	if err != nil {
 		h.logger.Error("get_room_stats_failed", slog.String("room_id", roomID), slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"room_id": roomID,
-		"stats":   stats,
-		"time":    time.Now(),
+		"room_id":     roomID,
+		"room_status": room.Status, // paused の間は押下が拒否 / 保留される
+		"stats":       stats,
+		"time":        time.Now(),
 	})
 }
 
-// GetRoomResult: 終了後の集計結果を取得
-func (h *APIHandler) GetRoomResult(c echo.Context) error {
+// StreamRoom: ルームの統計と発動通知を Server-Sent Events で配信
+// 接続直後に現在の統計を1件送り、以降は stats_update (間引き) と game_event (即時) を流す。
+func (h *APIHandler) StreamRoom(c echo.Context) error {
+	if h.roomStream == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "stream disabled"})
+	}
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	snapshot, err := h.roomStream.Snapshot(room)
+	if err != nil {
+		h.logger.Error("stream_room_snapshot_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	sub, unsubscribe := h.roomStream.Subscribe(roomID)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // リバースプロキシでのバッファリングを無効化
+	res.WriteHeader(http.StatusOK)
+	if err := writeSSE(res, snapshot); err != nil {
+		return nil
+	}
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-sub.C():
+			if !ok {
+				return nil
+			}
+			if err := writeSSE(res, msg); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			// コメント行でアイドル切断を防ぐ
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// writeSSE: 1件を SSE 形式で書き込んで即時に送出
+func writeSSE(res *echo.Response, msg service.RoomStreamMessage) error {
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", msg.Event, msg.Data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// ListRoomTriggers: ルームの発動履歴 (閾値到達で送信した game_event) を発動順に返す
+// ?round=N でラウンドを絞り込む (省略時は全ラウンド)。
+func (h *APIHandler) ListRoomTriggers(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	round := 0
+	if raw := c.QueryParam("round"); raw != "" {
+		if round, err = strconv.Atoi(raw); err != nil || round < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid round"})
+		}
+	}
+	triggers, err := h.eventService.ListTriggers(roomID, round)
+	if err != nil {
+		h.logger.Error("list_room_triggers_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room_id":  roomID,
+		"triggers": triggers,
+	})
+}
+
+// ListRoomStateHistory: ルームの状態遷移履歴を古い順に返す
+func (h *APIHandler) ListRoomStateHistory(c echo.Context) error {
 	roomID := c.Param("id")
 	room, err := h.roomService.GetRoom(roomID)
 	if err != nil || room == nil {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
 	}
-	if room.Status != "ended" {
-		return c.JSON(http.StatusConflict, map[string]string{"error": "room not ended"})
+	history, err := h.roomService.StateHistory(roomID)
+	if err != nil {
+		h.logger.Error("list_room_state_history_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room_id":     roomID,
+		"status":      room.Status,
+		"transitions": history,
+	})
+}
+
+// UpdateRoomSettings: ルーム単位の閾値設定を検証して更新 (ゲーム中も即時反映)
+// 配信者アカウントが所有するルームは所有者の API キー (Authorization: Bearer) が必要。
+func (h *APIHandler) UpdateRoomSettings(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	owned, err := h.hasStreamerOwner(room)
+	if err != nil {
+		h.logger.Error("get_room_owner_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if owned {
+		streamer, status, err := h.authenticateStreamer(c)
+		if err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+		if !h.streamers.Owns(room, streamer.ID) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "room is owned by another streamer"})
+		}
+	}
+	return h.updateRoomSettings(c, room)
+}
+
+// updateRoomSettings: 設定の更新本体 (権限確認は呼び出し側で行う)
+// リクエストに含まれたセクションのみ置き換える（空オブジェクト / 空配列で既定値に戻す）。
+func (h *APIHandler) updateRoomSettings(c echo.Context, room *model.Room) error {
+	roomID := room.ID
+	if room.Status.Terminal() {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "room already ended"})
+	}
+
+	var req model.RoomSettings
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+
+	// 保存済みの設定を読めない場合は上書きしない (固定の threshold_strategy を既定値に戻してしまうため)
+	settings, err := model.ParseRoomSettings(room.Settings)
+	if err != nil {
+		h.logger.Error("stored room settings invalid", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "stored room settings invalid"})
+	}
+	// 閾値戦略はルーム作成時に確定するため変更不可（パラメータの調整のみ許可）
+	currentStrategy := settings.ThresholdStrategy
+	if currentStrategy == "" {
+		currentStrategy = service.ThresholdStrategyStep
+	}
+	if req.ThresholdStrategy != "" && req.ThresholdStrategy != currentStrategy {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "threshold_strategy cannot be changed after room creation"})
+	}
+	if req.ThresholdParams != nil {
+		settings.ThresholdParams = req.ThresholdParams
+	}
+	if req.EventThresholds != nil {
+		settings.EventThresholds = req.EventThresholds
+	}
+	if req.ViewerMultipliers != nil {
+		settings.ViewerMultipliers = req.ViewerMultipliers
+	}
+	if req.TriggerLimit != nil {
+		settings.TriggerLimit = req.TriggerLimit
+	}
+	if req.PauseMode != "" {
+		settings.PauseMode = req.PauseMode
+	}
+	if err := h.eventService.ValidateRoomSettings(settings); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// 読み込んだ設定を元にマージしているため、その間に別の更新が入っていれば 409 (再取得してやり直してもらう)
+	err = h.roomService.UpdateSettings(roomID, settings, room.Settings)
+	if errors.Is(err, service.ErrRoomSettingsConflict) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("update_room_settings_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room_id":  roomID,
+		"settings": settings,
+	})
+}
+
+// GetRoomResult: 直近に終了したラウンドの集計結果を取得
+func (h *APIHandler) GetRoomResult(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
 	}
 	summary, err := h.sessionService.GetRoomResult(roomID)
+	if errors.Is(err, service.ErrRoundNotFound) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "no finished round"})
+	}
 	if err != nil {
 		h.logger.Error("get_room_result_failed", slog.String("room_id", roomID), slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	return h.roundResult(c, summary)
+}
+
+// ListRoomRounds: ルームのラウンド一覧 (開始 / 終了時刻) をラウンド順に返す
+func (h *APIHandler) ListRoomRounds(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	rounds, err := h.sessionService.ListRounds(roomID)
+	if err != nil {
+		h.logger.Error("list_room_rounds_failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room_id":       roomID,
+		"status":        room.Status,
+		"current_round": room.CurrentRound,
+		"rounds":        rounds,
+	})
+}
+
+// GetRoundResult: 指定ラウンドの集計結果を取得 (進行中のラウンドは 409)
+func (h *APIHandler) GetRoundResult(c echo.Context) error {
+	roomID := c.Param("id")
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	round, err := strconv.Atoi(c.Param("round"))
+	if err != nil || round < 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid round"})
+	}
+	summary, err := h.sessionService.GetRoundResult(roomID, round)
+	switch {
+	case errors.Is(err, service.ErrRoundNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "round not found"})
+	case errors.Is(err, service.ErrRoundInProgress):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "round in progress"})
+	case err != nil:
+		h.logger.Error("get_round_result_failed", slog.String("room_id", roomID), slog.Int("round", round), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return h.roundResult(c, summary)
+}
+
+// roundResult: ラウンドの結果レスポンス (?viewer_id= があればその視聴者の内訳も付ける)
+func (h *APIHandler) roundResult(c echo.Context, summary *model.RoomResultSummary) error {
 	var viewerSummary *model.ViewerSummary
 	if viewerID := c.QueryParam("viewer_id"); viewerID != "" {
-		if vs, err := h.sessionService.GetViewerSummary(roomID, viewerID); err == nil {
+		if vs, err := h.sessionService.GetViewerSummary(summary.RoomID, summary.Round, viewerID); err == nil {
 			viewerSummary = vs
 		}
 	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"game_over":      true,
 		"room_id":        summary.RoomID,
+		"round":          summary.Round,
+		"started_at":     summary.StartedAt,
 		"ended_at":       summary.EndedAt,
 		"top_by_event":   summary.TopByEvent,
 		"top_overall":    summary.TopOverall,
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+	"streamerrio-backend/internal/service"
+	"streamerrio-backend/pkg/protocol"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SetRelay: relay (モデレーターが Unity へ直接メッセージを送る機能) の認証・スキーマ・監査ログを設定
+// adminToken が空なら管理者による relay は無効 (API キーを持つルームの所有者のみ)。
+func (h *WebSocketHandler) SetRelay(adminToken string, registry *service.RelayMessageRegistry, audit repository.RelayAuditRepository) {
+	h.relayAdminToken = adminToken
+	h.relayRegistry = registry
+	h.relayAudit = audit
+}
+
+// RelayActionToUnity: 管理者 / 配信者の指定したメッセージをルームの Unity へ送信
+// Authorization: Bearer に管理者トークンか所有者の API キーが必要。送れるのは RelayMessageRegistry に登録されたタイプのみで、
+// 認証できたリクエストは結果 (sent / rejected / failed) に関わらず監査ログに残す。
+func (h *WebSocketHandler) RelayActionToUnity(c echo.Context) error {
+	// リクエストボディを JSON として受け取り、room_id 以外を Unity へ転送する
+	var payload map[string]interface{}
+	if err := c.Bind(&payload); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": fmt.Sprintf("invalid json: %v", err)})
+	}
+
+	// room_idが含まれているか確認
+	roomID, ok := payload["room_id"].(string)
+	if !ok || roomID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "room_id is required"})
+	}
+
+	actor, ok := h.authorizeRelay(c, roomID)
+	if !ok {
+		h.logger.Warn("relay unauthorized", slog.String("room_id", roomID), slog.String("remote_addr", c.RealIP()))
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"error": "admin or room owner credential required"})
+	}
+
+	// room_id を除去して転送用のペイロードを作成
+	forward := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if k == "room_id" {
+			continue
+		}
+		forward[k] = v
+	}
+	msgType, _ := forward["type"].(string)
+
+	if h.relayRegistry == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"error": "relay disabled"})
+	}
+	if err := h.relayRegistry.Validate(forward); err != nil {
+		h.recordRelay(c, roomID, msgType, actor, forward, model.RelayStatusRejected, err)
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":         err.Error(),
+			"allowed_types": h.relayRegistry.Types(),
+		})
+	}
+	// Unity 側で通常の発動と区別できるようにする
+	forward["relayed"] = true
+	forward["version"] = protocol.Version
+
+	// Unity へ送信
+	if err := h.SendEventToUnity(roomID, forward); err != nil {
+		h.recordRelay(c, roomID, msgType, actor, forward, model.RelayStatusFailed, err)
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+	}
+	h.recordRelay(c, roomID, msgType, actor, forward, model.RelayStatusSent, nil)
+	h.logger.Info("action relayed to unity", slog.String("room_id", roomID), slog.String("type", msgType), slog.String("actor", actor))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// ListRelayAudit: ルームの relay 監査ログを新しい順に返す (relay と同じ認証)
+func (h *WebSocketHandler) ListRelayAudit(c echo.Context) error {
+	roomID := c.Param("room_id")
+	if _, ok := h.authorizeRelay(c, roomID); !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"error": "admin or room owner credential required"})
+	}
+	if h.relayAudit == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"error": "relay audit disabled"})
+	}
+	limit := 100
+	if v, err := strconv.Atoi(c.QueryParam("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	records, err := h.relayAudit.ListByRoom(roomID, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"room_id": roomID, "records": records})
+}
+
+// authorizeRelay: Bearer トークンが管理者トークンか所有者の API キーなら実行者種別を返す
+// ルームトークンは Unity に渡す失効できない資格情報のため relay には使わせない。
+func (h *WebSocketHandler) authorizeRelay(c echo.Context, roomID string) (string, bool) {
+	auth := c.Request().Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == "" {
+		return "", false
+	}
+	if h.relayAdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.relayAdminToken)) == 1 {
+		return model.RelayActorAdmin, true
+	}
+	// 配信者の API キー (ルームの所有者のみ)
+	if h.streamers != nil && h.roomService != nil {
+		if streamer, err := h.streamers.Authenticate(token); err == nil {
+			if room, err := h.roomService.FindRoom(roomID); err == nil && h.streamers.Owns(room, streamer.ID) {
+				return model.RelayActorRoomOwner, true
+			}
+		}
+	}
+	return "", false
+}
+
+// recordRelay: relay の結果を監査ログに記録 (失敗はログのみ)
+func (h *WebSocketHandler) recordRelay(c echo.Context, roomID, msgType, actor string, payload map[string]interface{}, status string, cause error) {
+	if h.relayAudit == nil {
+		return
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		raw = []byte("{}")
+	}
+	rec := &model.RelayAuditRecord{
+		RoomID:      roomID,
+		MessageType: msgType,
+		Actor:       actor,
+		RemoteAddr:  c.RealIP(),
+		Payload:     string(raw),
+		Status:      status,
+		CreatedAt:   time.Now(),
+	}
+	if cause != nil {
+		msg := cause.Error()
+		rec.Error = &msg
+	}
+	if err := h.relayAudit.Create(rec); err != nil {
+		h.logger.Warn("record relay audit failed", slog.String("room_id", roomID), slog.Any("error", err))
+	}
+}
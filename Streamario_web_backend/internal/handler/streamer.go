@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamerContextKey: RequireStreamer が認証済みの配信者を格納する echo.Context のキー
+const streamerContextKey = "streamer"
+
+// RequireStreamer: Authorization: Bearer <API キー> で配信者を認証するミドルウェア
+func (h *APIHandler) RequireStreamer(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		streamer, status, err := h.authenticateStreamer(c)
+		if err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+		c.Set(streamerContextKey, streamer)
+		return next(c)
+	}
+}
+
+// authenticateStreamer: リクエストの API キーから配信者を特定 (失敗時は返すべきステータスも返す)
+func (h *APIHandler) authenticateStreamer(c echo.Context) (*model.Streamer, int, error) {
+	if h.streamers == nil {
+		return nil, http.StatusServiceUnavailable, errors.New("streamer accounts disabled")
+	}
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	key := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	if !strings.HasPrefix(auth, "Bearer ") || key == "" {
+		return nil, http.StatusUnauthorized, errors.New("api key required")
+	}
+	streamer, err := h.streamers.Authenticate(key)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		return nil, http.StatusUnauthorized, err
+	}
+	if err != nil {
+		h.logger.Error("authenticate_streamer_failed", slog.Any("error", err))
+		return nil, http.StatusInternalServerError, err
+	}
+	return streamer, http.StatusOK, nil
+}
+
+// currentStreamer: RequireStreamer で認証済みの配信者
+func currentStreamer(c echo.Context) *model.Streamer {
+	streamer, _ := c.Get(streamerContextKey).(*model.Streamer)
+	return streamer
+}
+
+// hasStreamerOwner: ルームが登録済みの配信者のものか (認証なしの Unity / 旧データのルームは false)
+func (h *APIHandler) hasStreamerOwner(room *model.Room) (bool, error) {
+	if h.streamers == nil || room.StreamerID == "" || room.StreamerID == model.UnityAnonymousStreamerID {
+		return false, nil
+	}
+	owner, err := h.streamers.Get(room.StreamerID)
+	if err != nil {
+		return false, err
+	}
+	return owner != nil, nil
+}
+
+// ownedRoom: 認証済みの配信者が所有するルームを取得 (期限切れ / 終了済みを含む。他人のルームは存在しない扱い)
+func (h *APIHandler) ownedRoom(c echo.Context) (*model.Room, error) {
+	room, err := h.roomService.FindRoom(c.Param("id"))
+	if err != nil {
+		h.logger.Error("get_owned_room_failed", slog.String("room_id", c.Param("id")), slog.Any("error", err))
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if room == nil || !h.streamers.Owns(room, currentStreamer(c).ID) {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "room not found"})
+	}
+	return room, nil
+}
+
+// RegisterStreamer: 配信者を登録し最初の API キーを返す (Authorization: Bearer <STREAMER_SIGNUP_TOKEN> が必要)
+// API キーの平文はこのレスポンスでしか返さない。
+func (h *APIHandler) RegisterStreamer(c echo.Context) error {
+	if h.streamers == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "streamer accounts disabled"})
+	}
+	token := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !h.streamers.CanRegister(token) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid signup token"})
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	streamer, key, err := h.streamers.Register(req.Name)
+	if errors.Is(err, service.ErrInvalidStreamerName) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("register_streamer_failed", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	h.logger.Info("streamer registered", slog.String("streamer_id", streamer.ID), slog.String("key_id", key.ID))
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"streamer": streamer,
+		"api_key":  key,
+	})
+}
+
+// GetStreamer: 認証中の配信者
+func (h *APIHandler) GetStreamer(c echo.Context) error {
+	return c.JSON(http.StatusOK, currentStreamer(c))
+}
+
+// ListStreamerAPIKeys: API キー一覧 (平文は含まない)
+func (h *APIHandler) ListStreamerAPIKeys(c echo.Context) error {
+	streamer := currentStreamer(c)
+	keys, err := h.streamers.ListAPIKeys(streamer.ID)
+	if err != nil {
+		h.logger.Error("list_streamer_api_keys_failed", slog.String("streamer_id", streamer.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"api_keys": keys})
+}
+
+// CreateStreamerAPIKey: API キーを追加発行 (平文はこのレスポンスでしか返さない)
+func (h *APIHandler) CreateStreamerAPIKey(c echo.Context) error {
+	streamer := currentStreamer(c)
+	var req struct {
+		Label string `json:"label"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	key, err := h.streamers.IssueAPIKey(streamer.ID, req.Label)
+	if err != nil {
+		h.logger.Error("create_streamer_api_key_failed", slog.String("streamer_id", streamer.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"api_key": key})
+}
+
+// RevokeStreamerAPIKey: API キーを失効させる (使用中のキー自身も失効できる)
+func (h *APIHandler) RevokeStreamerAPIKey(c echo.Context) error {
+	streamer := currentStreamer(c)
+	keyID := c.Param("key_id")
+	err := h.streamers.RevokeAPIKey(streamer.ID, keyID)
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		h.logger.Error("revoke_streamer_api_key_failed", slog.String("streamer_id", streamer.ID), slog.String("key_id", keyID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"id": keyID, "revoked": true})
+}
+
+// ListStreamerRooms: 配信者のルームを新しい順に返す (?limit=1〜100, ?before=<RFC3339>&before_id=<room_id> で続きを取得)
+func (h *APIHandler) ListStreamerRooms(c echo.Context) error {
+	streamer := currentStreamer(c)
+	limit := 20
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		limit = n
+	}
+	var before *time.Time
+	if v := c.QueryParam("before"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid before"})
+		}
+		before = &t
+	}
+	beforeID := c.QueryParam("before_id")
+	if beforeID != "" && before == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "before_id requires before"})
+	}
+	rooms, err := h.roomService.ListByStreamer(streamer.ID, before, beforeID, limit)
+	if err != nil {
+		h.logger.Error("list_streamer_rooms_failed", slog.String("streamer_id", streamer.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	var next *time.Time
+	var nextID *string
+	if len(rooms) > 0 && len(rooms) >= limit {
+		last := rooms[len(rooms)-1]
+		next, nextID = &last.CreatedAt, &last.ID
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"rooms":          rooms,
+		"next_before":    next,
+		"next_before_id": nextID,
+	})
+}
+
+// GetStreamerRoom: 所有ルームの詳細 (設定 / ラウンド一覧を含む)
+func (h *APIHandler) GetStreamerRoom(c echo.Context) error {
+	room, err := h.ownedRoom(c)
+	if room == nil {
+		return err
+	}
+	settings, err := model.ParseRoomSettings(room.Settings)
+	if err != nil {
+		h.logger.Warn("stored room settings invalid", slog.String("room_id", room.ID), slog.Any("error", err))
+	}
+	rounds, err := h.sessionService.ListRounds(room.ID)
+	if err != nil {
+		h.logger.Error("list_room_rounds_failed", slog.String("room_id", room.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room":     room,
+		"settings": settings,
+		"rounds":   rounds,
+	})
+}
+
+// ListStreamerRoomHistory: 所有ルームの状態遷移履歴とラウンド一覧 (終了 / 期限切れのルームも参照できる)
+func (h *APIHandler) ListStreamerRoomHistory(c echo.Context) error {
+	room, err := h.ownedRoom(c)
+	if room == nil {
+		return err
+	}
+	history, err := h.roomService.StateHistory(room.ID)
+	if err != nil {
+		h.logger.Error("list_room_state_history_failed", slog.String("room_id", room.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	rounds, err := h.sessionService.ListRounds(room.ID)
+	if err != nil {
+		h.logger.Error("list_room_rounds_failed", slog.String("room_id", room.ID), slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"room_id":     room.ID,
+		"status":      room.Status,
+		"transitions": history,
+		"rounds":      rounds,
+	})
+}
+
+// UpdateStreamerRoomSettings: 所有ルームの設定を更新 (内容は PUT /api/rooms/:id/settings と同じ)
+func (h *APIHandler) UpdateStreamerRoomSettings(c echo.Context) error {
+	room, err := h.ownedRoom(c)
+	if room == nil {
+		return err
+	}
+	return h.updateRoomSettings(c, room)
+}
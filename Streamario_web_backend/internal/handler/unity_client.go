@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"streamerrio-backend/pkg/protocol"
+)
+
+// pendingMessage: Unity から ack が返っていない送信済みメッセージ
+type pendingMessage struct {
+	payload  map[string]interface{}
+	firstAt  time.Time // 初回送信時刻 (遅延計測の起点)
+	lastAt   time.Time // 最後に送信した時刻 (再送判定の起点)
+	attempts int       // 送信回数 (初回を含む)
+}
+
+// unityClient: ルームの Unity 接続と配信状況
+// 送信メッセージすべてに接続内で単調増加する seq を付け、ack が返るまで保持して再送する。
+type unityClient struct {
+	conn         *websocket.Conn
+	connectedAt  time.Time
+	writeTimeout time.Duration // 1回の書き込みの期限 (相手が受信しない接続で送信側が詰まらないように)
+
+	writeMu sync.Mutex // 送信と書き込み期限の設定を直列化
+
+	mu       sync.Mutex
+	nextSeq  uint64
+	pending  map[uint64]*pendingMessage
+	lastSeen time.Time     // 最後に Unity からメッセージを受信した時刻
+	pingRTT  time.Duration // 直近の ping → pong の往復時間
+
+	// 配信統計
+	acked       int64
+	resent      int64
+	dropped     int64         // 再送上限に達して諦めた件数
+	lastLatency time.Duration // 直近の ack までの時間
+	avgLatency  time.Duration // ack までの時間の指数移動平均
+}
+
+// UnityClientStats: /clients で返すルームごとの配信状況
+type UnityClientStats struct {
+	RoomID          string    `json:"room_id"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	LastSeq         uint64    `json:"last_seq"`
+	Unacked         int       `json:"unacked"`
+	OldestUnackedMs int64     `json:"oldest_unacked_ms"`
+	Acked           int64     `json:"acked"`
+	Resent          int64     `json:"resent"`
+	Dropped         int64     `json:"dropped"`
+	LastLatencyMs   int64     `json:"last_latency_ms"`
+	AvgLatencyMs    int64     `json:"avg_latency_ms"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+	PingRTTMs       int64     `json:"ping_rtt_ms"`
+}
+
+func newUnityClient(conn *websocket.Conn, writeTimeout time.Duration) *unityClient {
+	now := time.Now()
+	return &unityClient{
+		conn:         conn,
+		connectedAt:  now,
+		writeTimeout: writeTimeout,
+		pending:      make(map[uint64]*pendingMessage),
+		lastSeen:     now,
+	}
+}
+
+// write: 書き込み期限を付けて JSON を送信
+// 期限切れ後の接続は使えないため、失敗した場合は接続を閉じて受信ループを終わらせる。
+func (u *unityClient) write(msg interface{}) error {
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	if u.writeTimeout > 0 {
+		if err := u.conn.SetWriteDeadline(time.Now().Add(u.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	if err := websocket.JSON.Send(u.conn, msg); err != nil {
+		u.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// writeMessage: seq を付けずにメッセージを送信 (ping / pong / error など ack 不要なもの)
+func (u *unityClient) writeMessage(msg protocol.Message) error {
+	fields, err := protocol.Fields(msg)
+	if err != nil {
+		return err
+	}
+	return u.write(fields)
+}
+
+// ping: 死活確認用の ping を送信 (seq を付けず ack 待ちにも登録しない)
+func (u *unityClient) ping() error {
+	return u.writeMessage(protocol.Ping{TS: time.Now().UnixMilli()})
+}
+
+// pong: Unity からの pong で往復時間を記録 (ts は ping で送った値をそのまま返してもらう)
+func (u *unityClient) pong(ts int64) {
+	if ts <= 0 {
+		return
+	}
+	rtt := time.Since(time.UnixMilli(ts))
+	if rtt < 0 {
+		return
+	}
+	u.mu.Lock()
+	u.pingRTT = rtt
+	u.mu.Unlock()
+}
+
+// touch: Unity からの受信を記録
+func (u *unityClient) touch() {
+	u.mu.Lock()
+	u.lastSeen = time.Now()
+	u.mu.Unlock()
+}
+
+// send: seq を付けて送信し、ack 待ちに登録 (送信に失敗した場合は登録しない)
+func (u *unityClient) send(payload map[string]interface{}) (uint64, error) {
+	msg := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		msg[k] = v
+	}
+	now := time.Now()
+	u.mu.Lock()
+	u.nextSeq++
+	seq := u.nextSeq
+	msg["seq"] = seq
+	u.pending[seq] = &pendingMessage{payload: msg, firstAt: now, lastAt: now, attempts: 1}
+	u.mu.Unlock()
+
+	if err := u.write(msg); err != nil {
+		u.mu.Lock()
+		delete(u.pending, seq)
+		u.mu.Unlock()
+		return 0, fmt.Errorf("send failed: %v", err)
+	}
+	return seq, nil
+}
+
+// ack: seq の ack 待ちを処理済みにして送信内容を返す (未登録 / ack 済みなら nil)
+// 再送で順序が入れ替わるため累積 ack にはしない。
+func (u *unityClient) ack(seq uint64) map[string]interface{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	p, ok := u.pending[seq]
+	if !ok {
+		return nil
+	}
+	latency := time.Since(p.firstAt)
+	u.lastLatency = latency
+	if u.avgLatency == 0 {
+		u.avgLatency = latency
+	} else {
+		u.avgLatency = (u.avgLatency*7 + latency) / 8
+	}
+	u.acked++
+	delete(u.pending, seq)
+	return p.payload
+}
+
+// due: timeout を過ぎた ack 待ちを再送対象として返す (maxResends を超えたものは破棄して dropped に返す)
+func (u *unityClient) due(timeout time.Duration, maxResends int) (resend []map[string]interface{}, dropped []map[string]interface{}) {
+	now := time.Now()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for s, p := range u.pending {
+		if now.Sub(p.lastAt) < timeout {
+			continue
+		}
+		if p.attempts > maxResends {
+			u.dropped++
+			dropped = append(dropped, p.payload)
+			delete(u.pending, s)
+			continue
+		}
+		p.attempts++
+		p.lastAt = now
+		u.resent++
+		resend = append(resend, p.payload)
+	}
+	return resend, dropped
+}
+
+// resend: ack 待ちのメッセージを同じ seq のまま再送 (resend フラグ付き)
+func (u *unityClient) resend(payload map[string]interface{}) error {
+	msg := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		msg[k] = v
+	}
+	msg["resend"] = true
+	if err := u.write(msg); err != nil {
+		return fmt.Errorf("resend failed: %v", err)
+	}
+	return nil
+}
+
+// takeOver: 置き換え前の接続の ack 待ちと seq を引き継ぐ (再接続時に新しい接続で再送する)
+func (u *unityClient) takeOver(old *unityClient) {
+	old.mu.Lock()
+	pending := old.pending
+	nextSeq := old.nextSeq
+	old.pending = make(map[uint64]*pendingMessage)
+	old.mu.Unlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if nextSeq > u.nextSeq {
+		u.nextSeq = nextSeq
+	}
+	for s, p := range pending {
+		p.lastAt = time.Time{} // 次の再送チェックで即時に送る
+		u.pending[s] = p
+	}
+}
+
+func (u *unityClient) stats(roomID string) UnityClientStats {
+	now := time.Now()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var oldest time.Duration
+	for _, p := range u.pending {
+		if age := now.Sub(p.firstAt); age > oldest {
+			oldest = age
+		}
+	}
+	return UnityClientStats{
+		RoomID:          roomID,
+		ConnectedAt:     u.connectedAt,
+		LastSeq:         u.nextSeq,
+		Unacked:         len(u.pending),
+		OldestUnackedMs: oldest.Milliseconds(),
+		Acked:           u.acked,
+		Resent:          u.resent,
+		Dropped:         u.dropped,
+		LastLatencyMs:   u.lastLatency.Milliseconds(),
+		AvgLatencyMs:    u.avgLatency.Milliseconds(),
+		LastSeenAt:      u.lastSeen,
+		PingRTTMs:       u.pingRTT.Milliseconds(),
+	}
+}
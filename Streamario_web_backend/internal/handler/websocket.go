@@ -3,57 +3,56 @@ package handler
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/net/websocket"
 
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
 	"streamerrio-backend/internal/service"
+	"streamerrio-backend/pkg/protocol"
 	"streamerrio-backend/pkg/pubsub"
 
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
 )
 
-// WebSocketMessageType: WebSocket通信で使用するメッセージタイプ
-// Unity ↔ Backend 間の通信で使用されるメッセージタイプを定義
-type WebSocketMessageType string
-
-const (
-	// UnityからBackendへ送信されるメッセージタイプ
-
-	// MessageTypeGameStart: ゲーム開始通知
-	// Unityから送信され、ゲームセッションの開始を通知
-	MessageTypeGameStart WebSocketMessageType = "game_start"
-
-	// MessageTypeGameEnd: ゲーム終了通知
-	// Unityから送信され、ゲームセッションの終了を通知
-	MessageTypeGameEnd WebSocketMessageType = "game_end"
-
-	// BackendからUnityへ送信されるメッセージタイプ
-
-	// MessageTypeRoomCreated: ルーム作成通知
-	// 新規接続時にBackendからUnityへ送信される
-	MessageTypeRoomCreated WebSocketMessageType = "room_created"
-
-	// MessageTypeRoomReady: ルーム準備完了通知
-	// 再接続時にBackendからUnityへ送信される
-	MessageTypeRoomReady WebSocketMessageType = "room_ready"
-)
+// unityConsumer: Unity クライアントの ack 位置を記録する consumer 名 (ルームごとに Unity は1接続)
+const unityConsumer = "unity"
 
 type WebSocketHandler struct {
-	connections    map[string]*websocket.Conn
-	mu             sync.RWMutex
-	roomService    *service.RoomService
-	sessionService *service.GameSessionService
-	pubsub         pubsub.PubSub
-	logger         *slog.Logger
-	ulidEntropy    io.Reader
+	connections     map[string]*unityClient
+	mu              sync.RWMutex
+	roomService     *service.RoomService
+	sessionService  *service.GameSessionService
+	triggerRepo     repository.TriggerRepository // Unity へ送信した game_event の配信状況を記録
+	pubsub          pubsub.PubSub
+	subMu           sync.Mutex                      // ルームチャネルの購読変更を直列化
+	roomSub         pubsub.Subscription             // 接続中ルームの room:{id}:events 購読 (StartPubSubSubscription 中のみ)
+	replayLimit     int64                           // 再接続時に再送する最大件数 (Durable な PubSub のみ)
+	ackTimeout      time.Duration                   // ack が返らない場合に再送するまでの時間
+	maxResends      int                             // 再送の上限回数 (超えたら諦める)
+	pingInterval    time.Duration                   // Unity へ ping を送る間隔
+	idleTimeout     time.Duration                   // この時間 Unity から何も受信しなければ接続を切断する
+	writeTimeout    time.Duration                   // Unity への1回の書き込みの期限
+	roomTokens      *service.RoomTokenService       // 再接続時の所有証明 (未設定なら検証しない)
+	allowedOrigins  []string                        // 接続を許可する Origin (空 / "*" なら全許可)
+	relayAdminToken string                          // relay を任意のルームに実行できる管理者トークン (空なら無効)
+	relayRegistry   *service.RelayMessageRegistry   // relay で送れるメッセージタイプとスキーマ
+	relayAudit      repository.RelayAuditRepository // relay の監査ログ
+	streamers       *service.StreamerService        // 配信者の API キー認証 (未設定なら認証しない)
+	requireStreamer bool                            // API キーの無い接続を拒否する
+	logger          *slog.Logger
+	ulidEntropy     io.Reader
 }
 
 func NewWebSocketHandler(ps pubsub.PubSub, logger *slog.Logger) *WebSocketHandler {
@@ -61,10 +60,16 @@ func NewWebSocketHandler(ps pubsub.PubSub, logger *slog.Logger) *WebSocketHandle
 		logger = slog.Default()
 	}
 	return &WebSocketHandler{
-		connections: make(map[string]*websocket.Conn),
-		pubsub:      ps,
-		logger:      logger,
-		ulidEntropy: ulid.Monotonic(rand.Reader, 0),
+		connections:  make(map[string]*unityClient),
+		pubsub:       ps,
+		replayLimit:  100,
+		ackTimeout:   3 * time.Second,
+		maxResends:   3,
+		pingInterval: 15 * time.Second,
+		idleTimeout:  45 * time.Second,
+		writeTimeout: 10 * time.Second,
+		logger:       logger,
+		ulidEntropy:  ulid.Monotonic(rand.Reader, 0),
 	}
 }
 
@@ -73,7 +78,23 @@ func NewWebSocketHandler(ps pubsub.PubSub, logger *slog.Logger) *WebSocketHandle
 func (h *WebSocketHandler) HandleUnityConnection(c echo.Context) error {
 	s := websocket.Server{
 		Handshake: func(cfg *websocket.Config, r *http.Request) error {
-			// 全オリジン許可（必要ならここで厳密にチェック）
+			// エラーを返すと 403 で接続を拒否する
+			if err := h.checkOrigin(r); err != nil {
+				h.logger.Warn("unity handshake rejected", slog.String("remote_addr", r.RemoteAddr), slog.String("origin", r.Header.Get("Origin")), slog.Any("error", err))
+				return err
+			}
+			streamer, err := h.authenticateStreamer(r)
+			if err != nil {
+				h.logger.Warn("unity handshake rejected", slog.String("remote_addr", r.RemoteAddr), slog.Any("error", err))
+				return err
+			}
+			if err := h.checkRoomAccess(r, streamer); err != nil {
+				h.logger.Warn("unity handshake rejected", slog.String("remote_addr", r.RemoteAddr), slog.String("room_id", r.URL.Query().Get("room_id")), slog.Any("error", err))
+				return err
+			}
+			if streamer != nil {
+				c.Set(streamerContextKey, streamer)
+			}
 			return nil
 		},
 		Handler: func(ws *websocket.Conn) {
@@ -82,75 +103,126 @@ func (h *WebSocketHandler) HandleUnityConnection(c echo.Context) error {
 			// 接続登録（再接続の場合は同一 room_id を維持）
 			requestedID := c.QueryParam("room_id")
 			var id string
+			var client *unityClient
 			if requestedID != "" {
-				id = h.registerWithID(requestedID, ws, c)
+				id, client = h.registerWithID(requestedID, ws, c)
 			} else {
-				id = h.registerNew(ws, c)
+				id, client = h.registerNew(ws, c)
 			}
-			defer h.unregister(id, ws, c)
-			defer h.roomService.MarkEnded(id, time.Now())
+			// 切断 (無応答による切断を含む) したらルームを切断状態にし、再接続を待つ
+			// 再接続で別の接続に置き換わっている場合はルームを使い続けているので触らない
+			defer func() {
+				if h.unregister(id, ws, c) {
+					h.markRoomDisconnected(id)
+				}
+			}()
+
+			// ack が返らないメッセージの再送と ping 送信 (接続が閉じたら停止)
+			watchCtx, stopWatch := context.WithCancel(context.Background())
+			defer stopWatch()
+			go h.watchAcks(watchCtx, id, client)
+			go h.keepAlive(watchCtx, id, client)
 
 			// 接続直後に必ずログを出す
 			c.Logger().Infof("Client connected: %s id=%s", c.Request().RemoteAddr, id)
 
-			// 初期メッセージ（再接続時はタイプのみ変える）
-			initType := MessageTypeRoomCreated
-			if requestedID != "" {
-				initType = MessageTypeRoomReady
-			}
-			payload := map[string]interface{}{
-				"type":    string(initType),
-				"room_id": id,
+			// 初期メッセージ（再接続時は room_ready）
+			// 再接続時に提示するトークンはルーム作成時にだけ渡す
+			var initMsg protocol.Message = protocol.RoomReady{RoomID: id}
+			if requestedID == "" {
+				created := protocol.RoomCreated{RoomID: id}
+				if h.roomTokens != nil {
+					created.RoomToken = h.roomTokens.Issue(id)
+				}
+				initMsg = created
 			}
-			if err := h.SendEventToUnity(id, payload); err != nil {
+			if err := h.SendToUnity(id, initMsg); err != nil {
 				c.Logger().Errorf("initial send failed: %v", err)
 				return
 			}
+			// 再接続時は切断中に取りこぼした game_event を再送
+			if requestedID != "" {
+				h.replayUndelivered(id)
+			}
 
 			for {
 				// Client からのメッセージを読み込む
+				// idleTimeout の間に何も届かなければ半開きの接続とみなして切断する
+				if err := ws.SetReadDeadline(time.Now().Add(h.idleTimeout)); err != nil {
+					c.Logger().Errorf("set read deadline failed id=%s err=%v", id, err)
+					return
+				}
 				msg := ""
 				err := websocket.Message.Receive(ws, &msg)
 
 				// エラー処理
 				// メッセージを受信できなかった場合は、接続を切断する
 				if err != nil {
-					if err == io.EOF {
+					var netErr net.Error
+					switch {
+					case err == io.EOF:
 						c.Logger().Infof("Client disconnected id=%s", id)
-					} else {
+					case errors.As(err, &netErr) && netErr.Timeout():
+						h.logger.Warn("unity connection evicted (idle timeout)",
+							slog.String("room_id", id),
+							slog.Duration("idle_timeout", h.idleTimeout))
+					default:
 						c.Logger().Errorf("receive failed: %v", err)
 					}
 					return
 				}
+				client.touch()
 
-				// 受信したメッセージをログに出力
-				c.Logger().Infof("message received id=%s msg=%s", id, msg)
-
-				var incoming struct {
-					Type string `json:"type"`
+				// 読めないメッセージ / 未知のタイプには理由を error で返す (接続は維持する)
+				_, incoming, err := protocol.DecodeDirection([]byte(msg), protocol.DirectionToBackend)
+				if err != nil {
+					var de *protocol.DecodeError
+					if !errors.As(err, &de) {
+						de = &protocol.DecodeError{Code: protocol.ErrCodeInvalidMessage, Err: err}
+					}
+					h.logger.Warn("invalid message from unity", slog.String("room_id", id), slog.String("code", de.Code), slog.String("msg", msg), slog.Any("error", err))
+					if err := client.writeMessage(de.Reply()); err != nil {
+						c.Logger().Errorf("error reply send failed id=%s err=%v", id, err)
+						return
+					}
+					continue
 				}
-				if err := json.Unmarshal([]byte(msg), &incoming); err != nil {
-					c.Logger().Warnf("json unmarshal failed id=%s msg=%s err=%v", id, msg, err)
+
+				// 死活確認は頻繁に届くためログに出さない
+				switch m := incoming.(type) {
+				case *protocol.Ping:
+					if err := client.writeMessage(protocol.Pong{TS: m.TS}); err != nil {
+						c.Logger().Errorf("pong send failed id=%s err=%v", id, err)
+						return
+					}
+					continue
+				case *protocol.Pong:
+					client.pong(m.TS)
 					continue
 				}
 
-				// 文字列をWebSocketMessageTypeに変換
-				messageType := WebSocketMessageType(incoming.Type)
+				// 受信したメッセージをログに出力
+				c.Logger().Infof("message received id=%s msg=%s", id, msg)
 
-				switch messageType {
-				case MessageTypeGameStart:
+				switch m := incoming.(type) {
+				case *protocol.GameStart:
 					c.Logger().Infof("game start received id=%s", id)
 					if h.roomService == nil {
 						c.Logger().Warn("game_start received but roomService not set")
 						continue
 					}
-					if err := h.roomService.MarkInGame(id); err != nil {
+					if h.sessionService == nil {
+						c.Logger().Warn("game_start received but sessionService not set")
+						continue
+					}
+					if round, err := h.sessionService.StartGame(id); err != nil {
 						c.Logger().Errorf("mark in_game failed id=%s err=%v", id, err)
+						h.replyStateError(client, protocol.TypeGameStart, err)
 					} else {
-						c.Logger().Infof("room marked as in_game id=%s", id)
+						c.Logger().Infof("room marked as in_game id=%s round=%d", id, round)
 					}
 
-				case MessageTypeGameEnd:
+				case *protocol.GameEnd:
 					c.Logger().Infof("game end received id=%s", id)
 					if h.sessionService == nil {
 						c.Logger().Warn("game_end received but sessionService not set")
@@ -158,10 +230,45 @@ func (h *WebSocketHandler) HandleUnityConnection(c echo.Context) error {
 					}
 					if _, err := h.sessionService.EndGame(id); err != nil {
 						c.Logger().Errorf("game end handling failed id=%s err=%v", id, err)
+						h.replyStateError(client, protocol.TypeGameEnd, err)
+					}
+
+				case *protocol.GamePause:
+					c.Logger().Infof("game pause received id=%s", id)
+					if h.sessionService == nil {
+						c.Logger().Warn("game_pause received but sessionService not set")
+						continue
+					}
+					if err := h.sessionService.PauseGame(id); err != nil {
+						c.Logger().Errorf("mark paused failed id=%s err=%v", id, err)
+						h.replyStateError(client, protocol.TypeGamePause, err)
+					} else {
+						c.Logger().Infof("room marked as paused id=%s", id)
 					}
+
+				case *protocol.GameResume:
+					c.Logger().Infof("game resume received id=%s", id)
+					if h.sessionService == nil {
+						c.Logger().Warn("game_resume received but sessionService not set")
+						continue
+					}
+					if err := h.sessionService.ResumeGame(id); err != nil {
+						c.Logger().Errorf("mark resumed failed id=%s err=%v", id, err)
+						h.replyStateError(client, protocol.TypeGameResume, err)
+					} else {
+						c.Logger().Infof("room resumed id=%s", id)
+					}
+
+				case *protocol.Ack:
+					h.handleAck(id, client, m.Seq, m.EventID)
 				default:
-					c.Logger().Warn("unhandled message type", slog.String("type", incoming.Type))
-					continue
+					// 定義上は Unity から送れるが、このサーバでは処理しないタイプ
+					h.logger.Warn("unhandled message type", slog.String("room_id", id), slog.String("type", string(incoming.MessageType())))
+					reply := protocol.Error{Code: protocol.ErrCodeUnexpectedType, Message: "message type is not handled", RefType: string(incoming.MessageType())}
+					if err := client.writeMessage(reply); err != nil {
+						c.Logger().Errorf("error reply send failed id=%s err=%v", id, err)
+						return
+					}
 				}
 			}
 		},
@@ -171,158 +278,623 @@ func (h *WebSocketHandler) HandleUnityConnection(c echo.Context) error {
 	return nil
 }
 
-func (h *WebSocketHandler) RelayActionToUnity(c echo.Context) error {
-	// リクエストボディをそのまま JSON として受け取り、Unity へ転送する
-	var payload map[string]interface{}
-	if err := c.Bind(&payload); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": fmt.Sprintf("invalid json: %v", err)})
+// replyStateError: 現在のルーム状態では受け付けられない通知に error で応答 (それ以外の失敗は応答しない)
+func (h *WebSocketHandler) replyStateError(client *unityClient, refType protocol.Type, err error) {
+	if !errors.Is(err, service.ErrInvalidRoomTransition) && !errors.Is(err, service.ErrRoomStateConflict) {
+		return
+	}
+	reply := protocol.Error{Code: protocol.ErrCodeInvalidState, Message: err.Error(), RefType: string(refType)}
+	if err := client.writeMessage(reply); err != nil {
+		h.logger.Warn("error reply send failed", slog.Any("error", err))
+	}
+}
+
+// SetRoomService: 後から RoomService を注入
+func (h *WebSocketHandler) SetRoomService(rs *service.RoomService) { h.roomService = rs }
+
+// SetGameSessionService: ゲーム終了処理サービスを注入
+func (h *WebSocketHandler) SetGameSessionService(gs *service.GameSessionService) {
+	h.sessionService = gs
+}
+
+// SetReplayLimit: 再接続時に再送する最大件数を設定
+func (h *WebSocketHandler) SetReplayLimit(n int) {
+	if n > 0 {
+		h.replayLimit = int64(n)
 	}
+}
 
-	// room_idが含まれているか確認
-	roomID, ok := payload["room_id"].(string)
-	if !ok || roomID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "room_id is required"})
+// SetAckPolicy: ack 待ちの再送間隔と再送上限を設定
+func (h *WebSocketHandler) SetAckPolicy(timeout time.Duration, maxResends int) {
+	if timeout > 0 {
+		h.ackTimeout = timeout
+	}
+	if maxResends >= 0 {
+		h.maxResends = maxResends
 	}
+}
 
-	// room_id を除去して転送用のペイロードを作成
-	forward := make(map[string]interface{}, len(payload))
-	for k, v := range payload {
-		if k == "room_id" {
-			continue
+// SetHeartbeat: ping の送信間隔・無応答で切断するまでの時間・書き込み期限を設定
+// idleTimeout は ping の取りこぼしを許容できるよう pingInterval より十分長くすること。
+func (h *WebSocketHandler) SetHeartbeat(pingInterval, idleTimeout, writeTimeout time.Duration) {
+	if pingInterval > 0 {
+		h.pingInterval = pingInterval
+	}
+	if idleTimeout > 0 {
+		h.idleTimeout = idleTimeout
+	}
+	if writeTimeout > 0 {
+		h.writeTimeout = writeTimeout
+	}
+	if h.idleTimeout <= h.pingInterval {
+		h.logger.Warn("idle timeout is not longer than ping interval, connections may be evicted",
+			slog.Duration("ping_interval", h.pingInterval),
+			slog.Duration("idle_timeout", h.idleTimeout))
+	}
+}
+
+// SetRoomTokenService: ルームトークンの発行 / 検証を有効化 (room_id 指定の接続にはトークンが必要になる)
+func (h *WebSocketHandler) SetRoomTokenService(ts *service.RoomTokenService) {
+	h.roomTokens = ts
+}
+
+// SetAllowedOrigins: 接続を許可する Origin を設定 ("*" を含めば全許可)
+func (h *WebSocketHandler) SetAllowedOrigins(origins []string) {
+	h.allowedOrigins = origins
+}
+
+// checkOrigin: Origin ヘッダが許可リストにあるか確認
+// Unity (ネイティブ) など Origin を送らないクライアントは許可する。
+func (h *WebSocketHandler) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(h.allowedOrigins) == 0 {
+		return nil
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin not allowed: %s", origin)
+}
+
+// SetStreamerAuth: 配信者の API キー認証を有効化 (required なら API キーの無い接続を拒否する)
+func (h *WebSocketHandler) SetStreamerAuth(svc *service.StreamerService, required bool) {
+	h.streamers = svc
+	h.requireStreamer = required
+}
+
+// authenticateStreamer: API キー (クエリ api_key または X-API-Key ヘッダ) から配信者を特定
+// キーが無ければ nil (必須設定なら拒否)、あっても無効なら拒否する。
+func (h *WebSocketHandler) authenticateStreamer(r *http.Request) (*model.Streamer, error) {
+	if h.streamers == nil {
+		return nil, nil
+	}
+	key := r.URL.Query().Get("api_key")
+	if key == "" {
+		key = r.Header.Get("X-API-Key")
+	}
+	if key == "" {
+		if h.requireStreamer {
+			return nil, errors.New("streamer api key required")
 		}
-		forward[k] = v
+		return nil, nil
 	}
+	return h.streamers.Authenticate(key)
+}
 
-	// Unity へ送信
-	if err := h.SendEventToUnity(roomID, forward); err != nil {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+// checkRoomAccess: room_id を指定した接続 (再接続) の所有確認
+// 配信者として認証した場合は自分のルームならトークン不要、他の配信者のルームは拒否する。
+// それ以外はルームトークンを検証する。新規接続 (room_id なし) は検証しない。
+func (h *WebSocketHandler) checkRoomAccess(r *http.Request, streamer *model.Streamer) error {
+	roomID := r.URL.Query().Get("room_id")
+	if roomID == "" {
+		return nil
+	}
+	if streamer != nil && h.roomService != nil {
+		room, err := h.roomService.FindRoom(roomID)
+		if err != nil {
+			return err
+		}
+		if room != nil && room.StreamerID == streamer.ID {
+			return nil
+		}
+		if room != nil && room.StreamerID != model.UnityAnonymousStreamerID {
+			return errors.New("room owned by another streamer")
+		}
 	}
+	return h.checkRoomToken(r)
+}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{"status": "ok"})
+// checkRoomToken: room_id を指定した接続 (再接続) ではルームトークンを検証
+// トークンはクエリ room_token または Authorization: Bearer で受け取る。新規接続 (room_id なし) は検証しない。
+func (h *WebSocketHandler) checkRoomToken(r *http.Request) error {
+	roomID := r.URL.Query().Get("room_id")
+	if roomID == "" || h.roomTokens == nil {
+		return nil
+	}
+	token := r.URL.Query().Get("room_token")
+	if token == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	if token == "" {
+		return errors.New("room token required")
+	}
+	if !h.roomTokens.Verify(roomID, token) {
+		return errors.New("invalid room token")
+	}
+	return nil
 }
 
-// SetRoomService: 後から RoomService を注入
-func (h *WebSocketHandler) SetRoomService(rs *service.RoomService) { h.roomService = rs }
+// SetTriggerRepository: 発動履歴の配信状況更新先を注入
+func (h *WebSocketHandler) SetTriggerRepository(repo repository.TriggerRepository) {
+	h.triggerRepo = repo
+}
 
-// SetGameSessionService: ゲーム終了処理サービスを注入
-func (h *WebSocketHandler) SetGameSessionService(gs *service.GameSessionService) {
-	h.sessionService = gs
+// streamerIDOf: 接続した配信者の ID (API キーなしの接続は model.UnityAnonymousStreamerID)
+func streamerIDOf(c echo.Context) string {
+	if streamer, ok := c.Get(streamerContextKey).(*model.Streamer); ok && streamer != nil {
+		return streamer.ID
+	}
+	return model.UnityAnonymousStreamerID
 }
 
 // registerNew: 新規接続用に新しい roomID を払い出して登録
-func (h *WebSocketHandler) registerNew(ws *websocket.Conn, c echo.Context) string {
+func (h *WebSocketHandler) registerNew(ws *websocket.Conn, c echo.Context) (string, *unityClient) {
 	id := ulid.MustNew(ulid.Timestamp(time.Now()), h.ulidEntropy).String()
 
 	if h.roomService != nil {
-		if err := h.roomService.CreateIfNotExists(id, "unity"); err != nil {
+		// 閾値戦略はルーム作成時にのみ指定可能 (未指定ならサーバ既定)
+		if err := h.roomService.CreateIfNotExists(id, streamerIDOf(c), c.QueryParam("threshold_strategy")); err != nil {
 			c.Logger().Errorf("room db create failed id=%s err=%v", id, err)
 		} else {
 			c.Logger().Infof("room db created id=%s", id)
 		}
+		h.touchUnityPresence([]string{id})
 	}
 
+	client := newUnityClient(ws, h.writeTimeout)
 	h.mu.Lock()
-	h.connections[id] = ws
+	h.connections[id] = client
 	h.mu.Unlock()
-	return id
+	h.syncRoomSubscription(id)
+	return id, client
 }
 
 // registerWithID: 指定 roomID で接続を登録（再接続時）
-// 既存接続がある場合は置き換える
-func (h *WebSocketHandler) registerWithID(id string, ws *websocket.Conn, c echo.Context) string {
+// 既存接続がある場合は置き換え、ack 待ちのメッセージを新しい接続で再送する
+func (h *WebSocketHandler) registerWithID(id string, ws *websocket.Conn, c echo.Context) (string, *unityClient) {
 	// 既存の DB レコードは触らない（既に存在している前提）。無い場合のみ作成。
 	if h.roomService != nil {
-		if err := h.roomService.CreateIfNotExists(id, "unity"); err != nil {
+		if err := h.roomService.CreateIfNotExists(id, streamerIDOf(c), c.QueryParam("threshold_strategy")); err != nil {
 			c.Logger().Errorf("room db ensure failed id=%s err=%v", id, err)
 		}
 	}
 
+	client := newUnityClient(ws, h.writeTimeout)
 	h.mu.Lock()
-	h.connections[id] = ws
+	old := h.connections[id]
+	if old != nil {
+		client.takeOver(old)
+	}
+	h.connections[id] = client
 	h.mu.Unlock()
+	if old != nil {
+		// 古い接続は半開きのまま残っていることがあるため閉じて受信ループを終わらせる
+		old.conn.Close()
+	}
+	h.syncRoomSubscription(id)
 	c.Logger().Infof("room re-registered id=%s", id)
-	return id
+	if h.roomService != nil {
+		// 接続確認を先に更新し、再接続直後にジャニターが Unity 不在と判定しないようにする
+		h.touchUnityPresence([]string{id})
+		if resumed, err := h.roomService.MarkReconnected(id); err != nil {
+			c.Logger().Errorf("room reconnect update failed id=%s err=%v", id, err)
+		} else if resumed {
+			h.logger.Info("room resumed after reconnect", slog.String("room_id", id))
+		}
+	}
+	return id, client
 }
 
 // unregister: 接続が同一の場合のみ削除（置換時の誤削除防止）
-func (h *WebSocketHandler) unregister(id string, ws *websocket.Conn, c echo.Context) {
+// 削除した場合 true を返す
+func (h *WebSocketHandler) unregister(id string, ws *websocket.Conn, c echo.Context) bool {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	cur := h.connections[id]
-	if cur == ws {
-		delete(h.connections, id)
-		c.Logger().Infof("Client unregistered id=%s", id)
-	} else {
+	if cur == nil || cur.conn != ws {
+		h.mu.Unlock()
 		// すでに別の接続に置き換わっている
 		c.Logger().Infof("Skip unregister (replaced) id=%s", id)
+		return false
+	}
+	delete(h.connections, id)
+	h.mu.Unlock()
+	c.Logger().Infof("Client unregistered id=%s", id)
+	h.syncRoomSubscription(id)
+	return true
+}
+
+// markRoomDisconnected: Unity が切断したルームを切断状態にする (猶予が 0 なら即終了)
+// 猶予内に同じ room_id で再接続されなければ ExpireDisconnectedRooms が終了状態にする。
+func (h *WebSocketHandler) markRoomDisconnected(id string) {
+	if h.roomService == nil {
+		return
+	}
+	now := time.Now()
+	grace := h.roomService.ReconnectGrace()
+	if grace <= 0 {
+		if err := h.roomService.MarkEnded(id, now, model.RoomTransitionClosed); err != nil {
+			h.logger.Error("mark ended failed", slog.String("room_id", id), slog.Any("error", err))
+		}
+		return
+	}
+	changed, err := h.roomService.MarkDisconnected(id, now)
+	if err != nil {
+		h.logger.Error("mark disconnected failed", slog.String("room_id", id), slog.Any("error", err))
+		return
+	}
+	if changed {
+		h.logger.Info("room disconnected, waiting for reconnect", slog.String("room_id", id), slog.Duration("grace", grace))
+	}
+}
+
+// ExpireDisconnectedRooms: 再接続猶予を過ぎた切断中ルームを定期的に終了状態にする (ctx がキャンセルされるまでブロック)
+// 判定は DB 上の切断時刻で行うため、切断を受けたインスタンスが再起動していても終了させられる。
+func (h *WebSocketHandler) ExpireDisconnectedRooms(ctx context.Context) {
+	if h.roomService == nil {
+		return
+	}
+	grace := h.roomService.ReconnectGrace()
+	if grace <= 0 {
+		return
+	}
+	interval := grace / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > 15*time.Second {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ids, err := h.roomService.ExpireDisconnected(time.Now())
+			if err != nil {
+				h.logger.Warn("expire disconnected rooms failed", slog.Any("error", err))
+				continue
+			}
+			for _, id := range ids {
+				h.logger.Info("room ended after reconnect grace", slog.String("room_id", id), slog.Duration("grace", grace))
+			}
+		}
+	}
+}
+
+// ReportUnityPresence: 接続中のルームの Unity 接続確認 (rooms.unity_seen_at) を定期的に更新する (ctx がキャンセルされるまでブロック)
+// 更新が途絶えたルームは、このインスタンスが落ちたものとしてジャニターが切断状態にする。
+func (h *WebSocketHandler) ReportUnityPresence(ctx context.Context) {
+	if h.roomService == nil {
+		return
+	}
+	interval := h.roomService.PresenceTimeout() / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.mu.RLock()
+			ids := make([]string, 0, len(h.connections))
+			for id := range h.connections {
+				ids = append(ids, id)
+			}
+			h.mu.RUnlock()
+			h.touchUnityPresence(ids)
+		}
 	}
 }
 
-func (h *WebSocketHandler) SendEventToUnity(roomID string, payload interface{}) error {
+// touchUnityPresence: 指定ルームの Unity 接続確認を現在時刻に更新 (失敗はログのみ、次回の更新で回復する)
+func (h *WebSocketHandler) touchUnityPresence(ids []string) {
+	if h.roomService == nil || len(ids) == 0 {
+		return
+	}
+	if err := h.roomService.TouchUnityPresence(ids, time.Now()); err != nil {
+		h.logger.Warn("touch unity presence failed", slog.Int("rooms", len(ids)), slog.Any("error", err))
+	}
+}
 
-	// 排他制御
+// syncRoomSubscription: 接続の有無に合わせてルームチャネルを購読 / 解除
+// 登録と解除が並行しても最後の状態に揃うよう、subMu の中で接続状態を読み直す。
+func (h *WebSocketHandler) syncRoomSubscription(id string) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	if h.roomSub == nil {
+		return // 購読開始時に接続中のルームをまとめて購読する
+	}
 	h.mu.RLock()
-	defer h.mu.RUnlock()
+	_, connected := h.connections[id]
+	h.mu.RUnlock()
+
+	channel := pubsub.RoomEventsChannel(id)
+	ctx := context.Background()
+	if connected {
+		if err := h.roomSub.Subscribe(ctx, channel); err != nil {
+			h.logger.Error("room channel subscribe failed", slog.String("room_id", id), slog.Any("error", err))
+		}
+		return
+	}
+	if err := h.roomSub.Unsubscribe(ctx, channel); err != nil {
+		h.logger.Warn("room channel unsubscribe failed", slog.String("room_id", id), slog.Any("error", err))
+	}
+}
 
-	// 接続先にメッセージを送信
+// SendEventToUnity: ルームの Unity 接続へ seq 付きで送信し、ack 待ちに登録
+func (h *WebSocketHandler) SendEventToUnity(roomID string, payload map[string]interface{}) error {
+	h.mu.RLock()
 	client := h.connections[roomID]
+	h.mu.RUnlock()
 	if client == nil {
 		return fmt.Errorf("no websocket client for roomID=%s", roomID)
 	}
-	if err := websocket.JSON.Send(client, payload); err != nil {
-		return fmt.Errorf("send failed: %v", err)
+	_, err := client.send(payload)
+	return err
+}
+
+// SendToUnity: メッセージを type / version 付きで送信 (SendEventToUnity と同じく seq を付けて ack 待ちに登録)
+func (h *WebSocketHandler) SendToUnity(roomID string, msg protocol.Message) error {
+	fields, err := protocol.Fields(msg)
+	if err != nil {
+		return err
 	}
-	return nil
+	return h.SendEventToUnity(roomID, fields)
 }
 
+// ListClients: 接続中のルームと配信状況 (ack 待ち件数 / ack までの遅延) を返す
 func (h *WebSocketHandler) ListClients(c echo.Context) error {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
 	ids := make([]string, 0, len(h.connections))
-	for id := range h.connections {
+	clients := make(map[string]*unityClient, len(h.connections))
+	for id, client := range h.connections {
 		ids = append(ids, id)
+		clients[id] = client
 	}
-	return c.JSON(http.StatusOK, map[string]interface{}{"clients": ids})
+	h.mu.RUnlock()
+	sort.Strings(ids)
+
+	rooms := make([]UnityClientStats, 0, len(ids))
+	for _, id := range ids {
+		rooms = append(rooms, clients[id].stats(id))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"clients": ids, "rooms": rooms})
 }
 
-// StartPubSubSubscription: Pub/Sub購読を開始（別goroutineで実行）
-// REST APIからのイベントをUnityに配信する
-func (h *WebSocketHandler) StartPubSubSubscription(ctx context.Context) error {
-	handler := func(channel string, message []byte) error {
-		var payload map[string]interface{}
-		if err := json.Unmarshal(message, &payload); err != nil {
-			h.logger.Error("pubsub message unmarshal failed", slog.Any("error", err))
-			return err
+// watchAcks: ackTimeout ごとに ack 待ちを確認し、期限切れを再送 (上限を超えたものは破棄してログに残す)
+func (h *WebSocketHandler) watchAcks(ctx context.Context, roomID string, client *unityClient) {
+	interval := h.ackTimeout / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			resend, dropped := client.due(h.ackTimeout, h.maxResends)
+			for _, p := range dropped {
+				h.logger.Warn("unacked message dropped after resends",
+					slog.String("room_id", roomID),
+					slog.Any("seq", p["seq"]),
+					slog.String("type", fmt.Sprintf("%v", p["type"])))
+			}
+			for _, p := range resend {
+				if err := client.resend(p); err != nil {
+					h.logger.Warn("resend to unity failed", slog.String("room_id", roomID), slog.Any("seq", p["seq"]), slog.Any("error", err))
+					break
+				}
+				h.logger.Debug("resent unacked message", slog.String("room_id", roomID), slog.Any("seq", p["seq"]))
+			}
 		}
+	}
+}
 
-		// room_idを取得
-		roomID, ok := payload["room_id"].(string)
-		if !ok || roomID == "" {
-			h.logger.Warn("pubsub message missing room_id", slog.Any("payload", payload))
-			return fmt.Errorf("room_id not found in payload")
+// keepAlive: pingInterval ごとに ping を送信 (送信できなければ接続を閉じて受信ループを終わらせる)
+// 応答の有無は受信側の読み込み期限 (idleTimeout) で判定する。
+func (h *WebSocketHandler) keepAlive(ctx context.Context, roomID string, client *unityClient) {
+	ticker := time.NewTicker(h.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := client.ping(); err != nil {
+				h.logger.Warn("ping to unity failed, closing connection", slog.String("room_id", roomID), slog.Any("error", err))
+				client.conn.Close()
+				return
+			}
 		}
+	}
+}
 
-		// 自分が接続を持っている場合のみ配信
-		if err := h.SendEventToUnity(roomID, payload); err != nil {
-			// 接続がないのは正常（他のインスタンスが持っている）
-			h.logger.Debug("no local connection for room, skip delivery",
-				slog.String("room_id", roomID),
-				slog.String("event_type", fmt.Sprintf("%v", payload["event_type"])))
-			return nil
+// handleAck: Unity からの ack を記録 (発動履歴を delivered に更新し、event_id があればストリーム上の位置も進める)
+func (h *WebSocketHandler) handleAck(roomID string, client *unityClient, seq uint64, eventID string) {
+	if seq > 0 {
+		payload := client.ack(seq)
+		if payload == nil {
+			h.logger.Debug("ack for unknown seq", slog.String("room_id", roomID), slog.Uint64("seq", seq))
+		} else {
+			h.markTriggerDelivered(payload)
+			if eventID == "" {
+				eventID, _ = payload["event_id"].(string)
+			}
 		}
+	}
+	h.ackEvent(roomID, eventID)
+}
+
+// markTriggerDelivered: trigger_id 付きの game_event に Unity から ack が返ったら発動履歴を delivered に更新
+func (h *WebSocketHandler) markTriggerDelivered(payload map[string]interface{}) {
+	if h.triggerRepo == nil {
+		return
+	}
+	// JSON の数値は float64 でデコードされる
+	id, ok := payload["trigger_id"].(float64)
+	if !ok || id <= 0 {
+		return
+	}
+	if err := h.triggerRepo.UpdateStatus(int64(id), model.DeliveryStatusDelivered, time.Now()); err != nil {
+		h.logger.Warn("mark trigger delivered failed", slog.Int64("trigger_id", int64(id)), slog.Any("error", err))
+	}
+}
+
+// deliverPubSubMessage: Pub/Sub で受信したメッセージを Unity へ送信 (eventID があれば event_id として付与)
+func (h *WebSocketHandler) deliverPubSubMessage(eventID string, message []byte) error {
+	_, msg, err := protocol.Decode(message)
+	if err != nil {
+		h.logger.Error("pubsub message decode failed", slog.Any("error", err))
+		return err
+	}
+
+	// room_idを取得
+	rm, ok := msg.(protocol.RoomMessage)
+	if !ok {
+		h.logger.Warn("pubsub message missing room_id", slog.String("type", string(msg.MessageType())))
+		return fmt.Errorf("room_id not found in payload")
+	}
+	roomID := rm.MessageRoomID()
+	payload, err := protocol.Fields(msg)
+	if err != nil {
+		return err
+	}
+	if eventID != "" {
+		payload["event_id"] = eventID
+	}
 
-		h.logger.Info("event delivered to unity via pubsub",
+	// 自分が接続を持っている場合のみ配信
+	if err := h.SendEventToUnity(roomID, payload); err != nil {
+		// 接続がないのは正常（他のインスタンスが持っている）
+		h.logger.Debug("no local connection for room, skip delivery",
 			slog.String("room_id", roomID),
 			slog.String("event_type", fmt.Sprintf("%v", payload["event_type"])))
 		return nil
 	}
 
-	// 購読開始（ブロッキング）
-	h.logger.Info("starting pubsub subscription", slog.String("channel", pubsub.ChannelGameEvents))
-	if err := h.pubsub.Subscribe(ctx, pubsub.ChannelGameEvents, handler); err != nil {
+	h.logger.Info("event delivered to unity via pubsub",
+		slog.String("room_id", roomID),
+		slog.String("event_id", eventID),
+		slog.String("event_type", fmt.Sprintf("%v", payload["event_type"])))
+	return nil
+}
+
+// replayUndelivered: 最後に ack された位置より後の game_event を古い順に再送 (Durable な PubSub のみ)
+// 再接続直後はライブ購読と再送が重なることがあるため、Unity は event_id で重複を除くこと。
+func (h *WebSocketHandler) replayUndelivered(roomID string) {
+	durable, ok := h.pubsub.(pubsub.Durable)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	channel := pubsub.RoomEventsChannel(roomID)
+	lastID, err := durable.LastAcked(ctx, channel, unityConsumer)
+	if err != nil {
+		h.logger.Warn("load last acked event failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return
+	}
+	msgs, err := durable.Replay(ctx, channel, lastID, h.replayLimit)
+	if err != nil {
+		h.logger.Warn("replay events failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return
+	}
+	replayed := 0
+	for _, msg := range msgs {
+		header, event, err := protocol.Decode(msg.Payload)
+		// 視聴者数更新は最新値だけ意味があるため再送しない
+		if err != nil || header.Type != protocol.TypeGameEvent {
+			continue
+		}
+		payload, err := protocol.Fields(event)
+		if err != nil {
+			continue
+		}
+		payload["event_id"] = msg.ID
+		payload["replayed"] = true
+		if err := h.SendEventToUnity(roomID, payload); err != nil {
+			h.logger.Warn("replay send failed", slog.String("room_id", roomID), slog.String("event_id", msg.ID), slog.Any("error", err))
+			return
+		}
+		replayed++
+	}
+	h.logger.Info("replayed undelivered events", slog.String("room_id", roomID), slog.String("after_id", lastID), slog.Int("count", replayed))
+}
+
+// ackEvent: Unity からの ack を記録 (Durable な PubSub のみ)
+func (h *WebSocketHandler) ackEvent(roomID, eventID string) {
+	durable, ok := h.pubsub.(pubsub.Durable)
+	if !ok || eventID == "" {
+		return
+	}
+	if err := durable.Ack(context.Background(), pubsub.RoomEventsChannel(roomID), unityConsumer, eventID); err != nil {
+		h.logger.Warn("ack event failed", slog.String("room_id", roomID), slog.String("event_id", eventID), slog.Any("error", err))
+	}
+}
+
+// StartPubSubSubscription: Pub/Sub購読を開始（別goroutineで実行）
+// REST APIからのイベントをUnityに配信する
+// Unity 接続を持っているルームのチャネル (room:{id}:events) だけを購読し、接続の登録 / 解除に合わせて増減させる
+// Durable な PubSub (Redis Streams) の場合は event_id を付けて送り、Unity の ack で配信済み位置を記録する
+func (h *WebSocketHandler) StartPubSubSubscription(ctx context.Context) error {
+	// 購読開始（ctx がキャンセルされるまでブロック）
+	var sub pubsub.Subscription
+	var err error
+	if durable, ok := h.pubsub.(pubsub.Durable); ok {
+		sub, err = durable.MultiplexMessages(ctx, func(msg pubsub.Message) error {
+			return h.deliverPubSubMessage(msg.ID, msg.Payload)
+		})
+	} else {
+		sub, err = h.pubsub.Multiplex(ctx, func(channel string, message []byte) error {
+			return h.deliverPubSubMessage("", message)
+		})
+	}
+	if err != nil {
 		h.logger.Error("pubsub subscription failed", slog.Any("error", err))
 		return err
 	}
-	return nil
+
+	// 購読開始前に登録済みの接続分をまとめて購読
+	h.subMu.Lock()
+	h.roomSub = sub
+	h.mu.RLock()
+	channels := make([]string, 0, len(h.connections))
+	for id := range h.connections {
+		channels = append(channels, pubsub.RoomEventsChannel(id))
+	}
+	h.mu.RUnlock()
+	if err := sub.Subscribe(ctx, channels...); err != nil {
+		h.logger.Error("room channel subscribe failed", slog.Int("room_count", len(channels)), slog.Any("error", err))
+	}
+	h.subMu.Unlock()
+	h.logger.Info("pubsub subscription started", slog.Int("room_count", len(channels)))
+
+	<-ctx.Done()
+	h.subMu.Lock()
+	h.roomSub = nil
+	h.subMu.Unlock()
+	return sub.Close()
 }
@@ -4,6 +4,8 @@ import "time"
 
 type EventType string
 
+// 初期カタログに含まれるイベント種別
+// NOTE: 実際に有効なイベント種別はイベントカタログ (event_catalog テーブル / 設定ファイル) で決まる
 const (
 	SKILL1 EventType = "skill1"
 	SKILL2 EventType = "skill2"
@@ -13,8 +15,22 @@ const (
 	ENEMY3 EventType = "enemy3"
 )
 
-func ListEventTypes() []EventType {
-	return []EventType{SKILL1, SKILL2, SKILL3, ENEMY1, ENEMY2, ENEMY3}
+// EventCategory: イベント種別の分類 (結果画面のチーム別集計などに使用)
+type EventCategory string
+
+const (
+	EventCategorySkill EventCategory = "skill"
+	EventCategoryEnemy EventCategory = "enemy"
+	EventCategoryOther EventCategory = "other"
+)
+
+// Valid: 定義済みカテゴリかどうか
+func (c EventCategory) Valid() bool {
+	switch c {
+	case EventCategorySkill, EventCategoryEnemy, EventCategoryOther:
+		return true
+	}
+	return false
 }
 
 type Event struct {
@@ -26,32 +42,55 @@ type Event struct {
 	Metadata    string    `json:"metadata" db:"metadata"`
 }
 
+// EventRecord: events テーブルへ書き込む1リクエスト分の押下 (書き込みキュー経由でまとめて保存)
+type EventRecord struct {
+	RoomID      string
+	Round       int // 押下時点のラウンド
+	ViewerID    *string
+	Counts      map[EventType]int64 // 押下のあったイベント種別のみ
+	TriggeredAt time.Time
+}
+
+// EventConfig: イベントカタログの1エントリ (表示情報 + 閾値設定)
 type EventConfig struct {
-	EventType       EventType `json:"event_type"`
-	BaseThreshold   int       `json:"base_threshold"`
-	MinThreshold    int       `json:"min_threshold"`
-	MaxThreshold    int       `json:"max_threshold"`
-	LevelMultiplier float64   `json:"level_multiplier"` // 互換性のため残すが未使用
+	EventType       EventType     `json:"event_type" db:"event_type"`
+	Category        EventCategory `json:"category" db:"category"`
+	DisplayName     string        `json:"display_name" db:"display_name"`
+	SortOrder       int           `json:"sort_order" db:"sort_order"`
+	BaseThreshold   int           `json:"base_threshold" db:"base_threshold"`
+	MinThreshold    int           `json:"min_threshold" db:"min_threshold"`
+	MaxThreshold    int           `json:"max_threshold" db:"max_threshold"`
+	LevelMultiplier float64       `json:"level_multiplier" db:"level_multiplier"` // 発動ごとに次の閾値へ掛ける倍率 (レベルN の閾値 = レベル1の閾値 × LevelMultiplier^(N-1))
+	CooldownMs      int           `json:"cooldown_ms" db:"cooldown_ms"`           // 発動後、同じイベント種別が再発動できるまでの時間 (ms, 0 はクールダウンなし)
 }
 
 type EventResult struct {
-	EventType       EventType `json:"event_type"`
-	CurrentCount    int       `json:"current_count"`
-	RequiredCount   int       `json:"required_count"`
-	RemainingCount  int       `json:"remaining_count"`
-	Progress        float64   `json:"progress"`
-	EffectTriggered bool      `json:"effect_triggered"`
-	ViewerCount     int       `json:"viewer_count"`
-	NextThreshold   int       `json:"next_threshold"`
+	EventType           EventType `json:"event_type"`
+	CurrentCount        int       `json:"current_count"`
+	RequiredCount       int       `json:"required_count"`
+	RemainingCount      int       `json:"remaining_count"`
+	Progress            float64   `json:"progress"`
+	EffectTriggered     bool      `json:"effect_triggered"`
+	ViewerCount         int       `json:"viewer_count"`
+	NextThreshold       int       `json:"next_threshold"`
+	Level               int       `json:"level"`                 // 処理後の現在レベル (発動時は上昇後のレベル)
+	TriggerCount        int       `json:"trigger_count"`         // この処理で発動した回数 (複数レベル分の閾値を超えた場合は2以上)
+	Throttled           bool      `json:"throttled"`             // 閾値到達済みだがクールダウン / ルーム上限で発動を見送った
+	CooldownRemainingMs int64     `json:"cooldown_remaining_ms"` // 次に発動できるまでの残り時間 (ms)
 }
 
 type RoomEventStat struct {
-	EventType      EventType `json:"event_type"`
-	CurrentCount   int       `json:"current_count"`
-	CurrentLevel   int       `json:"current_level"`
-	RequiredCount  int       `json:"required_count"`
-	RemainingCount int       `json:"remaining_count"`
-	Progress       float64   `json:"progress"`
-	NextThreshold  int       `json:"next_threshold"`
-	ViewerCount    int       `json:"viewer_count"`
+	EventType           EventType `json:"event_type"`
+	CurrentCount        int       `json:"current_count"`
+	CurrentLevel        int       `json:"current_level"`
+	RequiredCount       int       `json:"required_count"`
+	RemainingCount      int       `json:"remaining_count"`
+	Progress            float64   `json:"progress"`
+	NextThreshold       int       `json:"next_threshold"`
+	ViewerCount         int       `json:"viewer_count"`
+	ThresholdStrategy   string    `json:"threshold_strategy"`    // 閾値の算出に使った戦略名
+	ThresholdMultiplier float64   `json:"threshold_multiplier"`  // 戦略が BaseThreshold に掛けた倍率 (クランプ前)
+	PushRate            float64   `json:"push_rate,omitempty"`   // rate 戦略が参照した押下レート (回/秒)
+	CooldownRemainingMs int64     `json:"cooldown_remaining_ms"` // クールダウンの残り時間 (ms)
+	BufferedCount       int       `json:"buffered_count"`        // 一時停止中に保留している押下数 (再開時に反映)
 }
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// GameSession: ルーム内の1ラウンド (game_sessions テーブル)
+// Unity の game_start で開始し、game_end (またはルーム終了) で EndedAt が設定される。
+type GameSession struct {
+	ID        int64      `json:"id" db:"id"`
+	RoomID    string     `json:"room_id" db:"room_id"`
+	Round     int        `json:"round" db:"round"` // ルーム内で 1 から連番
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"` // 進行中は nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// 押下がレート制限で削られた理由
+const (
+	RateLimitReasonRequest = "request_rate" // リクエスト数の上限超過でリクエストごと破棄
+	RateLimitReasonPush    = "push_rate"    // 押下数の上限超過で一部 (または全部) を破棄
+)
+
+// RateLimitedPush: レート制限で削られた押下の記録 (rate_limited_pushes テーブル)
+type RateLimitedPush struct {
+	ID        int64     `json:"id" db:"id"`
+	RoomID    string    `json:"room_id" db:"room_id"`
+	ViewerID  *string   `json:"viewer_id" db:"viewer_id"`
+	ClientKey string    `json:"client_key" db:"client_key"` // 制限単位 (viewer:<id> / ip:<addr>)
+	EventType EventType `json:"event_type" db:"event_type"`
+	Requested int64     `json:"requested" db:"requested"` // リクエストされた押下数
+	Accepted  int64     `json:"accepted" db:"accepted"`   // 受け付けた押下数
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// relay の実行者 (relay_audit_logs.actor)
+const (
+	RelayActorAdmin     = "admin"      // 管理者トークン
+	RelayActorRoomOwner = "room_owner" // 所有者の API キー (配信者本人)
+)
+
+// relay の結果 (relay_audit_logs.status)
+const (
+	RelayStatusSent     = "sent"     // Unity へ送信済み
+	RelayStatusRejected = "rejected" // スキーマ検証で拒否
+	RelayStatusFailed   = "failed"   // 送信失敗 (接続なし等)
+)
+
+// RelayAuditRecord: Unity へ直接送ったメッセージの監査ログ (relay_audit_logs テーブル)
+type RelayAuditRecord struct {
+	ID          int64     `json:"id" db:"id"`
+	RoomID      string    `json:"room_id" db:"room_id"`
+	MessageType string    `json:"message_type" db:"message_type"`
+	Actor       string    `json:"actor" db:"actor"`
+	RemoteAddr  string    `json:"remote_addr" db:"remote_addr"`
+	Payload     string    `json:"payload" db:"payload"` // 転送内容 (JSON)
+	Status      string    `json:"status" db:"status"`
+	Error       *string   `json:"error" db:"error"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -7,7 +7,23 @@ type Room struct {
 	StreamerID string     `json:"streamer_id" db:"streamer_id"`
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
-	Status     string     `json:"status" db:"status"`
+	Status     RoomState  `json:"status" db:"status"`
 	Settings   string     `json:"settings" db:"settings"`
 	EndedAt    *time.Time `json:"ended_at" db:"ended_at"`
+	// DisconnectedAt: Unity が切断した時刻 (status = disconnected の間のみ意味を持つ)
+	DisconnectedAt *time.Time `json:"disconnected_at" db:"disconnected_at"`
+	// ResumeStatus: 再接続時に戻す状態 (status = disconnected の間のみ設定される)
+	ResumeStatus *RoomState `json:"-" db:"resume_status"`
+	// CurrentRound: 進行中 (または直近) のラウンド番号 (0 はまだゲームをしていない)
+	CurrentRound int `json:"current_round" db:"current_round"`
+	// LastActivityAt: 最後に押下 / 状態遷移があった時刻 (無操作タイムアウトの起点)
+	LastActivityAt *time.Time `json:"last_activity_at" db:"last_activity_at"`
+}
+
+// RoomExpiry: ジャニターが期限切れ / 無操作で終了させたルーム1件
+type RoomExpiry struct {
+	ID        string    `db:"id"`
+	FromState RoomState `db:"from_state"`
+	ToState   RoomState `db:"to_state"`
+	Reason    string    `db:"reason"`
 }
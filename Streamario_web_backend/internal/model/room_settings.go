@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RoomSettings: rooms.settings (JSONB) に保存するルーム単位の設定
+// 未指定の項目はイベントカタログ / サーバ既定値が使われる。
+type RoomSettings struct {
+	ThresholdStrategy string                          `json:"threshold_strategy,omitempty"` // 閾値戦略名 (ルーム作成時に決定)
+	ThresholdParams   *ThresholdParams                `json:"threshold_params,omitempty"`   // 閾値戦略のパラメータ
+	EventThresholds   map[EventType]ThresholdOverride `json:"event_thresholds,omitempty"`   // イベント種別ごとの閾値上書き
+	ViewerMultipliers []ViewerMultiplierStep          `json:"viewer_multipliers,omitempty"` // 視聴者数帯ごとの倍率テーブル
+	TriggerLimit      *TriggerLimit                   `json:"trigger_limit,omitempty"`      // ルーム全体の発動回数上限
+	PauseMode         string                          `json:"pause_mode,omitempty"`         // 一時停止中の押下の扱い (PauseMode*)
+}
+
+// 一時停止中 (paused) の押下の扱い
+const (
+	PauseModeReject = "reject" // 受け付けない (既定)
+	PauseModeBuffer = "buffer" // 記録だけして保留し、再開時にまとめてカウンタへ反映する
+)
+
+// EffectivePauseMode: 未指定なら既定の reject を返す
+func (s RoomSettings) EffectivePauseMode() string {
+	if s.PauseMode == "" {
+		return PauseModeReject
+	}
+	return s.PauseMode
+}
+
+// ThresholdParams: 閾値戦略のパラメータ (nil の項目は戦略の既定値を使用)
+type ThresholdParams struct {
+	LinearSlope *float64 `json:"linear_slope,omitempty"` // linear: 視聴者1人あたりの倍率増分
+	LogFactor   *float64 `json:"log_factor,omitempty"`   // log: ln(視聴者数) に掛ける係数
+	RateFactor  *float64 `json:"rate_factor,omitempty"`  // rate: 押下レート 1回/秒 あたりの倍率増分
+}
+
+// ThresholdOverride: カタログの閾値設定を部分的に上書きする (nil の項目はカタログ値を使用)
+type ThresholdOverride struct {
+	BaseThreshold *int `json:"base_threshold,omitempty"`
+	MinThreshold  *int `json:"min_threshold,omitempty"`
+	MaxThreshold  *int `json:"max_threshold,omitempty"`
+	CooldownMs    *int `json:"cooldown_ms,omitempty"`
+}
+
+// TriggerLimit: ルーム全体で WindowMs 内に発動できる回数の上限 (超過分のカウントは持ち越す)
+type TriggerLimit struct {
+	MaxTriggers int `json:"max_triggers"`
+	WindowMs    int `json:"window_ms"`
+}
+
+// ViewerMultiplierStep: アクティブ視聴者数が MaxViewers 以下のときに適用する倍率
+// MaxViewers = 0 は上限なし (テーブル末尾のみ指定可)
+type ViewerMultiplierStep struct {
+	MaxViewers int     `json:"max_viewers"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// ParseRoomSettings: rooms.settings の JSON 文字列を解釈 (空文字 / null は空設定として扱う)
+func ParseRoomSettings(raw string) (RoomSettings, error) {
+	var settings RoomSettings
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || trimmed == "null" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(trimmed), &settings); err != nil {
+		return RoomSettings{}, err
+	}
+	return settings, nil
+}
+
+// Encode: rooms.settings へ保存する JSON 文字列を生成
+func (s RoomSettings) Encode() (string, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+// RoomState: ルームの状態 (rooms.status)
+// 変更は RoomService.Transition を通し、roomTransitions に定義した遷移のみ許可する。
+type RoomState string
+
+const (
+	RoomStateCreated      RoomState = "created"      // 作成済み (Unity 未接続)
+	RoomStateWaiting      RoomState = "waiting"      // Unity 接続済み、次のラウンドの開始待ち
+	RoomStateInGame       RoomState = "in_game"      // ゲーム中 (視聴者の押下を受け付ける)
+	RoomStatePaused       RoomState = "paused"       // ゲーム一時停止中
+	RoomStateDisconnected RoomState = "disconnected" // Unity 切断中 (再接続猶予内)
+	RoomStateEnded        RoomState = "ended"        // ルーム終了 (結果参照のみ)
+	RoomStateExpired      RoomState = "expired"      // 期限切れ
+)
+
+// roomTransitions: 状態ごとの遷移先
+// waiting → in_game で新しいラウンドを開始し、game_end で waiting に戻る (ルームは何ラウンドでも続けられる)。
+// disconnected からは切断前の状態 (resume_status) へ戻る。ended / expired は終端。
+var roomTransitions = map[RoomState][]RoomState{
+	RoomStateCreated:      {RoomStateWaiting, RoomStateEnded, RoomStateExpired},
+	RoomStateWaiting:      {RoomStateInGame, RoomStateDisconnected, RoomStateEnded, RoomStateExpired},
+	RoomStateInGame:       {RoomStateWaiting, RoomStatePaused, RoomStateDisconnected, RoomStateEnded},
+	RoomStatePaused:       {RoomStateWaiting, RoomStateInGame, RoomStateDisconnected, RoomStateEnded},
+	RoomStateDisconnected: {RoomStateWaiting, RoomStateInGame, RoomStatePaused, RoomStateEnded, RoomStateExpired},
+	RoomStateEnded:        {},
+	RoomStateExpired:      {},
+}
+
+// Valid: 定義済みの状態か
+func (s RoomState) Valid() bool {
+	_, ok := roomTransitions[s]
+	return ok
+}
+
+// Terminal: 終端状態 (ended / expired) か
+func (s RoomState) Terminal() bool {
+	return s.Valid() && len(roomTransitions[s]) == 0
+}
+
+// CanTransitionTo: to への遷移が定義されているか
+func (s RoomState) CanTransitionTo(to RoomState) bool {
+	for _, next := range roomTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 状態遷移の理由 (room_state_transitions.reason)
+const (
+	RoomTransitionCreated        = "room_created"       // ルーム作成
+	RoomTransitionUnityConnected = "unity_connected"    // Unity が作成済みルームに接続
+	RoomTransitionGameStart      = "game_start"         // Unity からのゲーム開始通知 (waiting からは新しいラウンドを開始)
+	RoomTransitionGameEnd        = "game_end"           // Unity からのゲーム終了通知 (ラウンド終了)
+	RoomTransitionGamePause      = "game_pause"         // Unity からの一時停止通知
+	RoomTransitionGameResume     = "game_resume"        // Unity からの再開通知
+	RoomTransitionDisconnected   = "unity_disconnected" // Unity 切断 (再接続待ち)
+	RoomTransitionReconnected    = "unity_reconnected"  // 再接続猶予内に Unity が戻った
+	RoomTransitionGraceExpired   = "reconnect_expired"  // 再接続猶予切れで終了
+	RoomTransitionClosed         = "unity_closed"       // 再接続猶予なしの切断で終了
+	RoomTransitionUnityLost      = "unity_lost"         // Unity 接続を持つサーバーから接続の確認が途絶えた (再接続待ちにする)
+	RoomTransitionTTLExpired     = "room_ttl"           // ルームの有効期限 (expires_at) 切れ
+	RoomTransitionIdleExpired    = "idle_timeout"       // 無操作タイムアウト
+)
+
+// RoomStateTransition: ルーム状態の遷移履歴1件 (room_state_transitions テーブル)
+type RoomStateTransition struct {
+	ID        int64      `json:"id" db:"id"`
+	RoomID    string     `json:"room_id" db:"room_id"`
+	FromState *RoomState `json:"from_state" db:"from_state"` // 作成時は nil
+	ToState   RoomState  `json:"to_state" db:"to_state"`
+	Reason    string     `json:"reason" db:"reason"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
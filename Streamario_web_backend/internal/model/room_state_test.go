@@ -0,0 +1,68 @@
+package model
+
+import "testing"
+
+var allRoomStates = []RoomState{
+	RoomStateCreated, RoomStateWaiting, RoomStateInGame, RoomStatePaused,
+	RoomStateDisconnected, RoomStateEnded, RoomStateExpired,
+}
+
+// TestRoomState_CanTransitionTo: 全ての (from, to) の組を許可 / 禁止の期待値と照合する
+func TestRoomState_CanTransitionTo(t *testing.T) {
+	allowed := map[RoomState]map[RoomState]bool{
+		RoomStateCreated:      {RoomStateWaiting: true, RoomStateEnded: true, RoomStateExpired: true},
+		RoomStateWaiting:      {RoomStateInGame: true, RoomStateDisconnected: true, RoomStateEnded: true, RoomStateExpired: true},
+		RoomStateInGame:       {RoomStateWaiting: true, RoomStatePaused: true, RoomStateDisconnected: true, RoomStateEnded: true},
+		RoomStatePaused:       {RoomStateWaiting: true, RoomStateInGame: true, RoomStateDisconnected: true, RoomStateEnded: true},
+		RoomStateDisconnected: {RoomStateWaiting: true, RoomStateInGame: true, RoomStatePaused: true, RoomStateEnded: true, RoomStateExpired: true},
+	}
+	for _, from := range allRoomStates {
+		for _, to := range allRoomStates {
+			want := allowed[from][to]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: expected allowed=%v, got %v", from, to, want, got)
+			}
+		}
+	}
+}
+
+func TestRoomState_CanTransitionTo_Cases(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to RoomState
+		want     bool
+	}{
+		{"new round", RoomStateWaiting, RoomStateInGame, true},
+		{"round end", RoomStateInGame, RoomStateWaiting, true},
+		{"resume from pause", RoomStatePaused, RoomStateInGame, true},
+		{"reconnect to waiting", RoomStateDisconnected, RoomStateWaiting, true},
+		{"reconnect to in_game", RoomStateDisconnected, RoomStateInGame, true},
+		{"reconnect to paused", RoomStateDisconnected, RoomStatePaused, true},
+		{"ended cannot restart", RoomStateEnded, RoomStateInGame, false},
+		{"ended cannot wait", RoomStateEnded, RoomStateWaiting, false},
+		{"expired cannot restart", RoomStateExpired, RoomStateInGame, false},
+		{"created cannot start game", RoomStateCreated, RoomStateInGame, false},
+		{"in_game does not expire", RoomStateInGame, RoomStateExpired, false},
+		{"self transition", RoomStateInGame, RoomStateInGame, false},
+		{"unknown state", RoomState("archived"), RoomStateWaiting, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.from.CanTransitionTo(tc.to); got != tc.want {
+				t.Errorf("%s -> %s: expected %v, got %v", tc.from, tc.to, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRoomState_Terminal(t *testing.T) {
+	for _, s := range allRoomStates {
+		want := s == RoomStateEnded || s == RoomStateExpired
+		if got := s.Terminal(); got != want {
+			t.Errorf("%s: expected terminal=%v, got %v", s, want, got)
+		}
+	}
+	if RoomState("archived").Terminal() {
+		t.Error("unknown state must not be terminal")
+	}
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// UnityAnonymousStreamerID: 配信者として認証せずに Unity が作成したルームの streamer_id
+const UnityAnonymousStreamerID = "unity"
+
+// Streamer: 配信者アカウント (streamers テーブル)
+type Streamer struct {
+	ID         string     `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+}
+
+// StreamerAPIKey: 配信者の API キー (streamer_api_keys テーブル、平文は発行時にしか得られない)
+type StreamerAPIKey struct {
+	ID         string     `json:"id" db:"id"`
+	StreamerID string     `json:"streamer_id" db:"streamer_id"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	KeyPrefix  string     `json:"key_prefix" db:"key_prefix"`
+	Label      string     `json:"label" db:"label"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// 発動通知の配信状況 (game_events.delivery_status)
+const (
+	DeliveryStatusPending       = "pending"        // 記録直後 (配信前)
+	DeliveryStatusPublished     = "published"      // Pub/Sub へ配信済み
+	DeliveryStatusPublishFailed = "publish_failed" // Pub/Sub への配信に失敗
+	DeliveryStatusDelivered     = "delivered"      // Unity から ack を受信済み
+)
+
+// TriggerRecord: 閾値到達で発動した game_event の記録 (game_events テーブル)
+type TriggerRecord struct {
+	ID             int64      `json:"id" db:"id"`
+	RoomID         string     `json:"room_id" db:"room_id"`
+	Round          int        `json:"round" db:"round"`
+	EventType      EventType  `json:"event_type" db:"event_type"`
+	TriggerCount   int        `json:"trigger_count" db:"trigger_count"` // 到達時点のカウント
+	Multiplier     int        `json:"multiplier" db:"multiplier"`       // 1回の処理で発動した回数
+	Level          int        `json:"level" db:"level"`                 // 最後に発動したレベル
+	ViewerCount    int        `json:"viewer_count" db:"viewer_count"`
+	ViewerID       *string    `json:"viewer_id" db:"viewer_id"` // 閾値を超える押下をした視聴者
+	ViewerName     *string    `json:"viewer_name" db:"viewer_name"`
+	DeliveryStatus string     `json:"delivery_status" db:"delivery_status"`
+	SentAt         time.Time  `json:"sent_at" db:"sent_at"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+}
+
+// TriggerTimelineEntry: 結果画面向けの発動履歴1件
+type TriggerTimelineEntry struct {
+	At         time.Time `json:"at"`
+	Level      int       `json:"level"`
+	Multiplier int       `json:"multiplier"`
+	ViewerID   *string   `json:"viewer_id"`
+	ViewerName *string   `json:"viewer_name"`
+}
+
+// EventTriggerTimeline: イベント種別ごとの発動履歴 (時系列順)
+type EventTriggerTimeline struct {
+	EventType EventType              `json:"event_type"`
+	Total     int                    `json:"total"` // 発動回数の合計 (multiplier の合計)
+	Triggers  []TriggerTimelineEntry `json:"triggers"`
+}
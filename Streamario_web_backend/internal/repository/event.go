@@ -2,7 +2,10 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"streamerrio-backend/internal/model"
@@ -13,11 +16,12 @@ import (
 // EventRepository: イベント永続化用インタフェース
 // 主要イベントクエリのトレースログを出力し、運用時の観測性を高める。
 type EventRepository interface {
-	CreateEvent(roomID string, PushEventMap map[model.EventType]int64, viewerID *string) error // 単一イベント挿入
-	ListEventViewerCounts(roomID string) ([]model.EventAggregate, error)
-	ListEventTotals(roomID string) ([]model.EventTotal, error)
-	ListViewerTotals(roomID string) ([]model.ViewerTotal, error)
-	ListViewerEventCounts(roomID, viewerID string) ([]model.ViewerEventCount, error)
+	CreateEvents(records []model.EventRecord) error // 複数行をまとめて挿入 (1 INSERT)
+	// 集計はラウンド単位 (round はルーム内のラウンド番号)
+	ListEventViewerCounts(roomID string, round int) ([]model.EventAggregate, error)
+	ListEventTotals(roomID string, round int) ([]model.EventTotal, error)
+	ListViewerTotals(roomID string, round int) ([]model.ViewerTotal, error)
+	ListViewerEventCounts(roomID string, round int, viewerID string) ([]model.ViewerEventCount, error)
 	Close() error
 }
 
@@ -26,7 +30,6 @@ type eventRepository struct {
 	logger *slog.Logger
 
 	// 準備済みステートメントを保持
-	createEventStmt           *sqlx.Stmt
 	listEventViewerCountsStmt *sqlx.Stmt
 	listEventTotalsStmt       *sqlx.Stmt
 	listViewerTotalsStmt      *sqlx.Stmt
@@ -42,7 +45,6 @@ func NewEventRepository(db *sqlx.DB, logger *slog.Logger) EventRepository {
 	return &eventRepository{
 		db:                        db,
 		logger:                    logger,
-		createEventStmt:           mustPrepare(db, logger, queryCreateEvent),
 		listEventViewerCountsStmt: mustPrepare(db, logger, queryListEventViewerCounts),
 		listEventTotalsStmt:       mustPrepare(db, logger, queryListEventTotals),
 		listViewerTotalsStmt:      mustPrepare(db, logger, queryListViewerTotals),
@@ -50,40 +52,64 @@ func NewEventRepository(db *sqlx.DB, logger *slog.Logger) EventRepository {
 	}
 }
 
-// CreateEvent: events テーブルへ挿入 (TriggeredAt 未設定なら現在時刻)
-func (r *eventRepository) CreateEvent(roomID string, PushEventMap map[model.EventType]int64, viewerID *string) error {
+// CreateEvents: 複数レコードを1つの INSERT ... VALUES (...),(...) で挿入
+// 行数が可変のため準備済みステートメントは使わない。
+func (r *eventRepository) CreateEvents(records []model.EventRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
 	logger := r.logger.With(
 		slog.String("repo", "event"),
-		slog.String("op", "create_event"),
-		slog.String("room_id", roomID),
-		slog.Any("PushEventMap", PushEventMap),
-		slog.Bool("has_viewer", viewerID != nil),
+		slog.String("op", "create_events"),
+		slog.Int("record_count", len(records)),
 	)
+	var sb strings.Builder
+	sb.WriteString(queryCreateEventsPrefix)
+	args := make([]interface{}, 0, len(records)*6)
+	for i, rec := range records {
+		countsJSON, err := json.Marshal(rec.Counts)
+		if err != nil {
+			logger.Error("json marshal failed", slog.String("room_id", rec.RoomID), slog.Any("error", err))
+			return err
+		}
+		triggeredAt := rec.TriggeredAt
+		if triggeredAt.IsZero() {
+			triggeredAt = time.Now()
+		}
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		n := len(args)
+		fmt.Fprintf(&sb, "($%d,$%d,$%d,$%d,$%d,$%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+		args = append(args, rec.RoomID, rec.Round, rec.ViewerID, triggeredAt, "{}", string(countsJSON))
+	}
+	sb.WriteString(queryCreateEventsSuffix)
 	start := time.Now()
-	res, err := r.createEventStmt.Exec(roomID, viewerID, time.Now(), "{}", PushEventMap[model.SKILL1], PushEventMap[model.SKILL2], PushEventMap[model.SKILL3], PushEventMap[model.ENEMY1], PushEventMap[model.ENEMY2], PushEventMap[model.ENEMY3])
+	res, err := r.db.Exec(sb.String(), args...)
 	if err != nil {
-		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		logger.Error("db.exec failed", slog.Any("error", err))
 		return err
 	}
-	rows, _ := res.RowsAffected()
-	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	rooms, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rooms_touched", rooms), slog.Duration("elapsed", time.Since(start)))
 	return nil
 }
 
-func (r *eventRepository) ListEventViewerCounts(roomID string) ([]model.EventAggregate, error) {
+func (r *eventRepository) ListEventViewerCounts(roomID string, round int) ([]model.EventAggregate, error) {
 	rows := []struct {
 		EventType  model.EventType `db:"event_type"`
 		ViewerID   sql.NullString  `db:"viewer_id"`
 		ViewerName sql.NullString  `db:"viewer_name"`
-		Count      int             `db:"count"`
+		Count      int64           `db:"count"`
 	}{}
 	logger := r.logger.With(
 		slog.String("repo", "event"),
 		slog.String("op", "list_event_viewer_counts"),
 		slog.String("room_id", roomID),
+		slog.Int("round", round),
 	)
 	start := time.Now()
-	if err := r.listEventViewerCountsStmt.Select(&rows, roomID); err != nil {
+	if err := r.listEventViewerCountsStmt.Select(&rows, roomID, round); err != nil {
 		logger.Error("db.query (prepared) failed", slog.Any("error", err))
 		return nil, err
 	}
@@ -103,15 +129,16 @@ func (r *eventRepository) ListEventViewerCounts(roomID string) ([]model.EventAgg
 	return aggs, nil
 }
 
-func (r *eventRepository) ListEventTotals(roomID string) ([]model.EventTotal, error) {
+func (r *eventRepository) ListEventTotals(roomID string, round int) ([]model.EventTotal, error) {
 	rows := []model.EventTotal{}
 	logger := r.logger.With(
 		slog.String("repo", "event"),
 		slog.String("op", "list_event_totals"),
 		slog.String("room_id", roomID),
+		slog.Int("round", round),
 	)
 	start := time.Now()
-	if err := r.listEventTotalsStmt.Select(&rows, roomID); err != nil {
+	if err := r.listEventTotalsStmt.Select(&rows, roomID, round); err != nil {
 		logger.Error("db.query (prepared) failed", slog.Any("error", err))
 		return nil, err
 	}
@@ -119,19 +146,20 @@ func (r *eventRepository) ListEventTotals(roomID string) ([]model.EventTotal, er
 	return rows, nil
 }
 
-func (r *eventRepository) ListViewerTotals(roomID string) ([]model.ViewerTotal, error) {
+func (r *eventRepository) ListViewerTotals(roomID string, round int) ([]model.ViewerTotal, error) {
 	rows := []struct {
 		ViewerID   sql.NullString `db:"viewer_id"`
 		ViewerName sql.NullString `db:"viewer_name"`
-		Count      int            `db:"count"`
+		Count      int64          `db:"count"`
 	}{}
 	logger := r.logger.With(
 		slog.String("repo", "event"),
 		slog.String("op", "list_viewer_totals"),
 		slog.String("room_id", roomID),
+		slog.Int("round", round),
 	)
 	start := time.Now()
-	if err := r.listViewerTotalsStmt.Select(&rows, roomID); err != nil {
+	if err := r.listViewerTotalsStmt.Select(&rows, roomID, round); err != nil {
 		logger.Error("db.query (prepared) failed", slog.Any("error", err))
 		return nil, err
 	}
@@ -151,16 +179,17 @@ func (r *eventRepository) ListViewerTotals(roomID string) ([]model.ViewerTotal,
 	return totals, nil
 }
 
-func (r *eventRepository) ListViewerEventCounts(roomID, viewerID string) ([]model.ViewerEventCount, error) {
+func (r *eventRepository) ListViewerEventCounts(roomID string, round int, viewerID string) ([]model.ViewerEventCount, error) {
 	rows := []model.ViewerEventCount{}
 	logger := r.logger.With(
 		slog.String("repo", "event"),
 		slog.String("op", "list_viewer_event_counts"),
 		slog.String("room_id", roomID),
+		slog.Int("round", round),
 		slog.String("viewer_id", viewerID),
 	)
 	start := time.Now()
-	if err := r.listViewerEventCountsStmt.Select(&rows, roomID, viewerID); err != nil {
+	if err := r.listViewerEventCountsStmt.Select(&rows, roomID, round, viewerID); err != nil {
 		logger.Error("db.query (prepared) failed", slog.Any("error", err))
 		return nil, err
 	}
@@ -184,7 +213,6 @@ func (r *eventRepository) Close() error {
 		}
 	}
 
-	closeStmt(r.createEventStmt)
 	closeStmt(r.listEventViewerCountsStmt)
 	closeStmt(r.listEventTotalsStmt)
 	closeStmt(r.listViewerTotalsStmt)
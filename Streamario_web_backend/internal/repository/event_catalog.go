@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EventCatalogRepository: イベントカタログ (有効なイベント種別と閾値設定) の取得
+type EventCatalogRepository interface {
+	List() ([]model.EventConfig, error) // 有効なエントリを sort_order 順で取得
+	Close() error
+}
+
+type eventCatalogRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	listStmt *sqlx.Stmt
+}
+
+// NewEventCatalogRepository: 実装生成
+func NewEventCatalogRepository(db *sqlx.DB, logger *slog.Logger) EventCatalogRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &eventCatalogRepository{
+		db:       db,
+		logger:   logger,
+		listStmt: mustPrepare(db, logger, queryListEventCatalog),
+	}
+}
+
+func (r *eventCatalogRepository) List() ([]model.EventConfig, error) {
+	rows := []model.EventConfig{}
+	logger := r.logger.With(
+		slog.String("repo", "event_catalog"),
+		slog.String("op", "list"),
+	)
+	start := time.Now()
+	if err := r.listStmt.Select(&rows); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query", slog.Int("row_count", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *eventCatalogRepository) Close() error {
+	if r.listStmt == nil {
+		return nil
+	}
+	return r.listStmt.Close()
+}
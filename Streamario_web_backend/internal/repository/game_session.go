@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GameSessionRepository: ルームのラウンド (game_sessions) の参照
+// ラウンドの開始 / 終了はルームの状態遷移と同じステートメントで行う (RoomRepository.StartRound / Transition)。
+type GameSessionRepository interface {
+	ListByRoom(roomID string) ([]model.GameSession, error)    // ラウンド順に取得
+	Get(roomID string, round int) (*model.GameSession, error) // 存在しなければ nil
+	Close() error
+}
+
+type gameSessionRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	listByRoomStmt *sqlx.Stmt
+	getStmt        *sqlx.Stmt
+}
+
+// NewGameSessionRepository: 実装生成
+func NewGameSessionRepository(db *sqlx.DB, logger *slog.Logger) GameSessionRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &gameSessionRepository{
+		db:             db,
+		logger:         logger,
+		listByRoomStmt: mustPrepare(db, logger, queryListGameSessionsByRoom),
+		getStmt:        mustPrepare(db, logger, queryGetGameSession),
+	}
+}
+
+func (r *gameSessionRepository) ListByRoom(roomID string) ([]model.GameSession, error) {
+	rows := []model.GameSession{}
+	logger := r.logger.With(
+		slog.String("repo", "game_session"),
+		slog.String("op", "list_by_room"),
+		slog.String("room_id", roomID),
+	)
+	start := time.Now()
+	if err := r.listByRoomStmt.Select(&rows, roomID); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query", slog.Int("row_count", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *gameSessionRepository) Get(roomID string, round int) (*model.GameSession, error) {
+	var gs model.GameSession
+	logger := r.logger.With(
+		slog.String("repo", "game_session"),
+		slog.String("op", "get"),
+		slog.String("room_id", roomID),
+		slog.Int("round", round),
+	)
+	start := time.Now()
+	if err := r.getStmt.Get(&gs, roomID, round); err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("db.query (prepared)", slog.Bool("found", false), slog.Duration("elapsed", time.Since(start)))
+			return nil, nil
+		}
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Bool("found", true), slog.Duration("elapsed", time.Since(start)))
+	return &gs, nil
+}
+
+func (r *gameSessionRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sqlx.Stmt{r.listByRoomStmt, r.getStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LeaseRepository: 複数インスタンスで動くバックグラウンド処理の排他 (leases テーブル)
+// 保持者が期限内に更新し続ける限り他のインスタンスは取得できない。保持者が落ちても期限が過ぎれば引き継がれる。
+type LeaseRepository interface {
+	// Acquire: 期限切れ (または自分が保持中) なら holder として ttl の間保持する (取得 / 延長できた場合 true、期限は DB の時刻で判定)
+	Acquire(name, holder string, ttl time.Duration) (bool, error)
+	// Release: holder が保持している場合のみ手放す
+	Release(name, holder string) error
+	Close() error
+}
+
+type leaseRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	acquireStmt *sqlx.Stmt
+	releaseStmt *sqlx.Stmt
+}
+
+// NewLeaseRepository: 実装生成
+func NewLeaseRepository(db *sqlx.DB, logger *slog.Logger) LeaseRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &leaseRepository{
+		db:          db,
+		logger:      logger,
+		acquireStmt: mustPrepare(db, logger, queryAcquireLease),
+		releaseStmt: mustPrepare(db, logger, queryReleaseLease),
+	}
+}
+
+func (r *leaseRepository) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	logger := r.logger.With(
+		slog.String("repo", "lease"),
+		slog.String("op", "acquire"),
+		slog.String("name", name),
+		slog.String("holder", holder),
+	)
+	start := time.Now()
+	var got string
+	if err := r.acquireStmt.Get(&got, name, holder, ttl.Seconds()); err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("db.query (prepared)", slog.Bool("acquired", false), slog.Duration("elapsed", time.Since(start)))
+			return false, nil
+		}
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return false, err
+	}
+	logger.Debug("db.query (prepared)", slog.Bool("acquired", true), slog.Duration("elapsed", time.Since(start)))
+	return true, nil
+}
+
+func (r *leaseRepository) Release(name, holder string) error {
+	logger := r.logger.With(
+		slog.String("repo", "lease"),
+		slog.String("op", "release"),
+		slog.String("name", name),
+		slog.String("holder", holder),
+	)
+	start := time.Now()
+	res, err := r.releaseStmt.Exec(name, holder)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *leaseRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sqlx.Stmt{r.acquireStmt, r.releaseStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -1,153 +1,221 @@
 package repository
 
 // --- Event Repository Queries ---
+// イベント種別ごとの押下数は events.counts (JSONB) に格納し、jsonb_each_text で展開して集計する
+// 集計はすべて種別を行に展開した GROUP BY で行うため、イベント種別の追加でクエリを変える必要はない。
 const (
-	queryCreateEvent = `INSERT INTO events (room_id, viewer_id, triggered_at, metadata, skill1_count, skill2_count, skill3_count, enemy1_count, enemy2_count, enemy3_count) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`
+	// queryCreateEventsPrefix: 複数行 INSERT の先頭部分 (VALUES 以降は行数に応じて組み立てる)
+	// 挿入と同じステートメントでルームの最終操作時刻 (無操作タイムアウトの起点) を進める
+	queryCreateEventsPrefix = `WITH inserted AS (INSERT INTO events (room_id, round, viewer_id, triggered_at, metadata, counts) VALUES `
+	queryCreateEventsSuffix = ` RETURNING room_id, triggered_at)
+		UPDATE rooms r SET last_activity_at = a.at
+		FROM (SELECT room_id, MAX(triggered_at) AS at FROM inserted GROUP BY room_id) a
+		WHERE r.id = a.room_id AND (r.last_activity_at IS NULL OR r.last_activity_at < a.at)`
 
 	queryListEventViewerCounts = `
-		SELECT 
-			'skill1'::text AS event_type,
+		SELECT
+			c.key AS event_type,
 			e.viewer_id,
 			v.name AS viewer_name,
-			COALESCE(SUM(e.skill1_count), 0) AS count
+			SUM(c.value::bigint)::bigint AS count
 		FROM events e
+		CROSS JOIN LATERAL jsonb_each_text(e.counts) AS c(key, value)
 		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.skill1_count), 0) > 0
-		UNION ALL
-		SELECT 
-			'skill2'::text AS event_type,
-			e.viewer_id,
-			v.name AS viewer_name,
-			COALESCE(SUM(e.skill2_count), 0) AS count
-		FROM events e
-		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.skill2_count), 0) > 0
-		UNION ALL
-		SELECT 
-			'skill3'::text AS event_type,
-			e.viewer_id,
-			v.name AS viewer_name,
-			COALESCE(SUM(e.skill3_count), 0) AS count
-		FROM events e
-		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.skill3_count), 0) > 0
-		UNION ALL
-		SELECT 
-			'enemy1'::text AS event_type,
-			e.viewer_id,
-			v.name AS viewer_name,
-			COALESCE(SUM(e.enemy1_count), 0) AS count
-		FROM events e
-		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.enemy1_count), 0) > 0
-		UNION ALL
-		SELECT 
-			'enemy2'::text AS event_type,
-			e.viewer_id,
-			v.name AS viewer_name,
-			COALESCE(SUM(e.enemy2_count), 0) AS count
-		FROM events e
-		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.enemy2_count), 0) > 0
-		UNION ALL
-		SELECT 
-			'enemy3'::text AS event_type,
-			e.viewer_id,
-			v.name AS viewer_name,
-			COALESCE(SUM(e.enemy3_count), 0) AS count
-		FROM events e
-		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
-		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.enemy3_count), 0) > 0`
+		WHERE e.room_id = $1 AND e.round = $2 AND e.viewer_id IS NOT NULL
+		GROUP BY c.key, e.viewer_id, v.name
+		HAVING SUM(c.value::bigint) > 0`
 
 	queryListEventTotals = `
-		SELECT 'skill1'::text AS event_type, COALESCE(SUM(skill1_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1
-		UNION ALL
-		SELECT 'skill2'::text AS event_type, COALESCE(SUM(skill2_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1
-		UNION ALL
-		SELECT 'skill3'::text AS event_type, COALESCE(SUM(skill3_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1
-		UNION ALL
-		SELECT 'enemy1'::text AS event_type, COALESCE(SUM(enemy1_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1
-		UNION ALL
-		SELECT 'enemy2'::text AS event_type, COALESCE(SUM(enemy2_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1
-		UNION ALL
-		SELECT 'enemy3'::text AS event_type, COALESCE(SUM(enemy3_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1`
+		SELECT c.key AS event_type, SUM(c.value::bigint)::bigint AS count
+		FROM events e
+		CROSS JOIN LATERAL jsonb_each_text(e.counts) AS c(key, value)
+		WHERE e.room_id = $1 AND e.round = $2
+		GROUP BY c.key`
 
 	queryListViewerTotals = `
-		SELECT 
+		SELECT
 			e.viewer_id,
 			v.name AS viewer_name,
-			COALESCE(SUM(e.skill1_count + e.skill2_count + e.skill3_count + e.enemy1_count + e.enemy2_count + e.enemy3_count), 0)::int AS count
+			SUM(c.value::bigint)::bigint AS count
 		FROM events e
+		CROSS JOIN LATERAL jsonb_each_text(e.counts) AS c(key, value)
 		LEFT JOIN viewers v ON v.id = e.viewer_id
-		WHERE e.room_id = $1 AND e.viewer_id IS NOT NULL
+		WHERE e.room_id = $1 AND e.round = $2 AND e.viewer_id IS NOT NULL
 		GROUP BY e.viewer_id, v.name
-		HAVING COALESCE(SUM(e.skill1_count + e.skill2_count + e.skill3_count + e.enemy1_count + e.enemy2_count + e.enemy3_count), 0) > 0
+		HAVING SUM(c.value::bigint) > 0
 		ORDER BY count DESC, e.viewer_id`
 
 	queryListViewerEventCounts = `
-		SELECT 'skill1'::text AS event_type, COALESCE(SUM(skill1_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2
-		UNION ALL
-		SELECT 'skill2'::text AS event_type, COALESCE(SUM(skill2_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2
-		UNION ALL
-		SELECT 'skill3'::text AS event_type, COALESCE(SUM(skill3_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2
-		UNION ALL
-		SELECT 'enemy1'::text AS event_type, COALESCE(SUM(enemy1_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2
-		UNION ALL
-		SELECT 'enemy2'::text AS event_type, COALESCE(SUM(enemy2_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2
-		UNION ALL
-		SELECT 'enemy3'::text AS event_type, COALESCE(SUM(enemy3_count), 0)::int AS count
-		FROM events
-		WHERE room_id = $1 AND viewer_id = $2`
+		SELECT c.key AS event_type, SUM(c.value::bigint)::bigint AS count
+		FROM events e
+		CROSS JOIN LATERAL jsonb_each_text(e.counts) AS c(key, value)
+		WHERE e.room_id = $1 AND e.round = $2 AND e.viewer_id = $3
+		GROUP BY c.key`
+)
+
+// --- Event Catalog Repository Queries ---
+const (
+	queryListEventCatalog = `SELECT event_type, category, display_name, sort_order, base_threshold, min_threshold, max_threshold, level_multiplier, cooldown_ms
+		FROM event_catalog
+		WHERE enabled
+		ORDER BY sort_order, event_type`
 )
 
 // --- Room Repository Queries ---
 const (
-	queryCreateRoom = `INSERT INTO rooms (id, streamer_id, created_at, expires_at, status, settings, ended_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7)`
+	// 作成と同時に初期状態を遷移履歴に記録する
+	queryCreateRoom = `WITH created AS (
+			INSERT INTO rooms (id, streamer_id, created_at, expires_at, status, settings, ended_at, last_activity_at)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$3)
+			RETURNING id, status, created_at
+		)
+		INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+		SELECT id, NULL, status, $8, created_at FROM created`
 
-	queryGetRoom = `SELECT id, streamer_id, created_at, expires_at, status, settings, ended_at FROM rooms WHERE id=$1`
+	queryGetRoom = `SELECT id, streamer_id, created_at, expires_at, status, settings, ended_at, disconnected_at, resume_status, current_round, last_activity_at FROM rooms WHERE id=$1`
 
-	queryUpdateRoom = `UPDATE rooms SET streamer_id=$1, created_at=$2, expires_at=$3, status=$4, settings=$5, ended_at=$6 WHERE id=$7`
+	// 状態 (status / ended_at) は遷移でのみ変更するため更新対象に含めない
+	queryUpdateRoom = `UPDATE rooms SET streamer_id=$1, created_at=$2, expires_at=$3, settings=$4 WHERE id=$5`
 
 	queryDeleteRoom = `DELETE FROM rooms WHERE id=$1`
 
-	queryMarkEndedRoom = `UPDATE rooms SET status=$1, ended_at=$2 WHERE id=$3`
+	// 読み込んだ時点から settings が変わっていない場合のみ更新する ($3=読み込んだ settings。並行した更新はどちらか一方だけが成功する)
+	queryUpdateRoomSettings = `UPDATE rooms SET settings=$1 WHERE id=$2 AND settings IS NOT DISTINCT FROM NULLIF($3, '')::jsonb`
+
+	// 現在の状態が $2 の場合のみ $3 へ遷移し、遷移履歴を記録する (並行した遷移はどちらか一方だけが成功する)
+	// disconnected へは再接続時に戻す状態を resume_status に退避し、終端状態へは ended_at を記録する
+	// waiting / 終端状態へ遷移したら進行中のラウンドを終了する
+	queryTransitionRoom = `WITH updated AS (
+			UPDATE rooms SET status=$3::room_status,
+				ended_at=CASE WHEN $3::room_status IN ('ended', 'expired') THEN $4::timestamp ELSE ended_at END,
+				disconnected_at=CASE WHEN $3::room_status = 'disconnected' THEN $4::timestamp END,
+				resume_status=CASE WHEN $3::room_status = 'disconnected' THEN status END,
+				last_activity_at=$4::timestamp
+			WHERE id=$1 AND status=$2::room_status
+			RETURNING id
+		), closed AS (
+			UPDATE game_sessions SET ended_at=$4::timestamp
+			WHERE room_id IN (SELECT id FROM updated) AND ended_at IS NULL
+				AND $3::room_status IN ('waiting', 'ended', 'expired')
+		)
+		INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+		SELECT id, $2::room_status, $3::room_status, $5, $4::timestamp FROM updated`
+
+	// waiting のルームを in_game にして次のラウンドを開始し、ラウンド番号を返す (状態が変わっていれば0行)
+	queryStartRoomRound = `WITH updated AS (
+			UPDATE rooms SET status='in_game', current_round=current_round + 1, last_activity_at=$2
+			WHERE id=$1 AND status='waiting'
+			RETURNING id, current_round
+		), logged AS (
+			INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+			SELECT id, 'waiting', 'in_game', $3, $2 FROM updated
+		)
+		INSERT INTO game_sessions (room_id, round, started_at)
+		SELECT id, current_round, $2 FROM updated
+		RETURNING round`
 
-	queryMarkInGameRoom = `UPDATE rooms SET status=$1 WHERE id=$2`
+	// 猶予切れの切断ルームを終了させる (再接続と競合しても status の条件でどちらか一方だけが成功する)
+	queryExpireDisconnectedRooms = `WITH expired AS (
+			UPDATE rooms SET status='ended', ended_at=$2, resume_status=NULL
+			WHERE status='disconnected' AND disconnected_at <= $1
+			RETURNING id
+		), logged AS (
+			INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+			SELECT id, 'disconnected', 'ended', $3, $2 FROM expired
+		), closed AS (
+			UPDATE game_sessions SET ended_at=$2
+			WHERE room_id IN (SELECT id FROM expired) AND ended_at IS NULL
+		)
+		SELECT id FROM expired`
+
+	// 有効期限切れ / 無操作のルームを終了させる ($1=現在時刻, $2=無操作の判定時刻 (NULL なら無効), $3=Unity 接続確認の判定時刻, $4=件数上限)
+	// 有効期限切れはゲーム中 (in_game / paused) なら ended、それ以外は expired にする。
+	// 無操作は Unity が接続していない created / waiting のルームのみ対象 (ゲーム中のルームは Unity 接続の途絶で検出する)。
+	// FOR UPDATE で対象行の最新状態を読み直すため、同時に起きた遷移とはどちらか一方だけが成功する。
+	queryExpireStaleRooms = `WITH targets AS (
+			SELECT id, status AS from_state,
+				CASE WHEN expires_at IS NOT NULL AND expires_at <= $1 THEN $5 ELSE $6 END AS reason
+			FROM rooms
+			WHERE (
+				expires_at IS NOT NULL AND expires_at <= $1
+				AND status IN ('created', 'waiting', 'in_game', 'paused', 'disconnected')
+			) OR (
+				$2::timestamp IS NOT NULL AND last_activity_at <= $2::timestamp
+				AND status IN ('created', 'waiting')
+				AND (unity_seen_at IS NULL OR unity_seen_at <= $3)
+			)
+			ORDER BY id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		), updated AS (
+			UPDATE rooms r
+			SET status=CASE WHEN t.from_state IN ('in_game', 'paused') THEN 'ended'::room_status ELSE 'expired'::room_status END,
+				ended_at=$1, resume_status=NULL
+			FROM targets t
+			WHERE r.id = t.id
+			RETURNING r.id, t.from_state, r.status AS to_state, t.reason
+		), logged AS (
+			INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+			SELECT id, from_state, to_state, reason, $1 FROM updated
+		), closed AS (
+			UPDATE game_sessions SET ended_at=$1
+			WHERE room_id IN (SELECT id FROM updated) AND ended_at IS NULL
+		)
+		SELECT id, from_state, to_state, reason FROM updated`
+
+	// Unity 接続の確認が $1 より前で途絶えた進行中のルームを切断状態 (再接続待ち) にする ($2=現在時刻, $3=理由, $4=件数上限)
+	// Unity 接続を持つサーバーごと落ちた場合、切断処理が走らずに進行中のまま残るため
+	queryMarkOrphanedRooms = `WITH targets AS (
+			SELECT id FROM rooms
+			WHERE status IN ('waiting', 'in_game', 'paused') AND unity_seen_at <= $1
+			ORDER BY id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		), updated AS (
+			UPDATE rooms r SET status='disconnected', resume_status=r.status, disconnected_at=$2, last_activity_at=$2
+			FROM targets t
+			WHERE r.id = t.id
+			RETURNING r.id, r.resume_status
+		), logged AS (
+			INSERT INTO room_state_transitions (room_id, from_state, to_state, reason, created_at)
+			SELECT id, resume_status, 'disconnected', $3, $2 FROM updated
+		)
+		SELECT id FROM updated`
+
+	// Unity 接続を持つサーバーが接続中のルームをまとめて確認済みにする
+	queryTouchUnityPresence = `UPDATE rooms SET unity_seen_at=$1 WHERE id = ANY($2)`
+
+	// 終了後に Redis のカウンタ類をまだ削除していないルーム
+	queryListUnclearedRooms = `SELECT id FROM rooms
+		WHERE counters_cleared_at IS NULL AND status IN ('ended', 'expired')
+		ORDER BY ended_at NULLS FIRST, id
+		LIMIT $1`
+
+	queryMarkRoomsCleared = `UPDATE rooms SET counters_cleared_at=$1 WHERE id = ANY($2)`
+
+	queryListRoomTransitions = `SELECT id, room_id, from_state, to_state, reason, created_at
+		FROM room_state_transitions
+		WHERE room_id = $1
+		ORDER BY created_at, id`
+
+	// 配信者のルームを新しい順に (before 指定時は (created_at, id) がそれより前のもののみ。同時刻のルームも取りこぼさない)
+	queryListRoomsByStreamer = `SELECT id, streamer_id, created_at, expires_at, status, settings, ended_at, disconnected_at, resume_status, current_round, last_activity_at
+		FROM rooms
+		WHERE streamer_id = $1 AND ($2::timestamp IS NULL OR (created_at, id) < ($2::timestamp, $3::text))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`
+)
+
+// --- Game Session Repository Queries ---
+const (
+	queryListGameSessionsByRoom = `SELECT id, room_id, round, started_at, ended_at
+		FROM game_sessions
+		WHERE room_id = $1
+		ORDER BY round`
+
+	queryGetGameSession = `SELECT id, room_id, round, started_at, ended_at
+		FROM game_sessions
+		WHERE room_id = $1 AND round = $2`
 )
 
 // --- Viewer Repository Queries ---
@@ -159,3 +227,77 @@ const (
 
 	queryGetViewer = `SELECT id, name, created_at, updated_at FROM viewers WHERE id = $1`
 )
+
+// --- Rate Limit Log Repository Queries ---
+const (
+	queryCreateRateLimitedPush = `INSERT INTO rate_limited_pushes (room_id, viewer_id, client_key, event_type, requested, accepted, reason, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+)
+
+// --- Trigger Repository Queries ---
+const (
+	queryCreateTrigger = `INSERT INTO game_events (room_id, round, event_type, trigger_count, multiplier, level, viewer_count, viewer_id, viewer_name, delivery_status, sent_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING id`
+
+	// delivered は最終状態 (Publish の戻りより先に Unity の ack が届いた場合に published で上書きしない)
+	queryUpdateTriggerStatus = `UPDATE game_events
+		SET delivery_status = $1,
+			delivered_at = CASE WHEN $1 = 'delivered' THEN $2 ELSE delivered_at END
+		WHERE id = $3 AND delivery_status <> 'delivered'`
+
+	// $2 = 0 なら全ラウンド
+	queryListTriggersByRoom = `SELECT id, room_id, round, event_type, trigger_count, multiplier, level, viewer_count, viewer_id, viewer_name, delivery_status, sent_at, delivered_at
+		FROM game_events
+		WHERE room_id = $1 AND ($2 = 0 OR round = $2)
+		ORDER BY sent_at, id`
+)
+
+// --- Relay Audit Repository Queries ---
+const (
+	queryCreateRelayAudit = `INSERT INTO relay_audit_logs (room_id, message_type, actor, remote_addr, payload, status, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	queryListRelayAuditByRoom = `SELECT id, room_id, message_type, actor, remote_addr, payload, status, error, created_at
+		FROM relay_audit_logs
+		WHERE room_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+)
+
+// --- Lease Repository Queries ---
+const (
+	// 期限切れ (または自分が保持中) の場合のみ保持者を自分にする (取得できなければ0行、$3=保持秒数)
+	// 期限は DB の時刻で決めて比べるため、インスタンス間の時計のずれに左右されない
+	queryAcquireLease = `INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, LOCALTIMESTAMP + make_interval(secs => $3))
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at <= LOCALTIMESTAMP
+		RETURNING holder`
+
+	queryReleaseLease = `DELETE FROM leases WHERE name = $1 AND holder = $2`
+)
+
+// --- Streamer Repository Queries ---
+const (
+	queryCreateStreamer = `INSERT INTO streamers (id, name, created_at) VALUES ($1, $2, $3)`
+
+	queryGetStreamer = `SELECT id, name, created_at, disabled_at FROM streamers WHERE id = $1`
+
+	queryCreateStreamerAPIKey = `INSERT INTO streamer_api_keys (id, streamer_id, key_hash, key_prefix, label, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	// 有効なキー (未失効 / アカウント有効) なら使用時刻を更新して配信者を返す (1往復で認証する)
+	queryAuthenticateStreamerAPIKey = `UPDATE streamer_api_keys k SET last_used_at = $2
+		FROM streamers s
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND s.id = k.streamer_id AND s.disabled_at IS NULL
+		RETURNING s.id, s.name, s.created_at, s.disabled_at`
+
+	queryListStreamerAPIKeys = `SELECT id, streamer_id, key_hash, key_prefix, label, created_at, last_used_at, revoked_at
+		FROM streamer_api_keys
+		WHERE streamer_id = $1
+		ORDER BY created_at, id`
+
+	queryRevokeStreamerAPIKey = `UPDATE streamer_api_keys SET revoked_at = $3
+		WHERE id = $1 AND streamer_id = $2 AND revoked_at IS NULL`
+)
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RateLimitLogRepository: レート制限で削られた押下の記録
+type RateLimitLogRepository interface {
+	Record(entries []model.RateLimitedPush) error
+	Close() error
+}
+
+type rateLimitLogRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	createStmt *sqlx.Stmt
+}
+
+// NewRateLimitLogRepository: 実装生成
+func NewRateLimitLogRepository(db *sqlx.DB, logger *slog.Logger) RateLimitLogRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &rateLimitLogRepository{
+		db:         db,
+		logger:     logger,
+		createStmt: mustPrepare(db, logger, queryCreateRateLimitedPush),
+	}
+}
+
+// Record: 1リクエスト分の記録をまとめて保存 (トランザクション内で挿入)
+func (r *rateLimitLogRepository) Record(entries []model.RateLimitedPush) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	logger := r.logger.With(
+		slog.String("repo", "rate_limit_log"),
+		slog.String("op", "record"),
+		slog.String("room_id", entries[0].RoomID),
+		slog.Int("entry_count", len(entries)),
+	)
+	start := time.Now()
+	tx, err := r.db.Beginx()
+	if err != nil {
+		logger.Error("db.begin failed", slog.Any("error", err))
+		return err
+	}
+	stmt := tx.Stmtx(r.createStmt)
+	for _, e := range entries {
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+		if _, err := stmt.Exec(e.RoomID, e.ViewerID, e.ClientKey, e.EventType, e.Requested, e.Accepted, e.Reason, e.CreatedAt); err != nil {
+			_ = tx.Rollback()
+			logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("db.commit failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("db.exec", slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *rateLimitLogRepository) Close() error {
+	if r.createStmt == nil {
+		return nil
+	}
+	return r.createStmt.Close()
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RelayAuditRepository: Unity へ直接送ったメッセージ (relay) の監査ログ
+type RelayAuditRepository interface {
+	Create(rec *model.RelayAuditRecord) error                              // 記録して rec.ID を設定
+	ListByRoom(roomID string, limit int) ([]model.RelayAuditRecord, error) // 新しい順に取得
+	Close() error
+}
+
+type relayAuditRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	createStmt     *sqlx.Stmt
+	listByRoomStmt *sqlx.Stmt
+}
+
+// NewRelayAuditRepository: 実装生成
+func NewRelayAuditRepository(db *sqlx.DB, logger *slog.Logger) RelayAuditRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &relayAuditRepository{
+		db:             db,
+		logger:         logger,
+		createStmt:     mustPrepare(db, logger, queryCreateRelayAudit),
+		listByRoomStmt: mustPrepare(db, logger, queryListRelayAuditByRoom),
+	}
+}
+
+func (r *relayAuditRepository) Create(rec *model.RelayAuditRecord) error {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	logger := r.logger.With(
+		slog.String("repo", "relay_audit"),
+		slog.String("op", "create"),
+		slog.String("room_id", rec.RoomID),
+		slog.String("message_type", rec.MessageType),
+	)
+	start := time.Now()
+	if err := r.createStmt.Get(&rec.ID, rec.RoomID, rec.MessageType, rec.Actor, rec.RemoteAddr, rec.Payload, rec.Status, rec.Error, rec.CreatedAt); err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("db.exec", slog.Int64("id", rec.ID), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *relayAuditRepository) ListByRoom(roomID string, limit int) ([]model.RelayAuditRecord, error) {
+	rows := []model.RelayAuditRecord{}
+	logger := r.logger.With(
+		slog.String("repo", "relay_audit"),
+		slog.String("op", "list_by_room"),
+		slog.String("room_id", roomID),
+	)
+	start := time.Now()
+	if err := r.listByRoomStmt.Select(&rows, roomID, limit); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query", slog.Int("row_count", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *relayAuditRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sqlx.Stmt{r.createStmt, r.listByRoomStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
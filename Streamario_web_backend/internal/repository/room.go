@@ -8,17 +8,40 @@ import (
 	"streamerrio-backend/internal/model"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // RoomRepository: ルーム永続化アクセス用インタフェース
 // 主要メソッドでクエリの所要時間と結果をログ出力する。
 type RoomRepository interface {
-	Create(room *model.Room) error                // 新規作成
-	Get(id string) (*model.Room, error)           // ID取得 (存在しなければ nil)
-	Delete(id string) error                       // ID削除
-	Update(id string, room *model.Room) error     // ID更新
-	MarkEnded(id string, endedAt time.Time) error // 終了状態に遷移
-	MarkInGame(id string) error                   // ゲーム開始状態に遷移
+	Create(room *model.Room) error            // 新規作成 (初期状態を遷移履歴に記録)
+	Get(id string) (*model.Room, error)       // ID取得 (存在しなければ nil)
+	Delete(id string) error                   // ID削除
+	Update(id string, room *model.Room) error // ID更新 (状態は変更しない)
+	// Transition: 現在の状態が from の場合のみ to へ遷移し履歴を記録 (遷移した場合 true)
+	// 遷移が定義どおりかは呼び出し側 (RoomService) で確認する。
+	Transition(id string, from, to model.RoomState, at time.Time, reason string) (bool, error)
+	// StartRound: waiting のルームを in_game にして次のラウンドを開始し、ラウンド番号を返す (waiting でなければ 0)
+	StartRound(id string, at time.Time, reason string) (int, error)
+	// ExpireDisconnected: before 以前に切断したままのルームを終了状態にし、その ID を返す
+	ExpireDisconnected(before, endedAt time.Time) ([]string, error)
+	// ExpireStale: 有効期限切れ / idleBefore 以前から無操作 (nil なら判定しない) のルームを最大 limit 件終了させる
+	// seenBefore 以降に Unity 接続を確認したルームは無操作でも終了させない。
+	ExpireStale(now time.Time, idleBefore *time.Time, seenBefore time.Time, limit int) ([]model.RoomExpiry, error)
+	// MarkOrphaned: seenBefore 以前から Unity 接続を確認できない進行中のルームを最大 limit 件切断状態にし、その ID を返す
+	MarkOrphaned(seenBefore, at time.Time, limit int) ([]string, error)
+	// TouchUnityPresence: Unity が接続中のルームを at 時点で確認済みにする
+	TouchUnityPresence(ids []string, at time.Time) error
+	// ListUncleared: 終了後に Redis のカウンタ類をまだ削除していないルームの ID (最大 limit 件)
+	ListUncleared(limit int) ([]string, error)
+	// MarkCleared: カウンタ類を削除済みにする
+	MarkCleared(ids []string, at time.Time) error
+	// ListTransitions: ルームの状態遷移履歴 (古い順)
+	ListTransitions(id string) ([]model.RoomStateTransition, error)
+	// ListByStreamer: 配信者のルームを新しい順に最大 limit 件 (before 指定時は (created_at, id) がそれより前のもののみ)
+	ListByStreamer(streamerID string, before *time.Time, beforeID string, limit int) ([]model.Room, error)
+	// UpdateSettings: settings (JSONB) が expected のままなら更新する (更新した場合 true)
+	UpdateSettings(id, settings, expected string) (bool, error)
 	Close() error
 }
 
@@ -27,12 +50,21 @@ type roomRepository struct {
 	logger *slog.Logger
 
 	// 準備済みステートメント
-	createStmt     *sqlx.Stmt
-	getStmt        *sqlx.Stmt
-	updateStmt     *sqlx.Stmt
-	deleteStmt     *sqlx.Stmt
-	markEndedStmt  *sqlx.Stmt
-	markInGameStmt *sqlx.Stmt
+	createStmt          *sqlx.Stmt
+	getStmt             *sqlx.Stmt
+	updateStmt          *sqlx.Stmt
+	deleteStmt          *sqlx.Stmt
+	settingsStmt        *sqlx.Stmt
+	transitionStmt      *sqlx.Stmt
+	startRoundStmt      *sqlx.Stmt
+	expireStmt          *sqlx.Stmt
+	expireStaleStmt     *sqlx.Stmt
+	orphanedStmt        *sqlx.Stmt
+	touchPresenceStmt   *sqlx.Stmt
+	listUnclearedStmt   *sqlx.Stmt
+	markClearedStmt     *sqlx.Stmt
+	listTransitionsStmt *sqlx.Stmt
+	listByStreamerStmt  *sqlx.Stmt
 }
 
 // NewRoomRepository: 実装生成
@@ -42,14 +74,23 @@ func NewRoomRepository(db *sqlx.DB, logger *slog.Logger) RoomRepository {
 	}
 
 	return &roomRepository{
-		db:             db,
-		logger:         logger,
-		createStmt:     mustPrepare(db, logger, queryCreateRoom),
-		getStmt:        mustPrepare(db, logger, queryGetRoom),
-		updateStmt:     mustPrepare(db, logger, queryUpdateRoom),
-		deleteStmt:     mustPrepare(db, logger, queryDeleteRoom),
-		markEndedStmt:  mustPrepare(db, logger, queryMarkEndedRoom),
-		markInGameStmt: mustPrepare(db, logger, queryMarkInGameRoom),
+		db:                  db,
+		logger:              logger,
+		createStmt:          mustPrepare(db, logger, queryCreateRoom),
+		getStmt:             mustPrepare(db, logger, queryGetRoom),
+		updateStmt:          mustPrepare(db, logger, queryUpdateRoom),
+		deleteStmt:          mustPrepare(db, logger, queryDeleteRoom),
+		settingsStmt:        mustPrepare(db, logger, queryUpdateRoomSettings),
+		transitionStmt:      mustPrepare(db, logger, queryTransitionRoom),
+		startRoundStmt:      mustPrepare(db, logger, queryStartRoomRound),
+		expireStmt:          mustPrepare(db, logger, queryExpireDisconnectedRooms),
+		expireStaleStmt:     mustPrepare(db, logger, queryExpireStaleRooms),
+		orphanedStmt:        mustPrepare(db, logger, queryMarkOrphanedRooms),
+		touchPresenceStmt:   mustPrepare(db, logger, queryTouchUnityPresence),
+		listUnclearedStmt:   mustPrepare(db, logger, queryListUnclearedRooms),
+		markClearedStmt:     mustPrepare(db, logger, queryMarkRoomsCleared),
+		listTransitionsStmt: mustPrepare(db, logger, queryListRoomTransitions),
+		listByStreamerStmt:  mustPrepare(db, logger, queryListRoomsByStreamer),
 	}
 }
 
@@ -64,7 +105,7 @@ func (r *roomRepository) Create(room *model.Room) error {
 		slog.String("room_id", room.ID),
 	)
 	start := time.Now()
-	res, err := r.createStmt.Exec(room.ID, room.StreamerID, room.CreatedAt, room.ExpiresAt, room.Status, room.Settings, room.EndedAt)
+	res, err := r.createStmt.Exec(room.ID, room.StreamerID, room.CreatedAt, room.ExpiresAt, room.Status, room.Settings, room.EndedAt, model.RoomTransitionCreated)
 	if err != nil {
 		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
 		return err
@@ -103,7 +144,7 @@ func (r *roomRepository) Update(id string, room *model.Room) error {
 		slog.String("room_id", id),
 	)
 	start := time.Now()
-	res, err := r.updateStmt.Exec(room.StreamerID, room.CreatedAt, room.ExpiresAt, room.Status, room.Settings, room.EndedAt, id)
+	res, err := r.updateStmt.Exec(room.StreamerID, room.CreatedAt, room.ExpiresAt, room.Settings, id)
 	if err != nil {
 		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
 		return err
@@ -131,14 +172,118 @@ func (r *roomRepository) Delete(id string) error {
 	return nil
 }
 
-func (r *roomRepository) MarkEnded(id string, endedAt time.Time) error {
+func (r *roomRepository) UpdateSettings(id, settings, expected string) (bool, error) {
 	logger := r.logger.With(
 		slog.String("repo", "room"),
-		slog.String("op", "mark_ended"),
+		slog.String("op", "update_settings"),
 		slog.String("room_id", id),
 	)
 	start := time.Now()
-	res, err := r.markEndedStmt.Exec("ended", endedAt, id)
+	res, err := r.settingsStmt.Exec(settings, id, expected)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return false, err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return rows > 0, nil
+}
+
+func (r *roomRepository) Transition(id string, from, to model.RoomState, at time.Time, reason string) (bool, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "transition"),
+		slog.String("room_id", id),
+		slog.String("from", string(from)),
+		slog.String("to", string(to)),
+	)
+	start := time.Now()
+	res, err := r.transitionStmt.Exec(id, from, to, at, reason)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return false, err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return rows > 0, nil
+}
+
+func (r *roomRepository) StartRound(id string, at time.Time, reason string) (int, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "start_round"),
+		slog.String("room_id", id),
+	)
+	start := time.Now()
+	var round int
+	if err := r.startRoundStmt.Get(&round, id, at, reason); err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("db.query (prepared)", slog.Bool("started", false), slog.Duration("elapsed", time.Since(start)))
+			return 0, nil
+		}
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return 0, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("round", round), slog.Duration("elapsed", time.Since(start)))
+	return round, nil
+}
+
+func (r *roomRepository) ExpireDisconnected(before, endedAt time.Time) ([]string, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "expire_disconnected"),
+	)
+	start := time.Now()
+	var ids []string
+	if err := r.expireStmt.Select(&ids, before, endedAt, model.RoomTransitionGraceExpired); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(ids)), slog.Duration("elapsed", time.Since(start)))
+	return ids, nil
+}
+
+func (r *roomRepository) ExpireStale(now time.Time, idleBefore *time.Time, seenBefore time.Time, limit int) ([]model.RoomExpiry, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "expire_stale"),
+	)
+	start := time.Now()
+	rows := []model.RoomExpiry{}
+	if err := r.expireStaleStmt.Select(&rows, now, idleBefore, seenBefore, limit, model.RoomTransitionTTLExpired, model.RoomTransitionIdleExpired); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *roomRepository) MarkOrphaned(seenBefore, at time.Time, limit int) ([]string, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "mark_orphaned"),
+	)
+	start := time.Now()
+	var ids []string
+	if err := r.orphanedStmt.Select(&ids, seenBefore, at, model.RoomTransitionUnityLost, limit); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(ids)), slog.Duration("elapsed", time.Since(start)))
+	return ids, nil
+}
+
+func (r *roomRepository) TouchUnityPresence(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "touch_unity_presence"),
+		slog.Int("room_count", len(ids)),
+	)
+	start := time.Now()
+	res, err := r.touchPresenceStmt.Exec(at, pq.Array(ids))
 	if err != nil {
 		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
 		return err
@@ -148,14 +293,32 @@ func (r *roomRepository) MarkEnded(id string, endedAt time.Time) error {
 	return nil
 }
 
-func (r *roomRepository) MarkInGame(id string) error {
+func (r *roomRepository) ListUncleared(limit int) ([]string, error) {
 	logger := r.logger.With(
 		slog.String("repo", "room"),
-		slog.String("op", "mark_in_game"),
-		slog.String("room_id", id),
+		slog.String("op", "list_uncleared"),
+	)
+	start := time.Now()
+	var ids []string
+	if err := r.listUnclearedStmt.Select(&ids, limit); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(ids)), slog.Duration("elapsed", time.Since(start)))
+	return ids, nil
+}
+
+func (r *roomRepository) MarkCleared(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "mark_cleared"),
+		slog.Int("room_count", len(ids)),
 	)
 	start := time.Now()
-	res, err := r.markInGameStmt.Exec("in_game", id)
+	res, err := r.markClearedStmt.Exec(at, pq.Array(ids))
 	if err != nil {
 		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
 		return err
@@ -165,6 +328,39 @@ func (r *roomRepository) MarkInGame(id string) error {
 	return nil
 }
 
+func (r *roomRepository) ListTransitions(id string) ([]model.RoomStateTransition, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "list_transitions"),
+		slog.String("room_id", id),
+	)
+	start := time.Now()
+	rows := []model.RoomStateTransition{}
+	if err := r.listTransitionsStmt.Select(&rows, id); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+// ListByStreamer: 配信者のルームを新しい順に取得
+func (r *roomRepository) ListByStreamer(streamerID string, before *time.Time, beforeID string, limit int) ([]model.Room, error) {
+	logger := r.logger.With(
+		slog.String("repo", "room"),
+		slog.String("op", "list_by_streamer"),
+		slog.String("streamer_id", streamerID),
+	)
+	start := time.Now()
+	rows := []model.Room{}
+	if err := r.listByStreamerStmt.Select(&rows, streamerID, before, beforeID, limit); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
 func (r *roomRepository) Close() error {
 	var firstErr error
 	closeStmt := func(s *sqlx.Stmt) {
@@ -180,8 +376,17 @@ func (r *roomRepository) Close() error {
 	closeStmt(r.getStmt)
 	closeStmt(r.updateStmt)
 	closeStmt(r.deleteStmt)
-	closeStmt(r.markEndedStmt)
-	closeStmt(r.markInGameStmt)
+	closeStmt(r.settingsStmt)
+	closeStmt(r.transitionStmt)
+	closeStmt(r.startRoundStmt)
+	closeStmt(r.expireStmt)
+	closeStmt(r.expireStaleStmt)
+	closeStmt(r.orphanedStmt)
+	closeStmt(r.touchPresenceStmt)
+	closeStmt(r.listUnclearedStmt)
+	closeStmt(r.markClearedStmt)
+	closeStmt(r.listTransitionsStmt)
+	closeStmt(r.listByStreamerStmt)
 
 	return firstErr
 }
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StreamerRepository: 配信者アカウントと API キーの永続化
+type StreamerRepository interface {
+	// Create: 配信者と最初の API キーを同一トランザクションで作成
+	Create(streamer *model.Streamer, key *model.StreamerAPIKey) error
+	Get(id string) (*model.Streamer, error) // ID取得 (存在しなければ nil)
+	CreateAPIKey(key *model.StreamerAPIKey) error
+	// Authenticate: キーのハッシュが有効なキー (未失効 / アカウント有効) なら使用時刻を at に更新して配信者を返す (無効なら nil)
+	Authenticate(keyHash string, at time.Time) (*model.Streamer, error)
+	ListAPIKeys(streamerID string) ([]model.StreamerAPIKey, error)
+	// RevokeAPIKey: 配信者自身の未失効キーを失効させる (失効させた場合 true)
+	RevokeAPIKey(streamerID, keyID string, at time.Time) (bool, error)
+	Close() error
+}
+
+type streamerRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	createStmt       *sqlx.Stmt
+	getStmt          *sqlx.Stmt
+	createKeyStmt    *sqlx.Stmt
+	authenticateStmt *sqlx.Stmt
+	listKeysStmt     *sqlx.Stmt
+	revokeKeyStmt    *sqlx.Stmt
+}
+
+// NewStreamerRepository: 実装生成
+func NewStreamerRepository(db *sqlx.DB, logger *slog.Logger) StreamerRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &streamerRepository{
+		db:               db,
+		logger:           logger,
+		createStmt:       mustPrepare(db, logger, queryCreateStreamer),
+		getStmt:          mustPrepare(db, logger, queryGetStreamer),
+		createKeyStmt:    mustPrepare(db, logger, queryCreateStreamerAPIKey),
+		authenticateStmt: mustPrepare(db, logger, queryAuthenticateStreamerAPIKey),
+		listKeysStmt:     mustPrepare(db, logger, queryListStreamerAPIKeys),
+		revokeKeyStmt:    mustPrepare(db, logger, queryRevokeStreamerAPIKey),
+	}
+}
+
+func (r *streamerRepository) Create(streamer *model.Streamer, key *model.StreamerAPIKey) error {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "create"),
+		slog.String("streamer_id", streamer.ID),
+	)
+	start := time.Now()
+	tx, err := r.db.Beginx()
+	if err != nil {
+		logger.Error("db.begin failed", slog.Any("error", err))
+		return err
+	}
+	if _, err := tx.Stmtx(r.createStmt).Exec(streamer.ID, streamer.Name, streamer.CreatedAt); err != nil {
+		_ = tx.Rollback()
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	if _, err := tx.Stmtx(r.createKeyStmt).Exec(key.ID, key.StreamerID, key.KeyHash, key.KeyPrefix, key.Label, key.CreatedAt); err != nil {
+		_ = tx.Rollback()
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("db.commit failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("db.exec", slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *streamerRepository) Get(id string) (*model.Streamer, error) {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "get"),
+		slog.String("streamer_id", id),
+	)
+	start := time.Now()
+	var s model.Streamer
+	if err := r.getStmt.Get(&s, id); err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("db.query (prepared)", slog.Bool("found", false), slog.Duration("elapsed", time.Since(start)))
+			return nil, nil
+		}
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Bool("found", true), slog.Duration("elapsed", time.Since(start)))
+	return &s, nil
+}
+
+func (r *streamerRepository) CreateAPIKey(key *model.StreamerAPIKey) error {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "create_api_key"),
+		slog.String("streamer_id", key.StreamerID),
+		slog.String("key_id", key.ID),
+	)
+	start := time.Now()
+	res, err := r.createKeyStmt.Exec(key.ID, key.StreamerID, key.KeyHash, key.KeyPrefix, key.Label, key.CreatedAt)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *streamerRepository) Authenticate(keyHash string, at time.Time) (*model.Streamer, error) {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "authenticate"),
+	)
+	start := time.Now()
+	var s model.Streamer
+	if err := r.authenticateStmt.Get(&s, keyHash, at); err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("db.query (prepared)", slog.Bool("found", false), slog.Duration("elapsed", time.Since(start)))
+			return nil, nil
+		}
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Bool("found", true), slog.String("streamer_id", s.ID), slog.Duration("elapsed", time.Since(start)))
+	return &s, nil
+}
+
+func (r *streamerRepository) ListAPIKeys(streamerID string) ([]model.StreamerAPIKey, error) {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "list_api_keys"),
+		slog.String("streamer_id", streamerID),
+	)
+	start := time.Now()
+	rows := []model.StreamerAPIKey{}
+	if err := r.listKeysStmt.Select(&rows, streamerID); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query (prepared)", slog.Int("rows", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *streamerRepository) RevokeAPIKey(streamerID, keyID string, at time.Time) (bool, error) {
+	logger := r.logger.With(
+		slog.String("repo", "streamer"),
+		slog.String("op", "revoke_api_key"),
+		slog.String("streamer_id", streamerID),
+		slog.String("key_id", keyID),
+	)
+	start := time.Now()
+	res, err := r.revokeKeyStmt.Exec(keyID, streamerID, at)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return false, err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return rows > 0, nil
+}
+
+func (r *streamerRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sqlx.Stmt{r.createStmt, r.getStmt, r.createKeyStmt, r.authenticateStmt, r.listKeysStmt, r.revokeKeyStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
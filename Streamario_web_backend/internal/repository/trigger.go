@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TriggerRepository: 閾値到達 (game_event 発動) 履歴の永続化
+type TriggerRepository interface {
+	Create(rec *model.TriggerRecord) error                              // 記録して rec.ID を設定
+	UpdateStatus(id int64, status string, at time.Time) error           // 配信状況を更新 (delivered 済みなら何もしない)
+	ListByRoom(roomID string, round int) ([]model.TriggerRecord, error) // 発動順に取得 (round = 0 なら全ラウンド)
+	Close() error
+}
+
+type triggerRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+
+	// 準備済みステートメント
+	createStmt       *sqlx.Stmt
+	updateStatusStmt *sqlx.Stmt
+	listByRoomStmt   *sqlx.Stmt
+}
+
+// NewTriggerRepository: 実装生成
+func NewTriggerRepository(db *sqlx.DB, logger *slog.Logger) TriggerRepository {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &triggerRepository{
+		db:               db,
+		logger:           logger,
+		createStmt:       mustPrepare(db, logger, queryCreateTrigger),
+		updateStatusStmt: mustPrepare(db, logger, queryUpdateTriggerStatus),
+		listByRoomStmt:   mustPrepare(db, logger, queryListTriggersByRoom),
+	}
+}
+
+func (r *triggerRepository) Create(rec *model.TriggerRecord) error {
+	if rec.SentAt.IsZero() {
+		rec.SentAt = time.Now()
+	}
+	if rec.DeliveryStatus == "" {
+		rec.DeliveryStatus = model.DeliveryStatusPending
+	}
+	logger := r.logger.With(
+		slog.String("repo", "trigger"),
+		slog.String("op", "create"),
+		slog.String("room_id", rec.RoomID),
+		slog.String("event_type", string(rec.EventType)),
+	)
+	start := time.Now()
+	if err := r.createStmt.Get(&rec.ID, rec.RoomID, rec.Round, rec.EventType, rec.TriggerCount, rec.Multiplier, rec.Level, rec.ViewerCount, rec.ViewerID, rec.ViewerName, rec.DeliveryStatus, rec.SentAt); err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("db.exec", slog.Int64("id", rec.ID), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *triggerRepository) UpdateStatus(id int64, status string, at time.Time) error {
+	logger := r.logger.With(
+		slog.String("repo", "trigger"),
+		slog.String("op", "update_status"),
+		slog.Int64("id", id),
+		slog.String("status", status),
+	)
+	start := time.Now()
+	res, err := r.updateStatusStmt.Exec(status, at, id)
+	if err != nil {
+		logger.Error("db.exec (prepared) failed", slog.Any("error", err))
+		return err
+	}
+	rows, _ := res.RowsAffected()
+	logger.Debug("db.exec", slog.Int64("rows_affected", rows), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+func (r *triggerRepository) ListByRoom(roomID string, round int) ([]model.TriggerRecord, error) {
+	rows := []model.TriggerRecord{}
+	logger := r.logger.With(
+		slog.String("repo", "trigger"),
+		slog.String("op", "list_by_room"),
+		slog.String("room_id", roomID),
+		slog.Int("round", round),
+	)
+	start := time.Now()
+	if err := r.listByRoomStmt.Select(&rows, roomID, round); err != nil {
+		logger.Error("db.query (prepared) failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("db.query", slog.Int("row_count", len(rows)), slog.Duration("elapsed", time.Since(start)))
+	return rows, nil
+}
+
+func (r *triggerRepository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sqlx.Stmt{r.createStmt, r.updateStatusStmt, r.listByRoomStmt} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
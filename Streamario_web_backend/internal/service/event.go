@@ -2,37 +2,53 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math"
+	"time"
 
 	"streamerrio-backend/internal/model"
 	"streamerrio-backend/internal/repository"
 	"streamerrio-backend/pkg/counter"
+	"streamerrio-backend/pkg/protocol"
 	"streamerrio-backend/pkg/pubsub"
 )
 
 // WebSocket 送信用インタフェース (Unity へゲームイベント通知するための最小限)
 // NOTE: Pub/Sub導入後は下位互換のため残しているが、実際は使用しない
 type WebSocketSender interface {
-	SendEventToUnity(roomID string, payload map[string]interface{}) error
+	SendToUnity(roomID string, msg protocol.Message) error
 }
 
 type EventService struct {
-	counter   counter.Counter
-	eventRepo repository.EventRepository
-	pubsub    pubsub.PubSub // Pub/Sub経由でWebSocketサーバーに配信
-	configs   map[model.EventType]*model.EventConfig
-	logger    *slog.Logger
+	counter counter.Counter
+	writer  *EventWriter  // events テーブルへの書き込みキュー
+	pubsub  pubsub.PubSub // Pub/Sub経由でWebSocketサーバーに配信
+	catalog *EventCatalog
+	logger  *slog.Logger
+
+	triggers repository.TriggerRepository // 発動履歴 (nil の場合は記録しない)
 }
 
-// NewEventService: 依存（カウンタ / リポジトリ / PubSub）を束ねてサービス生成
-func NewEventService(counter counter.Counter, eventRepo repository.EventRepository, ps pubsub.PubSub, logger *slog.Logger) *EventService {
+// NewEventService: 依存（カウンタ / 書き込みキュー / PubSub / イベントカタログ）を束ねてサービス生成
+// catalog が nil の場合は組み込みのデフォルトカタログを使用する。
+func NewEventService(counter counter.Counter, writer *EventWriter, ps pubsub.PubSub, catalog *EventCatalog, logger *slog.Logger) *EventService {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &EventService{counter: counter, eventRepo: eventRepo, pubsub: ps, configs: getDefaultEventConfigs(), logger: logger}
+	if catalog == nil {
+		catalog = DefaultEventCatalog()
+	}
+	return &EventService{counter: counter, writer: writer, pubsub: ps, catalog: catalog, logger: logger}
+}
+
+// SetTriggerRepository: 発動履歴の保存先を設定
+func (s *EventService) SetTriggerRepository(repo repository.TriggerRepository) {
+	s.triggers = repo
+}
+
+// Catalog: 有効なイベントカタログを返す
+func (s *EventService) Catalog() *EventCatalog {
+	return s.catalog
 }
 
 // JoinRoom: 視聴者がルームに参加したことを記録 (アクティブ視聴者としてカウント)
@@ -46,114 +62,188 @@ func (s *EventService) JoinRoom(roomID, viewerID string) error {
 	return nil
 }
 
-// ProcessEvent: 1イベント処理の本流 (DB保存→視聴者アクティビティ更新→カウント加算→閾値判定→発動通知/リセット)
-func (s *EventService) ProcessEvent(roomID string, PushEventMap map[model.EventType]int64, viewerID *string, viewerName *string) ([]model.EventResult, error) {
-	responses := []model.EventResult{}
+// ProcessEvent: 1イベント処理の本流 (DB書き込みキュー投入→視聴者アクティビティ更新→カウント加算→閾値判定→発動通知/リセット)
+// 閾値はルーム設定 (room.Settings) の上書きを反映した実効設定で判定する。
+func (s *EventService) ProcessEvent(room *model.Room, PushEventMap map[model.EventType]int64, viewerID *string, viewerName *string) ([]model.EventResult, error) {
+	s.recordPushes(room, PushEventMap, viewerID)
+	return s.applyPushes(room, PushEventMap, viewerID, viewerName)
+}
+
+// BufferEvent: 一時停止中 (pause_mode = buffer) の押下を記録し、カウンタには加算せず保留する
+// 保留した押下は ApplyBufferedPushes (再開時) でまとめて反映する。保留した押下数を返す。
+func (s *EventService) BufferEvent(room *model.Room, PushEventMap map[model.EventType]int64, viewerID *string) (int64, error) {
+	s.recordPushes(room, PushEventMap, viewerID)
+	counts := make(map[string]int64, len(PushEventMap))
+	var total int64
+	for et, c := range PushEventMap {
+		if c > 0 {
+			counts[string(et)] = c
+			total += c
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	if err := s.counter.AddBufferedPushes(room.ID, counts); err != nil {
+		return 0, fmt.Errorf("buffer pushes failed: %w", err)
+	}
+	// 統計ストリームに保留数の変化を反映させる
+	s.PublishViewerCount(room.ID)
+	return total, nil
+}
+
+// ApplyBufferedPushes: 一時停止中に保留した押下をカウンタへ反映する (再開時に呼ぶ)
+// 閾値判定は通常の押下と同じで、複数回分の閾値を超えた場合も game_event はイベント種別ごとに1件 (multiplier) にまとまる。
+func (s *EventService) ApplyBufferedPushes(room *model.Room) ([]model.EventResult, error) {
+	buffered, err := s.counter.TakeBufferedPushes(room.ID)
+	if err != nil {
+		return nil, fmt.Errorf("take buffered pushes failed: %w", err)
+	}
+	pushes := make(map[model.EventType]int64, len(buffered))
+	var total int64
+	for _, et := range s.catalog.Types() {
+		if c := buffered[string(et)]; c > 0 {
+			pushes[et] = c
+			total += c
+		}
+	}
+	if total == 0 {
+		s.PublishViewerCount(room.ID)
+		return []model.EventResult{}, nil
+	}
+	s.logger.Info("applying buffered pushes", slog.String("room_id", room.ID), slog.Int64("pushes", total))
+	return s.applyPushes(room, pushes, nil, nil)
+}
+
+// recordPushes: 押下を events テーブルの書き込みキューへ積み、視聴者アクティビティを更新
+func (s *EventService) recordPushes(room *model.Room, PushEventMap map[model.EventType]int64, viewerID *string) {
+	roomID := room.ID
 
 	// 1. Record events
-	// 1. Record events
-	// Async execution to improve response time
-	go func() {
-		if err := s.eventRepo.CreateEvent(roomID, PushEventMap, viewerID); err != nil {
-			s.logger.Error("record events failed", slog.String("room_id", roomID), slog.Any("error", err))
+	// 書き込みキューに積み、まとめて INSERT する (押下のあったイベント種別のみ保存)
+	recordCounts := make(map[model.EventType]int64, len(PushEventMap))
+	for et, c := range PushEventMap {
+		if c > 0 {
+			recordCounts[et] = c
 		}
-	}()
+	}
+	if err := s.writer.Enqueue(model.EventRecord{RoomID: roomID, Round: room.CurrentRound, ViewerID: viewerID, Counts: recordCounts}); err != nil {
+		s.logger.Error("record events failed", slog.String("room_id", roomID), slog.Any("error", err))
+	}
 
-	// 2. Update viewer activity (backend-agnostic)
 	// 2. Update viewer activity (backend-agnostic)
 	// NOTE: ハートビート(空のイベント)ではアクティビティを更新しない
 	// ボタン押下がある場合のみ更新する
-	hasPushEvents := false
-	for _, count := range PushEventMap {
-		if count > 0 {
-			hasPushEvents = true
-			break
-		}
-	}
-	if viewerID != nil && hasPushEvents {
+	if viewerID != nil && totalPushes(PushEventMap) > 0 {
 		_ = s.counter.UpdateViewerActivity(roomID, *viewerID)
 	}
+}
+
+// applyPushes: カウント加算→閾値判定→発動通知/リセット (viewerID / viewerName は発動履歴に残す押下者、保留分の反映では nil)
+func (s *EventService) applyPushes(room *model.Room, PushEventMap map[model.EventType]int64, viewerID *string, viewerName *string) ([]model.EventResult, error) {
+	roomID := room.ID
+	thresholds := s.resolveRoomThresholds(room)
+	responses := []model.EventResult{}
+
+	// rate 戦略用にルーム全体の押下数を記録（失敗しても処理は継続）
+	if total := totalPushes(PushEventMap); total > 0 {
+		if err := s.counter.RecordPushes(roomID, total); err != nil {
+			s.logger.Warn("record push rate failed", slog.String("room_id", roomID), slog.Any("error", err))
+		}
+	}
 
 	// Active viewer count (ループの外で一度だけ計算し、一貫性を保つ)
 	viewers := s.getActiveViewerCount(roomID)
 
 	// WebSocket 向けに視聴者数更新イベントを送信（閾値到達に関わらず常時更新）
-	{
-		payload := map[string]interface{}{
-			"type":         "viewer_count_update",
-			"room_id":      roomID,
-			"viewer_count": viewers,
-		}
-		// エラーはログ出力のみで、メイン処理は止めない
-		if msg, err := json.Marshal(payload); err == nil {
-			if err := s.pubsub.Publish(context.Background(), pubsub.ChannelGameEvents, msg); err != nil {
-				s.logger.Warn("failed to publish viewer update", slog.String("room_id", roomID), slog.Any("error", err))
-			}
-		}
-	}
+	s.publishViewerCount(roomID, viewers)
 
-	for eventType, count := range PushEventMap {
+	for _, eventType := range s.catalog.Types() {
+		count := PushEventMap[eventType]
 		// イベントがない場合はカウントしない
 		if count == 0 {
 			continue
 		}
 
-		// 3. Increment counter
-		current, err := s.counter.Increment(roomID, string(eventType), count)
+		// 3. Threshold (レベル1の閾値をルール化し、レベル倍率はカウンタ側で適用)
+		catalogCfg, _ := s.catalog.Config(eventType)
+		cfg := thresholds.apply(catalogCfg)
+		rule, _ := thresholds.rule(cfg, viewers)
+
+		// 4. Increment + threshold check (原子的に実行し、複数インスタンス間での二重発動を防ぐ)
+		tr, err := s.counter.IncrementAndCheck(roomID, string(eventType), count, rule)
 		if err != nil {
 			return nil, fmt.Errorf("increment failed: %w", err)
 		}
+		threshold := int(tr.Threshold)
 
-		// 4. Active viewer count
-		//viewers := s.getActiveViewerCount(roomID)
-
-		// 5. Threshold
-		cfg := s.configs[eventType]
-		threshold := s.calculateDynamicThreshold(cfg, viewers)
+		res := model.EventResult{EventType: eventType, CurrentCount: int(tr.Count), RequiredCount: threshold, ViewerCount: viewers, EffectTriggered: false, NextThreshold: threshold, Level: int(tr.Level), Throttled: tr.Throttled, CooldownRemainingMs: tr.CooldownRemaining.Milliseconds()}
+		if tr.Throttled {
+			// クールダウン / ルーム上限中の押下はカウンタに持ち越され、次のサイクルで発動する
+			s.logger.Debug("event trigger throttled", slog.String("room_id", roomID), slog.String("event_type", string(eventType)), slog.Int64("count", tr.Count), slog.Duration("cooldown_remaining", tr.CooldownRemaining))
+		}
 
-		res := model.EventResult{EventType: eventType, CurrentCount: int(current), RequiredCount: threshold, ViewerCount: viewers, EffectTriggered: false, NextThreshold: threshold}
+		if tr.Triggered {
+			// 複数レベル分の閾値を一度に超えた場合も game_event は1件にまとめ、multiplier で発動回数を伝える
+			firedLevel := int(tr.Level - 1)
+			res.TriggerCount = int(tr.Triggers)
+			s.logger.Info("event triggered", slog.String("room_id", roomID), slog.String("event_type", string(eventType)), slog.Int("threshold", threshold), slog.Int("level", firedLevel), slog.Int64("triggers", tr.Triggers), slog.Int64("carry_over", tr.Count), slog.Int("active_viewers", viewers))
 
-		if int(current) >= threshold {
-			s.logger.Info("event triggered", slog.String("room_id", roomID), slog.String("event_type", string(eventType)), slog.Int("count", int(current)), slog.Int("threshold", threshold), slog.Int("active_viewers", viewers))
+			// 発動履歴を先に記録し、trigger_id を通知に含めて配信状況を追跡できるようにする
+			rec := s.recordTrigger(model.TriggerRecord{
+				RoomID:       roomID,
+				Round:        room.CurrentRound,
+				EventType:    eventType,
+				TriggerCount: int(tr.Consumed + tr.Count),
+				Multiplier:   int(tr.Triggers),
+				Level:        firedLevel,
+				ViewerCount:  viewers,
+				ViewerID:     viewerID,
+				ViewerName:   viewerName,
+			})
 
-			// Pub/Sub経由で全WebSocketサーバーにブロードキャスト
-			payload := map[string]interface{}{
-				"type":          "game_event",
-				"room_id":       roomID, // WebSocketサーバー側で配信先を特定するため必須
-				"event_type":    string(eventType),
-				"trigger_count": int(current),
-				"viewer_count":  viewers,
-				"viewer_name":   viewerName,
+			// Pub/Sub経由でルームのチャネルへ配信 (Unity 接続を持つ WebSocket サーバーが購読している)
+			event := protocol.GameEvent{
+				RoomID:       roomID, // WebSocketサーバー側で配信先を特定するため必須
+				EventType:    string(eventType),
+				TriggerCount: int(tr.Consumed + tr.Count), // 到達時点のカウント (消費分 + 持ち越し分)
+				ViewerCount:  viewers,
+				ViewerName:   viewerName,
+				Level:        firedLevel,       // 今回最後に発動したレベル
+				Multiplier:   int(tr.Triggers), // 今回の発動回数 (Unity はこの回数分エフェクトを実行)
+			}
+			if rec != nil {
+				event.TriggerID = rec.ID
 			}
 
-			message, err := json.Marshal(payload)
+			message, err := protocol.Encode(event)
+			status := model.DeliveryStatusPublishFailed
 			if err != nil {
 				s.logger.Error("json marshal failed", slog.String("room_id", roomID), slog.Any("error", err))
 			} else {
 				ctx := context.Background()
-				if err := s.pubsub.Publish(ctx, pubsub.ChannelGameEvents, message); err != nil {
+				if err := s.pubsub.Publish(ctx, pubsub.RoomEventsChannel(roomID), message); err != nil {
 					s.logger.Error("pubsub publish failed", slog.String("room_id", roomID), slog.String("event_type", string(eventType)), slog.Any("error", err))
 				} else {
+					status = model.DeliveryStatusPublished
 					s.logger.Info("event published to pubsub", slog.String("room_id", roomID), slog.String("event_type", string(eventType)))
 				}
 			}
-
-			// 閾値超過分をカウントに設定（超過分を捨てない）
-			excess := current - int64(threshold)
-			if err := s.counter.SetExcess(roomID, string(eventType), excess); err != nil {
-				s.logger.Error("set excess failed", slog.String("room_id", roomID), slog.String("event_type", string(eventType)), slog.Int64("excess", excess), slog.Any("error", err))
+			if rec != nil {
+				s.updateTriggerStatus(rec.ID, status)
 			}
+
+			// 超過分はカウンタ側で持ち越し済み。次の閾値は上昇後のレベルで計算
 			res.EffectTriggered = true
-			res.NextThreshold = s.calculateDynamicThreshold(cfg, s.getActiveViewerCount(roomID))
-			res.CurrentCount = int(excess)
+			res.NextThreshold, _ = thresholds.threshold(cfg, s.getActiveViewerCount(roomID), int(tr.Level))
 
 			// 閾値到達時は、リセット後の次のサイクルに向けた残り回数と進捗率を計算
 			res.RemainingCount = res.NextThreshold - res.CurrentCount
 			res.Progress = float64(res.CurrentCount) / float64(res.NextThreshold)
 		} else {
 			// 通常時
-			res.RemainingCount = threshold - int(current)
-			res.Progress = float64(current) / float64(threshold)
+			res.RemainingCount = threshold - res.CurrentCount
+			res.Progress = float64(res.CurrentCount) / float64(threshold)
 		}
 
 		// 安全策: マイナスや1.0超過をクランプ
@@ -171,44 +261,74 @@ func (s *EventService) ProcessEvent(roomID string, PushEventMap map[model.EventT
 	return responses, nil
 }
 
-// calculateDynamicThreshold: 視聴者数に応じた動的閾値を算出し上下限でクランプ
-func (s *EventService) calculateDynamicThreshold(cfg *model.EventConfig, viewerCount int) int {
-	mult := s.getViewerMultiplier(viewerCount)
-	raw := float64(cfg.BaseThreshold) * mult
-	val := int(math.Ceil(raw))
-	if val < cfg.MinThreshold {
-		val = cfg.MinThreshold
+// totalPushes: 押下数の合計 (0 以下は数えない)
+func totalPushes(PushEventMap map[model.EventType]int64) int64 {
+	var total int64
+	for _, count := range PushEventMap {
+		if count > 0 {
+			total += count
+		}
+	}
+	return total
+}
+
+// PublishViewerCount: 現在の視聴者数を viewer_count_update としてルームのチャネルへ配信
+// 押下以外の契機 (一時停止 / 再開など) で統計ストリームの購読者に最新の統計を取り直させるのにも使う。
+func (s *EventService) PublishViewerCount(roomID string) {
+	s.publishViewerCount(roomID, s.getActiveViewerCount(roomID))
+}
+
+// publishViewerCount: viewer_count_update を配信 (エラーはログ出力のみで、メイン処理は止めない)
+func (s *EventService) publishViewerCount(roomID string, viewers int) {
+	msg, err := protocol.Encode(protocol.ViewerCountUpdate{RoomID: roomID, ViewerCount: viewers})
+	if err != nil {
+		return
+	}
+	if err := s.pubsub.Publish(context.Background(), pubsub.RoomEventsChannel(roomID), msg); err != nil {
+		s.logger.Warn("failed to publish viewer update", slog.String("room_id", roomID), slog.Any("error", err))
+	}
+}
+
+// recordTrigger: 発動履歴を保存 (失敗しても発動通知は止めない。保存できなかった場合は nil)
+func (s *EventService) recordTrigger(rec model.TriggerRecord) *model.TriggerRecord {
+	if s.triggers == nil {
+		return nil
 	}
-	if val > cfg.MaxThreshold {
-		val = cfg.MaxThreshold
+	if err := s.triggers.Create(&rec); err != nil {
+		s.logger.Error("record trigger failed", slog.String("room_id", rec.RoomID), slog.String("event_type", string(rec.EventType)), slog.Any("error", err))
+		return nil
 	}
-	return val
+	return &rec
 }
 
-// getViewerMultiplier: 視聴者数帯ごとの倍率テーブル
-func (s *EventService) getViewerMultiplier(v int) float64 {
-	switch {
-	case v <= 5:
-		return 1.0
-	case v <= 10:
-		return 1.2
-	case v <= 20:
-		return 1.5
-	case v <= 50:
-		return 2.0
-	case v <= 100:
-		return 3.0
-	case v <= 150:
-		return 4.0
-	case v <= 200:
-		return 5.0
-	case v <= 300:
-		return 6.0
-	default:
-		return 7.0
+// updateTriggerStatus: 発動履歴の配信状況を更新 (失敗はログのみ)
+func (s *EventService) updateTriggerStatus(id int64, status string) {
+	if err := s.triggers.UpdateStatus(id, status, time.Now()); err != nil {
+		s.logger.Warn("update trigger status failed", slog.Int64("trigger_id", id), slog.String("status", status), slog.Any("error", err))
 	}
 }
 
+// ListTriggers: ルームの発動履歴を発動順に返却 (round = 0 なら全ラウンド)
+func (s *EventService) ListTriggers(roomID string, round int) ([]model.TriggerRecord, error) {
+	if s.triggers == nil {
+		return []model.TriggerRecord{}, nil
+	}
+	recs, err := s.triggers.ListByRoom(roomID, round)
+	if err != nil {
+		return nil, fmt.Errorf("list triggers failed: %w", err)
+	}
+	return recs, nil
+}
+
+// levelOf: カウンタから取得したレベルを int に変換 (未取得 / 不正値はレベル1)
+func levelOf(levels map[string]int64, et model.EventType) int {
+	lv := levels[string(et)]
+	if lv < 1 {
+		return 1
+	}
+	return int(lv)
+}
+
 // getActiveViewerCount: アクティブ視聴者数取得 (0 やエラー時は 1 にフォールバック)
 func (s *EventService) getActiveViewerCount(roomID string) int {
 	c, err := s.counter.GetActiveViewerCount(roomID)
@@ -221,26 +341,41 @@ func (s *EventService) getActiveViewerCount(roomID string) int {
 	return int(c)
 }
 
-// GetRoomStats: 全イベント種別について現在カウントと閾値をまとめて返却
-func (s *EventService) GetRoomStats(roomID string) ([]model.RoomEventStat, error) {
+// GetRoomStats: カタログの全イベント種別について現在カウントと閾値をカタログ順にまとめて返却
+func (s *EventService) GetRoomStats(room *model.Room) ([]model.RoomEventStat, error) {
+	roomID := room.ID
+	thresholds := s.resolveRoomThresholds(room)
 	viewers := s.getActiveViewerCount(roomID)
-	
-	// Prepare event types for batch retrieval
-	eventTypes := make([]string, 0, len(s.configs))
-	for et := range s.configs {
-		eventTypes = append(eventTypes, string(et))
-	}
 
 	// Batch get counts from Redis
-	counts, err := s.counter.GetMulti(roomID, eventTypes)
+	counts, err := s.counter.GetMulti(roomID, s.catalog.TypeStrings())
 	if err != nil {
 		return nil, fmt.Errorf("get counters failed: %w", err)
 	}
+	levels, err := s.counter.GetLevels(roomID, s.catalog.TypeStrings())
+	if err != nil {
+		return nil, fmt.Errorf("get levels failed: %w", err)
+	}
+	cooldowns, err := s.counter.GetCooldowns(roomID, s.catalog.TypeStrings())
+	if err != nil {
+		return nil, fmt.Errorf("get cooldowns failed: %w", err)
+	}
+	// 一時停止中は保留している押下数も返す (再開時にまとめて反映される分)
+	var buffered map[string]int64
+	if room.Status == model.RoomStatePaused {
+		if buffered, err = s.counter.GetBufferedPushes(roomID); err != nil {
+			return nil, fmt.Errorf("get buffered pushes failed: %w", err)
+		}
+	}
 
-	stats := make([]model.RoomEventStat, 0, len(s.configs))
-	for et, cfg := range s.configs {
+	entries := s.catalog.Entries()
+	stats := make([]model.RoomEventStat, 0, len(entries))
+	for _, catalogCfg := range entries {
+		cfg := thresholds.apply(catalogCfg)
+		et := cfg.EventType
 		cur := counts[string(et)]
-		th := s.calculateDynamicThreshold(cfg, viewers)
+		level := levelOf(levels, et)
+		th, mult := thresholds.threshold(cfg, viewers, level)
 
 		// 残り回数と進捗率の計算
 		rem := th - int(cur)
@@ -256,28 +391,20 @@ func (s *EventService) GetRoomStats(roomID string) ([]model.RoomEventStat, error
 		}
 
 		stats = append(stats, model.RoomEventStat{
-			EventType:      et,
-			CurrentCount:   int(cur),
-			CurrentLevel:   1,
-			RequiredCount:  th,
-			RemainingCount: rem,
-			Progress:       prog,
-			NextThreshold:  th,
-			ViewerCount:    viewers,
+			EventType:           et,
+			CurrentCount:        int(cur),
+			CurrentLevel:        level,
+			RequiredCount:       th,
+			RemainingCount:      rem,
+			Progress:            prog,
+			NextThreshold:       th,
+			ViewerCount:         viewers,
+			ThresholdStrategy:   thresholds.strategy.Name(),
+			ThresholdMultiplier: mult,
+			PushRate:            thresholds.pushRate,
+			CooldownRemainingMs: cooldowns[string(et)].Milliseconds(),
+			BufferedCount:       int(buffered[string(et)]),
 		})
 	}
 	return stats, nil
 }
-
-// Default configs (unchanged thresholds foundation)
-// getDefaultEventConfigs: 初期閾値設定マップ生成
-func getDefaultEventConfigs() map[model.EventType]*model.EventConfig {
-	return map[model.EventType]*model.EventConfig{
-		model.SKILL1: {EventType: model.SKILL1, BaseThreshold: 5, MinThreshold: 3, MaxThreshold: 50, LevelMultiplier: 1.3},
-		model.SKILL2: {EventType: model.SKILL2, BaseThreshold: 8, MinThreshold: 4, MaxThreshold: 60, LevelMultiplier: 1.3},
-		model.SKILL3: {EventType: model.SKILL3, BaseThreshold: 8, MinThreshold: 8, MaxThreshold: 100, LevelMultiplier: 1.4},
-		model.ENEMY1: {EventType: model.ENEMY1, BaseThreshold: 5, MinThreshold: 4, MaxThreshold: 45, LevelMultiplier: 1.3},
-		model.ENEMY2: {EventType: model.ENEMY2, BaseThreshold: 10, MinThreshold: 5, MaxThreshold: 55, LevelMultiplier: 1.4},
-		model.ENEMY3: {EventType: model.ENEMY3, BaseThreshold: 17, MinThreshold: 6, MaxThreshold: 80, LevelMultiplier: 1.5},
-	}
-}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+)
+
+// EventCatalog: 有効なイベント種別とその表示情報・閾値設定の一覧 (生成後は読み取り専用)
+// バリデーション / カウント / 永続化 / 結果集計はすべてこのカタログに従う。
+type EventCatalog struct {
+	entries []model.EventConfig
+	index   map[model.EventType]int
+}
+
+// NewEventCatalog: エントリを検証してカタログを生成 (sort_order → event_type 順に整列)
+func NewEventCatalog(entries []model.EventConfig) (*EventCatalog, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("event catalog is empty")
+	}
+	normalized := make([]model.EventConfig, 0, len(entries))
+	seen := make(map[model.EventType]struct{}, len(entries))
+	for _, e := range entries {
+		e.EventType = model.EventType(strings.TrimSpace(string(e.EventType)))
+		if e.EventType == "" {
+			return nil, errors.New("event_type is required")
+		}
+		if _, dup := seen[e.EventType]; dup {
+			return nil, fmt.Errorf("duplicate event_type %q", e.EventType)
+		}
+		seen[e.EventType] = struct{}{}
+		if e.Category == "" {
+			e.Category = model.EventCategoryOther
+		}
+		if !e.Category.Valid() {
+			return nil, fmt.Errorf("event_type %q: invalid category %q", e.EventType, e.Category)
+		}
+		if e.DisplayName == "" {
+			e.DisplayName = string(e.EventType)
+		}
+		if e.BaseThreshold <= 0 || e.MinThreshold <= 0 {
+			return nil, fmt.Errorf("event_type %q: thresholds must be positive", e.EventType)
+		}
+		if e.MaxThreshold < e.MinThreshold {
+			return nil, fmt.Errorf("event_type %q: max_threshold must be >= min_threshold", e.EventType)
+		}
+		if e.CooldownMs < 0 {
+			return nil, fmt.Errorf("event_type %q: cooldown_ms must not be negative", e.EventType)
+		}
+		// 省略 (0) は倍率なし。1 未満 (閾値が下がっていく) は DB の制約と同じく拒否する
+		if e.LevelMultiplier == 0 {
+			e.LevelMultiplier = 1.0
+		}
+		if e.LevelMultiplier < 1 {
+			return nil, fmt.Errorf("event_type %q: level_multiplier must be >= 1", e.EventType)
+		}
+		normalized = append(normalized, e)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		if normalized[i].SortOrder != normalized[j].SortOrder {
+			return normalized[i].SortOrder < normalized[j].SortOrder
+		}
+		return normalized[i].EventType < normalized[j].EventType
+	})
+	index := make(map[model.EventType]int, len(normalized))
+	for i, e := range normalized {
+		index[e.EventType] = i
+	}
+	return &EventCatalog{entries: normalized, index: index}, nil
+}
+
+// LoadEventCatalog: 設定ファイル → DB → 組み込みデフォルトの順でカタログを読み込む
+// path が指定されていればファイルを優先し、DB が空の場合はデフォルトの6種にフォールバックする。
+func LoadEventCatalog(path string, repo repository.EventCatalogRepository, logger *slog.Logger) (*EventCatalog, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read event catalog file: %w", err)
+		}
+		var entries []model.EventConfig
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("parse event catalog file: %w", err)
+		}
+		catalog, err := NewEventCatalog(entries)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("event catalog loaded", slog.String("source", "file"), slog.String("path", path), slog.Int("event_types", len(catalog.entries)))
+		return catalog, nil
+	}
+	if repo != nil {
+		entries, err := repo.List()
+		if err != nil {
+			return nil, fmt.Errorf("load event catalog: %w", err)
+		}
+		if len(entries) > 0 {
+			catalog, err := NewEventCatalog(entries)
+			if err != nil {
+				return nil, err
+			}
+			logger.Info("event catalog loaded", slog.String("source", "database"), slog.Int("event_types", len(catalog.entries)))
+			return catalog, nil
+		}
+	}
+	logger.Warn("event catalog not configured, falling back to defaults")
+	return DefaultEventCatalog(), nil
+}
+
+// DefaultEventCatalog: 組み込みの初期カタログ (skill1..3 / enemy1..3)
+func DefaultEventCatalog() *EventCatalog {
+	catalog, err := NewEventCatalog([]model.EventConfig{
+		{EventType: model.SKILL1, Category: model.EventCategorySkill, DisplayName: "スキル1", SortOrder: 10, BaseThreshold: 5, MinThreshold: 3, MaxThreshold: 50, LevelMultiplier: 1.3},
+		{EventType: model.SKILL2, Category: model.EventCategorySkill, DisplayName: "スキル2", SortOrder: 20, BaseThreshold: 8, MinThreshold: 4, MaxThreshold: 60, LevelMultiplier: 1.3},
+		{EventType: model.SKILL3, Category: model.EventCategorySkill, DisplayName: "スキル3", SortOrder: 30, BaseThreshold: 8, MinThreshold: 8, MaxThreshold: 100, LevelMultiplier: 1.4},
+		{EventType: model.ENEMY1, Category: model.EventCategoryEnemy, DisplayName: "敵1", SortOrder: 40, BaseThreshold: 5, MinThreshold: 4, MaxThreshold: 45, LevelMultiplier: 1.3},
+		{EventType: model.ENEMY2, Category: model.EventCategoryEnemy, DisplayName: "敵2", SortOrder: 50, BaseThreshold: 10, MinThreshold: 5, MaxThreshold: 55, LevelMultiplier: 1.4},
+		{EventType: model.ENEMY3, Category: model.EventCategoryEnemy, DisplayName: "敵3", SortOrder: 60, BaseThreshold: 17, MinThreshold: 6, MaxThreshold: 80, LevelMultiplier: 1.5},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// Types: カタログ順のイベント種別一覧
+func (c *EventCatalog) Types() []model.EventType {
+	types := make([]model.EventType, len(c.entries))
+	for i, e := range c.entries {
+		types[i] = e.EventType
+	}
+	return types
+}
+
+// TypeStrings: カウンタ API 向けに文字列化したイベント種別一覧
+func (c *EventCatalog) TypeStrings() []string {
+	types := make([]string, len(c.entries))
+	for i, e := range c.entries {
+		types[i] = string(e.EventType)
+	}
+	return types
+}
+
+// Entries: カタログ全体のコピー
+func (c *EventCatalog) Entries() []model.EventConfig {
+	return append([]model.EventConfig(nil), c.entries...)
+}
+
+// Contains: カタログに定義されたイベント種別か
+func (c *EventCatalog) Contains(et model.EventType) bool {
+	_, ok := c.index[et]
+	return ok
+}
+
+// Config: イベント種別の設定 (コピー) を取得
+func (c *EventCatalog) Config(et model.EventType) (model.EventConfig, bool) {
+	i, ok := c.index[et]
+	if !ok {
+		return model.EventConfig{}, false
+	}
+	return c.entries[i], true
+}
+
+// Category: イベント種別のカテゴリ (未定義なら other)
+func (c *EventCatalog) Category(et model.EventType) model.EventCategory {
+	if i, ok := c.index[et]; ok {
+		return c.entries[i].Category
+	}
+	return model.EventCategoryOther
+}
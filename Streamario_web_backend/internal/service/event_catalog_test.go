@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"streamerrio-backend/internal/model"
+)
+
+func TestNewEventCatalog_LevelMultiplier(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   float64
+		want    float64
+		wantErr bool
+	}{
+		{"omitted defaults to 1.0", 0, 1.0, false},
+		{"exactly 1", 1.0, 1.0, false},
+		{"above 1", 1.3, 1.3, false},
+		{"below 1", 0.5, 0, true},
+		{"negative", -1, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			catalog, err := NewEventCatalog([]model.EventConfig{
+				{EventType: model.SKILL1, BaseThreshold: 5, MinThreshold: 3, MaxThreshold: 50, LevelMultiplier: tc.value},
+			})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cfg, _ := catalog.Config(model.SKILL1)
+			if cfg.LevelMultiplier != tc.want {
+				t.Errorf("expected level_multiplier %v, got %v", tc.want, cfg.LevelMultiplier)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/pkg/counter"
+	"streamerrio-backend/pkg/pubsub"
+)
+
+// EventFlusher: ルームの書き込み待ちイベントを DB に反映させる
+type EventFlusher interface {
+	FlushRoom(ctx context.Context, roomID string) error
+}
+
+// RemoteEventFlusher: 別プロセス (API サーバ) の EventWriter にフラッシュを依頼し、
+// カウンタ上の書き込み待ち数が 0 になるまで待つ (Unity WebSocket サーバの EndGame 用)。
+type RemoteEventFlusher struct {
+	ps           pubsub.PubSub
+	counter      counter.Counter
+	pollInterval time.Duration
+	logger       *slog.Logger
+}
+
+// NewRemoteEventFlusher: 生成
+func NewRemoteEventFlusher(ps pubsub.PubSub, c counter.Counter, logger *slog.Logger) *RemoteEventFlusher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RemoteEventFlusher{ps: ps, counter: c, pollInterval: 50 * time.Millisecond, logger: logger}
+}
+
+// FlushRoom: フラッシュ要求を配信し、書き込み待ちが無くなるか ctx の期限まで待つ
+func (f *RemoteEventFlusher) FlushRoom(ctx context.Context, roomID string) error {
+	msg, err := json.Marshal(map[string]string{"type": "event_flush", "room_id": roomID})
+	if err != nil {
+		return err
+	}
+	if err := f.ps.Publish(ctx, pubsub.ChannelEventFlush, msg); err != nil {
+		// 依頼できなくても FlushInterval ごとに書き込まれるため、待機は続ける
+		f.logger.Warn("publish flush request failed", slog.String("room_id", roomID), slog.Any("error", err))
+	}
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+	for {
+		pending, err := f.counter.GetPendingWrites(roomID)
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			f.logger.Warn("flush wait timed out", slog.String("room_id", roomID), slog.Int64("pending_writes", pending))
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+	"streamerrio-backend/pkg/counter"
+	"streamerrio-backend/pkg/pubsub"
+)
+
+// ErrEventWriterClosed: 停止後に書き込もうとした
+var ErrEventWriterClosed = errors.New("event writer closed")
+
+// EventWriterConfig: 書き込みキューの設定
+type EventWriterConfig struct {
+	QueueSize     int           // キュー容量 (満杯時は呼び出し元で同期書き込み)
+	BatchSize     int           // 1回の INSERT にまとめる最大行数
+	FlushInterval time.Duration // バッチが埋まらなくても書き込む間隔
+	MaxRetries    int           // 書き込み失敗時の再試行回数
+	RetryBackoff  time.Duration // 再試行の初回待ち時間 (以降倍々)
+}
+
+// withDefaults: 未設定 / 不正値を既定値で補完
+func (c EventWriterConfig) withDefaults() EventWriterConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 200
+	}
+	if c.BatchSize > 5000 { // 1 INSERT あたりのバインド変数上限 (65535) を超えないように
+		c.BatchSize = 5000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 500 * time.Millisecond
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 100 * time.Millisecond
+	}
+	return c
+}
+
+// EventWriter: events テーブルへの write-behind キュー
+// ProcessEvent から受け取った押下を溜め、複数行 INSERT でまとめて保存する。
+// ルームごとの書き込み待ち数をカウンタ (Redis) に記録し、別プロセスの EndGame が完了を待てるようにする。
+type EventWriter struct {
+	repo    repository.EventRepository
+	counter counter.Counter
+	cfg     EventWriterConfig
+	logger  *slog.Logger
+
+	queue   chan model.EventRecord
+	flushCh chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex // closed と queue への送信を保護
+	closed bool
+	depth  atomic.Int64
+}
+
+// NewEventWriter: 生成して書き込みループを開始
+func NewEventWriter(repo repository.EventRepository, c counter.Counter, cfg EventWriterConfig, logger *slog.Logger) *EventWriter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cfg = cfg.withDefaults()
+	w := &EventWriter{
+		repo:    repo,
+		counter: c,
+		cfg:     cfg,
+		logger:  logger,
+		queue:   make(chan model.EventRecord, cfg.QueueSize),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Enqueue: 1リクエスト分の押下をキューへ積む
+// キューが満杯の場合は呼び出し元の goroutine で同期的に書き込む (取りこぼさないためのバックプレッシャ)。
+// 同期書き込みはロックを手放してから行い (再試行の待ちで Close を止めない)、Close はその完了も待つ。
+func (w *EventWriter) Enqueue(rec model.EventRecord) error {
+	if rec.TriggeredAt.IsZero() {
+		rec.TriggeredAt = time.Now()
+	}
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrEventWriterClosed
+	}
+	w.addPending(rec.RoomID, 1)
+	select {
+	case w.queue <- rec:
+		w.depth.Add(1)
+		w.mu.RUnlock()
+		return nil
+	default:
+	}
+	w.wg.Add(1)
+	w.mu.RUnlock()
+	defer w.wg.Done()
+
+	w.logger.Warn("event queue full, writing synchronously", slog.String("room_id", rec.RoomID), slog.Int("queue_size", w.cfg.QueueSize))
+	return w.write([]model.EventRecord{rec})
+}
+
+// Depth: キューに溜まっている件数
+func (w *EventWriter) Depth() int {
+	return int(w.depth.Load())
+}
+
+// FlushRoom: キューに溜まっている分を即時に書き込む (EventFlusher 実装)
+// 書き込みループは単一なので、指定ルームに限らずその時点のキュー全体を書き込む。
+func (w *EventWriter) FlushRoom(ctx context.Context, roomID string) error {
+	ack := make(chan struct{})
+	select {
+	case w.flushCh <- ack:
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ListenFlushRequests: 他プロセスからのフラッシュ要求 (ChannelEventFlush) を購読して FlushRoom を実行 (ブロッキング)
+func (w *EventWriter) ListenFlushRequests(ctx context.Context, ps pubsub.PubSub) error {
+	return ps.Subscribe(ctx, pubsub.ChannelEventFlush, func(channel string, message []byte) error {
+		var req struct {
+			RoomID string `json:"room_id"`
+		}
+		if err := json.Unmarshal(message, &req); err != nil {
+			w.logger.Warn("invalid flush request", slog.Any("error", err))
+			return err
+		}
+		if err := w.FlushRoom(ctx, req.RoomID); err != nil {
+			w.logger.Warn("flush on request failed", slog.String("room_id", req.RoomID), slog.Any("error", err))
+			return err
+		}
+		w.logger.Debug("flushed on request", slog.String("room_id", req.RoomID))
+		return nil
+	})
+}
+
+// Close: 新規受付を止め、キューの残りと実行中の同期書き込みを書き切ってから終了 (ctx の期限まで待つ)
+func (w *EventWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		w.logger.Info("event writer drained")
+		return nil
+	case <-ctx.Done():
+		w.logger.Error("event writer drain timed out", slog.Int("queue_depth", w.Depth()))
+		return ctx.Err()
+	}
+}
+
+// run: キューからバッチを組み立てて書き込むループ (BatchSize 到達 / FlushInterval 経過 / フラッシュ要求で書き込む)
+func (w *EventWriter) run() {
+	defer w.wg.Done()
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]model.EventRecord, 0, w.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		_ = w.write(batch)
+		w.depth.Add(-int64(len(batch)))
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case rec, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= w.cfg.BatchSize {
+				flush()
+			}
+		case ack := <-w.flushCh:
+			// その時点でキューにある分も取り込んでから書き込む
+			for drained := false; !drained; {
+				select {
+				case rec, ok := <-w.queue:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, rec)
+					if len(batch) >= w.cfg.BatchSize {
+						flush()
+					}
+				default:
+					drained = true
+				}
+			}
+			flush()
+			close(ack)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// write: 指数バックオフで再試行しながら書き込み、結果に関わらず書き込み待ち数を減らす
+// 再試行し尽くした行は破棄し、内容をエラーログに残す。
+func (w *EventWriter) write(records []model.EventRecord) error {
+	var err error
+	backoff := w.cfg.RetryBackoff
+	for attempt := 0; attempt <= w.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = w.repo.CreateEvents(records); err == nil {
+			break
+		}
+		w.logger.Warn("write events failed", slog.Int("attempt", attempt+1), slog.Int("record_count", len(records)), slog.Any("error", err))
+	}
+	if err != nil {
+		w.logger.Error("events dropped after retries", slog.Int("record_count", len(records)), slog.Any("records", records), slog.Any("error", err))
+	}
+	perRoom := make(map[string]int64)
+	for _, rec := range records {
+		perRoom[rec.RoomID]++
+	}
+	for roomID, n := range perRoom {
+		w.addPending(roomID, -n)
+	}
+	return err
+}
+
+func (w *EventWriter) addPending(roomID string, delta int64) {
+	if err := w.counter.AddPendingWrites(roomID, delta); err != nil {
+		w.logger.Warn("update pending writes failed", slog.String("room_id", roomID), slog.Int64("delta", delta), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"streamerrio-backend/internal/repository"
+	"streamerrio-backend/pkg/counter"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// roomJanitorLease: ジャニターの排他に使うリース名 (複数インスタンスのうち1つだけが実行する)
+const roomJanitorLease = "room_janitor"
+
+// RoomJanitor: ルームの期限切れ / 無操作 / Unity 不在を定期的に判定して終了させ、終了したルームの Redis キーを掃除する
+// 同時実行はリース (leases テーブル、期限は DB の時刻で判定) で1インスタンスに絞る。
+// ルームの判定は RunOnce に渡した時刻と各インスタンスが書き込んだ時刻 (unity_seen_at など) を比べるため、
+// インスタンス間の時計は NTP 等で同期している前提。
+type RoomJanitor struct {
+	rooms    *RoomService
+	leases   repository.LeaseRepository
+	counter  counter.Counter
+	catalog  *EventCatalog
+	interval time.Duration
+	batch    int
+	holder   string
+	logger   *slog.Logger
+}
+
+// RoomJanitorResult: 1回の実行で処理した件数
+type RoomJanitorResult struct {
+	Leader       bool // リースを取得して実行したか (false なら他のインスタンスが担当)
+	Disconnected int  // 再接続猶予切れで終了
+	Expired      int  // TTL / 無操作で終了
+	Orphaned     int  // Unity 不在で切断状態へ
+	Cleared      int  // Redis キーを削除
+}
+
+// NewRoomJanitor: 生成 (interval は実行間隔、batch は1回に処理する最大件数)
+func NewRoomJanitor(rooms *RoomService, leases repository.LeaseRepository, c counter.Counter, catalog *EventCatalog, interval time.Duration, batch int, logger *slog.Logger) *RoomJanitor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if catalog == nil {
+		catalog = DefaultEventCatalog()
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batch <= 0 {
+		batch = 100
+	}
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("%s-%d-%s", host, os.Getpid(), ulid.MustNew(ulid.Now(), rand.Reader).String())
+	return &RoomJanitor{
+		rooms:    rooms,
+		leases:   leases,
+		counter:  c,
+		catalog:  catalog,
+		interval: interval,
+		batch:    batch,
+		holder:   holder,
+		logger:   logger,
+	}
+}
+
+// Run: interval ごとに RunOnce を実行する (ctx がキャンセルされるまでブロック、終了時にリースを手放す)
+func (j *RoomJanitor) Run(ctx context.Context) {
+	defer func() {
+		if err := j.leases.Release(roomJanitorLease, j.holder); err != nil {
+			j.logger.Warn("room janitor lease release failed", slog.Any("error", err))
+		}
+	}()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.RunOnce(time.Now()); err != nil {
+				j.logger.Warn("room janitor run failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// RunOnce: リースを取得できた場合のみ1回分の掃除を行う
+// リースの期限は実行間隔の3倍 (保持者が2回続けて実行できなければ他のインスタンスが引き継ぐ)。
+func (j *RoomJanitor) RunOnce(now time.Time) (RoomJanitorResult, error) {
+	var res RoomJanitorResult
+	ok, err := j.leases.Acquire(roomJanitorLease, j.holder, 3*j.interval)
+	if err != nil {
+		return res, fmt.Errorf("acquire lease: %w", err)
+	}
+	if !ok {
+		return res, nil
+	}
+	res.Leader = true
+
+	// 1. 再接続猶予切れ (Unity WebSocket サーバーが止まっていても終了させる)
+	if j.rooms.ReconnectGrace() > 0 {
+		ids, err := j.rooms.ExpireDisconnected(now)
+		if err != nil {
+			return res, fmt.Errorf("expire disconnected: %w", err)
+		}
+		res.Disconnected = len(ids)
+		for _, id := range ids {
+			j.logger.Info("room ended after reconnect grace", slog.String("room_id", id))
+		}
+	}
+
+	// 2. TTL / 無操作
+	expired, err := j.rooms.ExpireStale(now, j.batch)
+	if err != nil {
+		return res, fmt.Errorf("expire stale: %w", err)
+	}
+	res.Expired = len(expired)
+	for _, e := range expired {
+		j.logger.Info("room expired", slog.String("room_id", e.ID), slog.String("from", string(e.FromState)), slog.String("to", string(e.ToState)), slog.String("reason", e.Reason))
+	}
+
+	// 3. Unity 不在 (接続を持っていたインスタンスが落ちた等) → 切断状態にして再接続を待つ
+	orphaned, err := j.rooms.MarkOrphaned(now, j.batch)
+	if err != nil {
+		return res, fmt.Errorf("mark orphaned: %w", err)
+	}
+	res.Orphaned = len(orphaned)
+	for _, id := range orphaned {
+		j.logger.Info("room lost unity presence, waiting for reconnect", slog.String("room_id", id))
+	}
+
+	// 4. 終了済みルームの Redis キー削除 (失敗したルームは次回に再試行)
+	ids, err := j.rooms.ListUncleared(j.batch)
+	if err != nil {
+		return res, fmt.Errorf("list uncleared: %w", err)
+	}
+	types := j.catalog.TypeStrings()
+	cleared := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err := j.counter.DeleteRoom(id, types); err != nil {
+			j.logger.Warn("room counter cleanup failed", slog.String("room_id", id), slog.Any("error", err))
+			continue
+		}
+		cleared = append(cleared, id)
+	}
+	if len(cleared) > 0 {
+		if err := j.rooms.MarkCleared(cleared, now); err != nil {
+			return res, fmt.Errorf("mark cleared: %w", err)
+		}
+	}
+	res.Cleared = len(cleared)
+
+	if res.Disconnected+res.Expired+res.Orphaned+res.Cleared > 0 {
+		j.logger.Info("room janitor run",
+			slog.Int("disconnected", res.Disconnected),
+			slog.Int("expired", res.Expired),
+			slog.Int("orphaned", res.Orphaned),
+			slog.Int("cleared", res.Cleared))
+	}
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+	"streamerrio-backend/pkg/counter"
+)
+
+// RateLimitConfig: 視聴者 (または IP) ごとのトークンバケット設定
+type RateLimitConfig struct {
+	Requests counter.TokenBucket // イベント送信リクエスト数 / 秒
+	Pushes   counter.TokenBucket // ボタン押下数 / 秒
+}
+
+// RateLimiter: イベント送信の連打対策
+// バケットはカウンタ (Redis) に置き、複数 API インスタンス間で共有する。
+type RateLimiter struct {
+	counter counter.Counter
+	logRepo repository.RateLimitLogRepository
+	catalog *EventCatalog
+	cfg     RateLimitConfig
+	logger  *slog.Logger
+}
+
+// PushLimitResult: 押下数制限の適用結果
+type PushLimitResult struct {
+	Accepted   map[model.EventType]int64 // 受け付けた押下数 (イベント種別ごと)
+	Dropped    int64                     // 削った押下数の合計
+	RetryAfter time.Duration             // 削った分が補充されるまでの時間
+}
+
+// NewRateLimiter: 生成 (logRepo が nil の場合は記録しない)
+func NewRateLimiter(c counter.Counter, logRepo repository.RateLimitLogRepository, catalog *EventCatalog, cfg RateLimitConfig, logger *slog.Logger) *RateLimiter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if catalog == nil {
+		catalog = DefaultEventCatalog()
+	}
+	return &RateLimiter{counter: c, logRepo: logRepo, catalog: catalog, cfg: cfg, logger: logger}
+}
+
+func bucketKey(roomID, clientKey, kind string) string {
+	return fmt.Sprintf("room:%s:%s:%s", roomID, clientKey, kind)
+}
+
+// AllowRequest: リクエスト数の上限を確認 (超過時は false と再試行までの時間)
+// カウンタ障害時は制限せずに通す。
+func (l *RateLimiter) AllowRequest(roomID, clientKey string) (bool, time.Duration) {
+	res, err := l.counter.TakeTokens(bucketKey(roomID, clientKey, "req"), l.cfg.Requests, 1)
+	if err != nil {
+		l.logger.Warn("rate limit check failed, allowing request", slog.String("room_id", roomID), slog.String("client_key", clientKey), slog.Any("error", err))
+		return true, 0
+	}
+	if res.Granted < 1 {
+		return false, res.RetryAfter
+	}
+	return true, 0
+}
+
+// LimitPushes: 押下数の上限を適用し、受け付ける押下数をカタログ順に割り当てる
+// カウンタ障害時は全量を受け付ける。
+func (l *RateLimiter) LimitPushes(roomID, clientKey string, pushes map[model.EventType]int64) PushLimitResult {
+	var total int64
+	for _, n := range pushes {
+		total += n
+	}
+	result := PushLimitResult{Accepted: pushes}
+	if total == 0 {
+		return result
+	}
+	res, err := l.counter.TakeTokens(bucketKey(roomID, clientKey, "push"), l.cfg.Pushes, total)
+	if err != nil {
+		l.logger.Warn("push rate limit check failed, allowing pushes", slog.String("room_id", roomID), slog.String("client_key", clientKey), slog.Any("error", err))
+		return result
+	}
+	if res.Granted >= total {
+		return result
+	}
+	accepted := make(map[model.EventType]int64, len(pushes))
+	remaining := res.Granted
+	for _, et := range l.catalog.Types() {
+		n, ok := pushes[et]
+		if !ok {
+			continue
+		}
+		take := n
+		if take > remaining {
+			take = remaining
+		}
+		accepted[et] = take
+		remaining -= take
+	}
+	result.Accepted = accepted
+	result.Dropped = total - res.Granted
+	result.RetryAfter = res.RetryAfter
+	return result
+}
+
+// Record: 削った押下を非同期で記録 (失敗はログのみ)
+func (l *RateLimiter) Record(roomID, clientKey string, viewerID *string, reason string, requested, accepted map[model.EventType]int64) {
+	if l.logRepo == nil {
+		return
+	}
+	now := time.Now()
+	entries := make([]model.RateLimitedPush, 0, len(requested))
+	for _, et := range l.catalog.Types() {
+		req := requested[et]
+		acc := accepted[et]
+		if req <= acc {
+			continue
+		}
+		entries = append(entries, model.RateLimitedPush{
+			RoomID:    roomID,
+			ViewerID:  viewerID,
+			ClientKey: clientKey,
+			EventType: et,
+			Requested: req,
+			Accepted:  acc,
+			Reason:    reason,
+			CreatedAt: now,
+		})
+	}
+	if len(entries) == 0 {
+		return
+	}
+	l.logger.Info("pushes rate limited", slog.String("room_id", roomID), slog.String("client_key", clientKey), slog.String("reason", reason), slog.Int("event_types", len(entries)))
+	go func() {
+		if err := l.logRepo.Record(entries); err != nil {
+			l.logger.Error("record rate limited pushes failed", slog.String("room_id", roomID), slog.Any("error", err))
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"streamerrio-backend/pkg/protocol"
+)
+
+// RelayFieldType: relay メッセージのフィールド型
+type RelayFieldType string
+
+const (
+	RelayFieldString RelayFieldType = "string"
+	RelayFieldInt    RelayFieldType = "int"
+	RelayFieldBool   RelayFieldType = "bool"
+)
+
+// RelayField: relay メッセージの1フィールドの定義
+// Min / Max は int なら値の範囲、string なら文字数の範囲 (0 は制限なし)。Enum が空でなければ値を限定する。
+type RelayField struct {
+	Name     string
+	Type     RelayFieldType
+	Required bool
+	Min      int64
+	Max      int64
+	Enum     []string
+}
+
+// RelayMessageSchema: relay で送れるメッセージタイプ1件の定義 (type と room_id 以外のフィールド)
+type RelayMessageSchema struct {
+	Type   string
+	Fields []RelayField
+}
+
+// RelayMessageRegistry: relay で Unity へ送ってよいメッセージタイプとスキーマの一覧
+// 登録されていないタイプ / 定義にないフィールドは拒否する (seq や trigger_id などサーバが付けるフィールドを偽装させない)。
+type RelayMessageRegistry struct {
+	schemas map[string]RelayMessageSchema
+}
+
+// NewRelayMessageRegistry: Unity が解釈できるメッセージ (game_event / viewer_count_update) を登録して生成
+// game_event の event_type はイベントカタログの種別に限定する。
+func NewRelayMessageRegistry(catalog *EventCatalog) *RelayMessageRegistry {
+	r := &RelayMessageRegistry{schemas: make(map[string]RelayMessageSchema)}
+	var eventTypes []string
+	if catalog != nil {
+		eventTypes = catalog.TypeStrings()
+	}
+	r.Register(RelayMessageSchema{
+		Type: string(protocol.TypeGameEvent),
+		Fields: []RelayField{
+			{Name: "event_type", Type: RelayFieldString, Required: true, Enum: eventTypes},
+			{Name: "trigger_count", Type: RelayFieldInt, Min: 0, Max: 1000000},
+			{Name: "multiplier", Type: RelayFieldInt, Min: 1, Max: 100},
+			{Name: "level", Type: RelayFieldInt, Min: 1, Max: 1000},
+			{Name: "viewer_count", Type: RelayFieldInt, Min: 0, Max: 1000000},
+			{Name: "viewer_name", Type: RelayFieldString, Max: 64},
+		},
+	})
+	r.Register(RelayMessageSchema{
+		Type: string(protocol.TypeViewerCountUpdate),
+		Fields: []RelayField{
+			{Name: "viewer_count", Type: RelayFieldInt, Required: true, Min: 0, Max: 1000000},
+		},
+	})
+	return r
+}
+
+// Register: メッセージタイプを追加 (同じタイプは上書き)
+func (r *RelayMessageRegistry) Register(schema RelayMessageSchema) {
+	r.schemas[schema.Type] = schema
+}
+
+// Types: 登録済みのメッセージタイプ (名前順)
+func (r *RelayMessageRegistry) Types() []string {
+	types := make([]string, 0, len(r.schemas))
+	for t := range r.schemas {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Validate: type が登録済みで、各フィールドが定義どおりか検証 (room_id は呼び出し側で取り除いておく)
+// version はプロトコルの版 (protocol.Version) と一致する場合のみ受け付ける。
+func (r *RelayMessageRegistry) Validate(payload map[string]interface{}) error {
+	msgType, ok := payload["type"].(string)
+	if !ok || msgType == "" {
+		return fmt.Errorf("type is required")
+	}
+	schema, ok := r.schemas[msgType]
+	if !ok {
+		return fmt.Errorf("message type not allowed: %s", msgType)
+	}
+	fields := make(map[string]RelayField, len(schema.Fields))
+	for _, f := range schema.Fields {
+		fields[f.Name] = f
+	}
+	if v, present := payload["version"]; present {
+		if n, ok := v.(float64); !ok || int(n) != protocol.Version {
+			return fmt.Errorf("unsupported version: %v", v)
+		}
+	}
+	for name := range payload {
+		if name == "type" || name == "version" {
+			continue
+		}
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("field not allowed for %s: %s", msgType, name)
+		}
+	}
+	for _, f := range schema.Fields {
+		v, present := payload[f.Name]
+		if !present || v == nil {
+			if f.Required {
+				return fmt.Errorf("%s is required", f.Name)
+			}
+			continue
+		}
+		if err := f.validate(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate: JSON からデコードした値 (数値は float64) を検証
+func (f RelayField) validate(v interface{}) error {
+	switch f.Type {
+	case RelayFieldString:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", f.Name)
+		}
+		n := int64(len([]rune(s)))
+		if (f.Min > 0 && n < f.Min) || (f.Max > 0 && n > f.Max) {
+			return fmt.Errorf("%s length must be between %d and %d", f.Name, f.Min, f.Max)
+		}
+		if len(f.Enum) > 0 {
+			for _, e := range f.Enum {
+				if s == e {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s must be one of %v", f.Name, f.Enum)
+		}
+	case RelayFieldInt:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s must be an integer", f.Name)
+		}
+		if int64(n) < f.Min || (f.Max > 0 && int64(n) > f.Max) {
+			return fmt.Errorf("%s must be between %d and %d", f.Name, f.Min, f.Max)
+		}
+	case RelayFieldBool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", f.Name)
+		}
+	default:
+		return fmt.Errorf("unknown field type for %s: %s", f.Name, f.Type)
+	}
+	return nil
+}
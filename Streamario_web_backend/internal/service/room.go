@@ -3,6 +3,7 @@ package service
 import (
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"time"
 
 	"streamerrio-backend/internal/config"
@@ -12,7 +13,16 @@ import (
 	"github.com/oklog/ulid/v2"
 )
 
-// RoomService: ルームのライフサイクル管理 (取得/生成/存在保証)
+var (
+	// ErrInvalidRoomTransition: 定義されていない状態遷移 (終了済みルームの再開など)
+	ErrInvalidRoomTransition = errors.New("invalid room state transition")
+	// ErrRoomStateConflict: 遷移の直前に別の処理が状態を変えた (条件付き UPDATE が0件)
+	ErrRoomStateConflict = errors.New("room state changed concurrently")
+	// ErrRoomSettingsConflict: 読み込んでから保存するまでに別の更新が設定を変えた
+	ErrRoomSettingsConflict = errors.New("room settings changed concurrently")
+)
+
+// RoomService: ルームのライフサイクル管理 (取得/生成/存在保証/状態遷移)
 type RoomService struct {
 	repo repository.RoomRepository
 	cfg  *config.Config
@@ -49,27 +59,53 @@ func (s *RoomService) GetRoom(id string) (*model.Room, error) {
 	return room, nil
 }
 
+// FindRoom: 期限切れを含めて取得 (存在しなければ nil、所有者による履歴参照用)
+func (s *RoomService) FindRoom(id string) (*model.Room, error) {
+	return s.repo.Get(id)
+}
+
+// initialSettings: 新規ルームの設定を生成 (閾値戦略はここで確定し、以後変更しない)
+// thresholdStrategy が空なら設定の既定戦略を使う。
+func (s *RoomService) initialSettings(thresholdStrategy string) (string, error) {
+	if thresholdStrategy == "" && s.cfg != nil {
+		thresholdStrategy = s.cfg.DefaultThresholdStrategy
+	}
+	if thresholdStrategy == "" {
+		thresholdStrategy = ThresholdStrategyStep
+	}
+	if _, err := NewThresholdStrategy(thresholdStrategy, nil); err != nil {
+		return "", err
+	}
+	return model.RoomSettings{ThresholdStrategy: thresholdStrategy}.Encode()
+}
+
 // GenerateRoom: ULIDを用いて新規ルームを生成し保存
 func (s *RoomService) GenerateRoom(streamerID string) (*model.Room, error) {
+	settings, err := s.initialSettings("")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 	entropy := ulid.Monotonic(rand.Reader, 0)
-	id := ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+	id := ulid.MustNew(ulid.Timestamp(now), entropy).String()
 	room := &model.Room{
 		ID:         id,
 		StreamerID: streamerID,
-		CreatedAt:  time.Now(),
-		Status:     "active",
-		Settings:   "{}",
+		CreatedAt:  now,
+		ExpiresAt:  s.expiresAt(now),
+		Status:     model.RoomStateCreated,
+		Settings:   settings,
 		EndedAt:    nil,
 	}
-	// TTL 機能は現状未実装 (Config 拡張で追加予定)
 	if err := s.repo.Create(room); err != nil {
 		return nil, err
 	}
 	return room, nil
 }
 
-// CreateIfNotExists: (WebSocket発行IDをDBへ確定させる用途) 存在しなければ指定 streamerID で作成
-func (s *RoomService) CreateIfNotExists(id, streamerID string) error {
+// CreateIfNotExists: (WebSocket発行IDをDBへ確定させる用途) 存在しなければ指定 streamerID / 閾値戦略で作成
+// Unity の接続時に呼ぶため waiting で作成する。既存ルームの場合、閾値戦略は変更しない。
+func (s *RoomService) CreateIfNotExists(id, streamerID, thresholdStrategy string) error {
 	existing, err := s.repo.Get(id)
 	if err != nil {
 		return err
@@ -77,21 +113,265 @@ func (s *RoomService) CreateIfNotExists(id, streamerID string) error {
 	if existing != nil {
 		return nil
 	}
+	settings, err := s.initialSettings(thresholdStrategy)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.repo.Create(&model.Room{ID: id, StreamerID: streamerID, CreatedAt: now, ExpiresAt: s.expiresAt(now), Status: model.RoomStateWaiting, Settings: settings, EndedAt: nil})
+}
+
+// expiresAt: 作成時刻から ROOM_TTL 後の有効期限 (TTL 0 なら無期限で nil)
+func (s *RoomService) expiresAt(createdAt time.Time) *time.Time {
+	if s.cfg == nil || s.cfg.RoomTTL <= 0 {
+		return nil
+	}
+	t := createdAt.Add(s.cfg.RoomTTL)
+	return &t
+}
+
+// Transition: 現在の状態が from の場合のみ to へ遷移し、遷移履歴を記録
+// 定義にない遷移は ErrInvalidRoomTransition、その間に状態が変わっていれば ErrRoomStateConflict を返す。
+func (s *RoomService) Transition(id string, from, to model.RoomState, at time.Time, reason string) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidRoomTransition, from, to)
+	}
+	ok, err := s.repo.Transition(id, from, to, at, reason)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: expected %s", ErrRoomStateConflict, from)
+	}
+	return nil
+}
+
+// transitionTo: 現在の状態を読み、to へ遷移 (既に to なら何もしない)
+func (s *RoomService) transitionTo(id string, to model.RoomState, at time.Time, reason string) error {
+	room, err := s.repo.Get(id)
+	if err != nil {
+		return err
+	}
+	if room == nil {
+		return errors.New("room not found")
+	}
+	if room.Status == to {
+		return nil
+	}
+	return s.Transition(id, room.Status, to, at, reason)
+}
+
+// MarkEnded: ルームを終了状態へ遷移 (終了済みなら何もしない)
+func (s *RoomService) MarkEnded(id string, endedAt time.Time, reason string) error {
+	return s.transitionTo(id, model.RoomStateEnded, endedAt, reason)
+}
+
+// MarkInGame: ルームをゲーム中へ遷移し、ラウンド番号と新しいラウンドを開始したかを返す
+// waiting からは次のラウンドを開始し、それ以外 (paused など) からは同じラウンドを続ける。既にゲーム中なら何もしない。
+func (s *RoomService) MarkInGame(id string) (int, bool, error) {
+	room, err := s.repo.Get(id)
+	if err != nil {
+		return 0, false, err
+	}
+	if room == nil {
+		return 0, false, errors.New("room not found")
+	}
+	now := time.Now()
+	switch room.Status {
+	case model.RoomStateInGame:
+		return room.CurrentRound, false, nil
+	case model.RoomStateWaiting:
+		round, err := s.repo.StartRound(id, now, model.RoomTransitionGameStart)
+		if err != nil {
+			return 0, false, err
+		}
+		if round == 0 {
+			return 0, false, fmt.Errorf("%w: expected %s", ErrRoomStateConflict, room.Status)
+		}
+		return round, true, nil
+	default:
+		if err := s.Transition(id, room.Status, model.RoomStateInGame, now, model.RoomTransitionGameStart); err != nil {
+			return 0, false, err
+		}
+		return room.CurrentRound, false, nil
+	}
+}
+
+// MarkPaused: ゲーム中のルームを一時停止へ遷移 (既に一時停止中なら false)
+func (s *RoomService) MarkPaused(id string) (bool, error) {
+	return s.markPlaying(id, model.RoomStateInGame, model.RoomStatePaused, model.RoomTransitionGamePause)
+}
+
+// MarkResumed: 一時停止中のルームをゲーム中へ戻す (既にゲーム中なら false)
+func (s *RoomService) MarkResumed(id string) (bool, error) {
+	return s.markPlaying(id, model.RoomStatePaused, model.RoomStateInGame, model.RoomTransitionGameResume)
+}
+
+// markPlaying: ラウンド中の in_game ⇄ paused の遷移 (from 以外の状態からは拒否、既に to なら何もしない)
+// disconnected → paused / waiting → in_game のような再接続・ラウンド開始の遷移をここで起こさないよう from を限定する。
+func (s *RoomService) markPlaying(id string, from, to model.RoomState, reason string) (bool, error) {
+	room, err := s.repo.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if room == nil {
+		return false, errors.New("room not found")
+	}
+	if room.Status == to {
+		return false, nil
+	}
+	if room.Status != from {
+		return false, fmt.Errorf("%w: %s -> %s", ErrInvalidRoomTransition, room.Status, to)
+	}
+	if err := s.Transition(id, from, to, time.Now(), reason); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MarkDisconnected: Unity の切断でルームを切断状態へ遷移 (進行中のルームのみ。遷移した場合 true)
+func (s *RoomService) MarkDisconnected(id string, at time.Time) (bool, error) {
+	room, err := s.repo.Get(id)
+	if err != nil || room == nil {
+		return false, err
+	}
+	if !room.Status.CanTransitionTo(model.RoomStateDisconnected) {
+		return false, nil
+	}
+	err = s.Transition(id, room.Status, model.RoomStateDisconnected, at, model.RoomTransitionDisconnected)
+	if errors.Is(err, ErrRoomStateConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MarkReconnected: Unity の接続でルームを使える状態へ遷移
+// 切断中なら切断前の状態へ戻して true を返す。作成済み (Unity 未接続) のルームは waiting にする。
+func (s *RoomService) MarkReconnected(id string) (bool, error) {
+	room, err := s.repo.Get(id)
+	if err != nil || room == nil {
+		return false, err
+	}
 	now := time.Now()
-	return s.repo.Create(&model.Room{ID: id, StreamerID: streamerID, CreatedAt: now, Status: "active", Settings: "{}", EndedAt: nil})
+	switch room.Status {
+	case model.RoomStateCreated:
+		return false, s.Transition(id, room.Status, model.RoomStateWaiting, now, model.RoomTransitionUnityConnected)
+	case model.RoomStateDisconnected:
+		resume := model.RoomStateWaiting
+		if room.ResumeStatus != nil && room.Status.CanTransitionTo(*room.ResumeStatus) {
+			resume = *room.ResumeStatus
+		}
+		err := s.Transition(id, room.Status, resume, now, model.RoomTransitionReconnected)
+		if errors.Is(err, ErrRoomStateConflict) {
+			// 猶予切れで終了した直後など
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, nil
+	}
+}
+
+// StateHistory: ルームの状態遷移履歴 (古い順)
+func (s *RoomService) StateHistory(id string) ([]model.RoomStateTransition, error) {
+	return s.repo.ListTransitions(id)
+}
+
+// ReconnectGrace: 切断から終了扱いにするまでの猶予 (0 なら切断で即終了)
+func (s *RoomService) ReconnectGrace() time.Duration {
+	if s.cfg == nil || s.cfg.UnityReconnectGrace < 0 {
+		return 0
+	}
+	return s.cfg.UnityReconnectGrace
+}
+
+// ReconnectRemaining: 切断中のルームが終了扱いになるまでの残り時間
+func (s *RoomService) ReconnectRemaining(room *model.Room, now time.Time) time.Duration {
+	if room == nil || room.DisconnectedAt == nil {
+		return 0
+	}
+	remaining := room.DisconnectedAt.Add(s.ReconnectGrace()).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// ExpireDisconnected: 猶予を過ぎても再接続しなかったルームを終了状態にし、その ID を返す
+func (s *RoomService) ExpireDisconnected(now time.Time) ([]string, error) {
+	return s.repo.ExpireDisconnected(now.Add(-s.ReconnectGrace()), now)
+}
+
+// ListByStreamer: 配信者のルームを新しい順に取得 (limit は 1〜100 に丸める)
+// 続きは直前のページ末尾の created_at / id を before / beforeID に渡す (beforeID が空なら before より前に作成したもののみ)。
+func (s *RoomService) ListByStreamer(streamerID string, before *time.Time, beforeID string, limit int) ([]model.Room, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListByStreamer(streamerID, before, beforeID, limit)
+}
+
+// PresenceTimeout: Unity 接続の確認がこの時間途絶えたルームを切断扱いにする
+func (s *RoomService) PresenceTimeout() time.Duration {
+	if s.cfg == nil || s.cfg.UnityPresenceTimeout <= 0 {
+		return 2 * time.Minute
+	}
+	return s.cfg.UnityPresenceTimeout
 }
 
-// MarkEnded: ルームを終了状態へ更新
-func (s *RoomService) MarkEnded(id string, endedAt time.Time) error {
-	return s.repo.MarkEnded(id, endedAt)
+// TouchUnityPresence: Unity が接続中のルームを確認済みにする (WebSocket サーバーが定期的に呼ぶ)
+func (s *RoomService) TouchUnityPresence(ids []string, now time.Time) error {
+	return s.repo.TouchUnityPresence(ids, now)
+}
+
+// ExpireStale: 有効期限切れ / 無操作のルームを最大 limit 件終了させる
+// 無操作は Unity が接続していない (PresenceTimeout 内に確認できない) ルームのみ対象。
+func (s *RoomService) ExpireStale(now time.Time, limit int) ([]model.RoomExpiry, error) {
+	var idleBefore *time.Time
+	if s.cfg != nil && s.cfg.RoomIdleTimeout > 0 {
+		t := now.Add(-s.cfg.RoomIdleTimeout)
+		idleBefore = &t
+	}
+	return s.repo.ExpireStale(now, idleBefore, now.Add(-s.PresenceTimeout()), limit)
 }
 
-// MarkInGame: ルームをゲーム開始状態へ更新
-func (s *RoomService) MarkInGame(id string) error {
-	return s.repo.MarkInGame(id)
+// MarkOrphaned: Unity 接続の確認が途絶えた進行中のルームを最大 limit 件切断状態 (再接続待ち) にし、その ID を返す
+// 以後は通常の切断と同じく、猶予内に再接続されなければ ExpireDisconnected で終了する。
+func (s *RoomService) MarkOrphaned(now time.Time, limit int) ([]string, error) {
+	return s.repo.MarkOrphaned(now.Add(-s.PresenceTimeout()), now, limit)
+}
+
+// ListUncleared: 終了後に Redis のカウンタ類をまだ削除していないルームの ID
+func (s *RoomService) ListUncleared(limit int) ([]string, error) {
+	return s.repo.ListUncleared(limit)
+}
+
+// MarkCleared: カウンタ類を削除済みにする
+func (s *RoomService) MarkCleared(ids []string, now time.Time) error {
+	return s.repo.MarkCleared(ids, now)
+}
+
+// UpdateSettings: ルーム設定 (rooms.settings) を保存
+// expected は更新の元にした settings (Room.Settings)。その後に別の更新が入っていれば ErrRoomSettingsConflict を返す。
+func (s *RoomService) UpdateSettings(id string, settings model.RoomSettings, expected string) error {
+	raw, err := settings.Encode()
+	if err != nil {
+		return err
+	}
+	ok, err := s.repo.UpdateSettings(id, raw, expected)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRoomSettingsConflict
+	}
+	return nil
 }
 
-// UpdateRoom: ルームを更新
+// UpdateRoom: ルームを更新 (状態は変更しない。状態は Transition で遷移させる)
 func (s *RoomService) UpdateRoom(id string, room *model.Room) error {
 	return s.repo.Update(id, room)
 }
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/pkg/protocol"
+	"streamerrio-backend/pkg/pubsub"
+)
+
+// ルームストリームで配信するイベント名
+const (
+	RoomStreamEventStats   = "stats_update"                 // 視聴者数 + 進捗 (間引いて配信)
+	RoomStreamEventTrigger = string(protocol.TypeGameEvent) // 閾値到達 (即時配信)
+)
+
+// RoomStreamMessage: ストリーム購読者へ送る1件 (SSE の event / data に対応)
+type RoomStreamMessage struct {
+	Event string
+	Data  []byte
+}
+
+// RoomStreamSubscription: 1接続分の購読
+type RoomStreamSubscription struct {
+	roomID string
+	ch     chan RoomStreamMessage
+}
+
+// C: 配信メッセージを受け取るチャネル (購読解除で close される)
+func (s *RoomStreamSubscription) C() <-chan RoomStreamMessage {
+	return s.ch
+}
+
+// RoomStreamHub: 購読者のいるルームのチャネル (room:{id}:events) を購読し、視聴者向けに中継する
+// 閾値到達はそのまま即時に流し、viewer_count_update などの更新はルームごとに interval 単位でまとめて
+// 最新の統計 (RoomEventStat) を1件だけ送る。押下が集中しても購読者あたりの配信頻度は一定に保たれる。
+type RoomStreamHub struct {
+	ps       pubsub.PubSub
+	rooms    *RoomService
+	events   *EventService
+	interval time.Duration
+	buffer   int
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	subs   map[string]map[*RoomStreamSubscription]struct{}
+	dirty  map[string]struct{} // 前回配信以降に更新があったルーム
+	closed bool
+
+	chMu    sync.Mutex          // ルームチャネルの購読変更を直列化
+	roomSub pubsub.Subscription // Run 中のみ設定
+}
+
+// NewRoomStreamHub: 生成 (interval はルームあたりの統計配信の最短間隔)
+func NewRoomStreamHub(ps pubsub.PubSub, rooms *RoomService, events *EventService, interval time.Duration, logger *slog.Logger) *RoomStreamHub {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	return &RoomStreamHub{
+		ps:       ps,
+		rooms:    rooms,
+		events:   events,
+		interval: interval,
+		buffer:   32,
+		logger:   logger,
+		subs:     make(map[string]map[*RoomStreamSubscription]struct{}),
+		dirty:    make(map[string]struct{}),
+	}
+}
+
+// Subscribe: ルームの購読を開始 (戻り値の関数で解除)
+// ハブ停止後に呼んだ場合は close 済みのチャネルを返す。
+func (h *RoomStreamHub) Subscribe(roomID string) (*RoomStreamSubscription, func()) {
+	sub := &RoomStreamSubscription{roomID: roomID, ch: make(chan RoomStreamMessage, h.buffer)}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(sub.ch)
+		return sub, func() {}
+	}
+	if h.subs[roomID] == nil {
+		h.subs[roomID] = make(map[*RoomStreamSubscription]struct{})
+	}
+	h.subs[roomID][sub] = struct{}{}
+	h.mu.Unlock()
+	h.syncRoomChannel(roomID)
+
+	return sub, func() {
+		h.mu.Lock()
+		if _, ok := h.subs[roomID][sub]; !ok {
+			h.mu.Unlock()
+			return // 停止時に解除済み
+		}
+		delete(h.subs[roomID], sub)
+		if len(h.subs[roomID]) == 0 {
+			delete(h.subs, roomID)
+			delete(h.dirty, roomID)
+		}
+		close(sub.ch)
+		h.mu.Unlock()
+		h.syncRoomChannel(roomID)
+	}
+}
+
+// syncRoomChannel: 購読者の有無に合わせてルームチャネルを購読 / 解除
+// 購読と解除が並行しても最後の状態に揃うよう、chMu の中で購読者数を読み直す。
+func (h *RoomStreamHub) syncRoomChannel(roomID string) {
+	h.chMu.Lock()
+	defer h.chMu.Unlock()
+	if h.roomSub == nil {
+		return // Run 開始時に購読者のいるルームをまとめて購読する
+	}
+	h.mu.Lock()
+	want := len(h.subs[roomID]) > 0
+	h.mu.Unlock()
+
+	channel := pubsub.RoomEventsChannel(roomID)
+	ctx := context.Background()
+	if want {
+		if err := h.roomSub.Subscribe(ctx, channel); err != nil {
+			h.logger.Error("room channel subscribe failed", slog.String("room_id", roomID), slog.Any("error", err))
+		}
+		return
+	}
+	if err := h.roomSub.Unsubscribe(ctx, channel); err != nil {
+		h.logger.Warn("room channel unsubscribe failed", slog.String("room_id", roomID), slog.Any("error", err))
+	}
+}
+
+// Snapshot: 購読開始直後に送る現在の統計
+func (h *RoomStreamHub) Snapshot(room *model.Room) (RoomStreamMessage, error) {
+	stats, err := h.events.GetRoomStats(room)
+	if err != nil {
+		return RoomStreamMessage{}, err
+	}
+	return h.statsMessage(room, stats)
+}
+
+// Run: Pub/Sub 購読と間引き配信ループを開始 (ctx がキャンセルされるまでブロック)
+// 終了時は全購読のチャネルを close し、SSE 接続を閉じさせる。
+func (h *RoomStreamHub) Run(ctx context.Context) error {
+	defer h.closeAll()
+	sub, err := h.ps.Multiplex(ctx, h.handleMessage)
+	if err != nil {
+		return err
+	}
+	go h.flushLoop(ctx)
+
+	// Run 開始前に購読者のいたルームをまとめて購読
+	h.chMu.Lock()
+	h.roomSub = sub
+	h.mu.Lock()
+	channels := make([]string, 0, len(h.subs))
+	for roomID := range h.subs {
+		channels = append(channels, pubsub.RoomEventsChannel(roomID))
+	}
+	h.mu.Unlock()
+	if err := sub.Subscribe(ctx, channels...); err != nil {
+		h.logger.Error("room channel subscribe failed", slog.Int("room_count", len(channels)), slog.Any("error", err))
+	}
+	h.chMu.Unlock()
+
+	<-ctx.Done()
+	h.chMu.Lock()
+	h.roomSub = nil
+	h.chMu.Unlock()
+	return sub.Close()
+}
+
+// closeAll: 全購読を解除して以後の購読を受け付けない
+func (h *RoomStreamHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			close(sub.ch)
+		}
+	}
+	h.subs = make(map[string]map[*RoomStreamSubscription]struct{})
+	h.dirty = make(map[string]struct{})
+}
+
+// handleMessage: game_event は即時中継、それ以外はルームを更新ありとして次の tick で統計を配信
+func (h *RoomStreamHub) handleMessage(channel string, message []byte) error {
+	header, msg, err := protocol.Decode(message)
+	if err != nil {
+		h.logger.Warn("room stream message decode failed", slog.Any("error", err))
+		return err
+	}
+	rm, ok := msg.(protocol.RoomMessage)
+	if !ok {
+		return nil
+	}
+	roomID := rm.MessageRoomID()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs[roomID]) == 0 {
+		return nil
+	}
+	if header.Type == protocol.TypeGameEvent {
+		h.broadcastLocked(roomID, RoomStreamMessage{Event: RoomStreamEventTrigger, Data: message})
+	}
+	// 発動でも進捗が変わるため統計は常に更新対象にする
+	h.dirty[roomID] = struct{}{}
+	return nil
+}
+
+// flushLoop: interval ごとに更新のあったルームの統計を取り直して配信
+func (h *RoomStreamHub) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			roomIDs := make([]string, 0, len(h.dirty))
+			for roomID := range h.dirty {
+				roomIDs = append(roomIDs, roomID)
+			}
+			h.dirty = make(map[string]struct{})
+			h.mu.Unlock()
+
+			for _, roomID := range roomIDs {
+				h.publishStats(roomID)
+			}
+		}
+	}
+}
+
+// publishStats: ルームの統計を取得して購読者へ配信 (失敗はログのみ)
+func (h *RoomStreamHub) publishStats(roomID string) {
+	room, err := h.rooms.GetRoom(roomID)
+	if err != nil || room == nil {
+		h.logger.Warn("room stream get room failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return
+	}
+	msg, err := h.Snapshot(room)
+	if err != nil {
+		h.logger.Warn("room stream get stats failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return
+	}
+	h.mu.Lock()
+	h.broadcastLocked(roomID, msg)
+	h.mu.Unlock()
+}
+
+func (h *RoomStreamHub) statsMessage(room *model.Room, stats []model.RoomEventStat) (RoomStreamMessage, error) {
+	viewers := 0
+	if len(stats) > 0 {
+		viewers = stats[0].ViewerCount
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":         RoomStreamEventStats,
+		"room_id":      room.ID,
+		"room_status":  room.Status,
+		"viewer_count": viewers,
+		"stats":        stats,
+		"time":         time.Now(),
+	})
+	if err != nil {
+		return RoomStreamMessage{}, err
+	}
+	return RoomStreamMessage{Event: RoomStreamEventStats, Data: data}, nil
+}
+
+// broadcastLocked: ルームの購読者へ送信 (h.mu を保持して呼ぶ)
+// 受信が追いつかない購読者の分は捨てる (統計は次の配信で最新に追いつく)。
+func (h *RoomStreamHub) broadcastLocked(roomID string, msg RoomStreamMessage) {
+	for sub := range h.subs[roomID] {
+		select {
+		case sub.ch <- msg:
+		default:
+			h.logger.Debug("room stream subscriber lagging, message dropped", slog.String("room_id", roomID), slog.String("event", msg.Event))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+)
+
+// transitionRoomRepository: Transition の条件付き UPDATE だけをメモリ上で再現する RoomRepository
+// (他のメソッドは呼ばれない前提で、埋め込んだ nil のインタフェースに任せる)
+type transitionRoomRepository struct {
+	repository.RoomRepository
+	status map[string]model.RoomState
+	calls  int
+}
+
+func (r *transitionRoomRepository) Transition(id string, from, to model.RoomState, at time.Time, reason string) (bool, error) {
+	r.calls++
+	if r.status[id] != from {
+		return false, nil
+	}
+	r.status[id] = to
+	return true, nil
+}
+
+func TestRoomService_Transition(t *testing.T) {
+	cases := []struct {
+		name    string
+		current model.RoomState // DB 上の状態
+		from    model.RoomState // 呼び出し側が読んだ状態
+		to      model.RoomState
+		wantErr error
+	}{
+		{"reconnect to waiting", model.RoomStateDisconnected, model.RoomStateDisconnected, model.RoomStateWaiting, nil},
+		{"resume from pause", model.RoomStatePaused, model.RoomStatePaused, model.RoomStateInGame, nil},
+		{"new round", model.RoomStateWaiting, model.RoomStateWaiting, model.RoomStateInGame, nil},
+		{"ended cannot restart", model.RoomStateEnded, model.RoomStateEnded, model.RoomStateInGame, ErrInvalidRoomTransition},
+		{"expired cannot wait", model.RoomStateExpired, model.RoomStateExpired, model.RoomStateWaiting, ErrInvalidRoomTransition},
+		// 読んだ後に別の処理が終了させた: 遷移自体は定義済みでも条件付き UPDATE が0件になる
+		{"ended concurrently", model.RoomStateEnded, model.RoomStatePaused, model.RoomStateInGame, ErrRoomStateConflict},
+		{"reconnected concurrently", model.RoomStateWaiting, model.RoomStateDisconnected, model.RoomStateWaiting, ErrRoomStateConflict},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &transitionRoomRepository{status: map[string]model.RoomState{"room-1": tc.current}}
+			s := NewRoomService(repo, nil)
+			err := s.Transition("room-1", tc.from, tc.to, time.Now(), "test")
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if repo.status["room-1"] != tc.to {
+					t.Errorf("expected status %s, got %s", tc.to, repo.status["room-1"])
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+			if repo.status["room-1"] != tc.current {
+				t.Errorf("status must stay %s, got %s", tc.current, repo.status["room-1"])
+			}
+			// 定義にない遷移は DB まで行かない
+			if errors.Is(tc.wantErr, ErrInvalidRoomTransition) && repo.calls != 0 {
+				t.Errorf("expected no repository call, got %d", repo.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// roomTokenVersion: トークン形式の版 (形式を変える場合に旧トークンと区別する)
+const roomTokenVersion = "v1"
+
+// RoomTokenService: Unity 接続の所有証明に使うルームトークンの発行 / 検証
+// トークンは room_id に対する HMAC-SHA256 で、DB に保存しなくても検証できる。
+// ルーム作成時に Unity へ渡し、同じ room_id での再接続時に提示させる。
+type RoomTokenService struct {
+	secret []byte
+}
+
+// NewRoomTokenService: 署名鍵を指定して生成
+func NewRoomTokenService(secret string) (*RoomTokenService, error) {
+	if strings.TrimSpace(secret) == "" {
+		return nil, errors.New("room token secret is required")
+	}
+	return &RoomTokenService{secret: []byte(secret)}, nil
+}
+
+// NewRandomRoomTokenService: ランダムな署名鍵で生成 (署名鍵が未設定の場合用)
+// 鍵はプロセス内にしか無いため、再起動すると発行済みトークンは無効になり、複数インスタンス間でも共有されない。
+func NewRandomRoomTokenService() (*RoomTokenService, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &RoomTokenService{secret: secret}, nil
+}
+
+// Issue: ルームのトークンを発行 (同じ room_id には常に同じトークンを返す)
+func (s *RoomTokenService) Issue(roomID string) string {
+	return roomTokenVersion + "." + base64.RawURLEncoding.EncodeToString(s.sign(roomID))
+}
+
+// Verify: トークンがルームに対して発行されたものか検証
+func (s *RoomTokenService) Verify(roomID, token string) bool {
+	if roomID == "" || token == "" {
+		return false
+	}
+	version, encoded, ok := strings.Cut(token, ".")
+	if !ok || version != roomTokenVersion {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, s.sign(roomID))
+}
+
+func (s *RoomTokenService) sign(roomID string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("unity-room:" + roomID))
+	return mac.Sum(nil)
+}
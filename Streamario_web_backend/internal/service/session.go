@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -9,26 +10,122 @@ import (
 	"streamerrio-backend/internal/model"
 	"streamerrio-backend/internal/repository"
 	"streamerrio-backend/pkg/counter"
+	"streamerrio-backend/pkg/protocol"
 )
 
-// GameSessionService: ゲーム開始〜終了の境界を跨ぐ処理を担当
+var (
+	// ErrRoundNotFound: 指定したラウンドが存在しない (まだ1ラウンドも終わっていない場合を含む)
+	ErrRoundNotFound = errors.New("round not found")
+	// ErrRoundInProgress: ラウンドが進行中で結果がまだ無い
+	ErrRoundInProgress = errors.New("round in progress")
+)
+
+// GameSessionService: ゲーム (ラウンド) 開始〜終了の境界を跨ぐ処理を担当
 type GameSessionService struct {
 	roomService *RoomService
 	eventRepo   repository.EventRepository
 	viewerRepo  repository.ViewerRepository
 	counter     counter.Counter
+	catalog     *EventCatalog
 	wsSender    WebSocketSender
+	flusher     EventFlusher                     // 集計前に書き込み待ちイベントを DB へ反映させる
+	flushWait   time.Duration                    // flusher の待ち時間上限
+	triggers    repository.TriggerRepository     // 発動履歴 (nil の場合はタイムラインを空で返す)
+	sessions    repository.GameSessionRepository // ラウンド (nil の場合はラウンドの開始 / 終了時刻を返さない)
+	events      *EventService                    // 再開時の保留押下の反映と統計の更新通知 (nil の場合は行わない)
 	logger      *slog.Logger
 }
 
-func NewGameSessionService(roomService *RoomService, eventRepo repository.EventRepository, viewerRepo repository.ViewerRepository, counter counter.Counter, sender WebSocketSender, logger *slog.Logger) *GameSessionService {
+func NewGameSessionService(roomService *RoomService, eventRepo repository.EventRepository, viewerRepo repository.ViewerRepository, counter counter.Counter, catalog *EventCatalog, sender WebSocketSender, logger *slog.Logger) *GameSessionService {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &GameSessionService{roomService: roomService, eventRepo: eventRepo, viewerRepo: viewerRepo, counter: counter, wsSender: sender, logger: logger}
+	if catalog == nil {
+		catalog = DefaultEventCatalog()
+	}
+	return &GameSessionService{roomService: roomService, eventRepo: eventRepo, viewerRepo: viewerRepo, counter: counter, catalog: catalog, wsSender: sender, logger: logger}
+}
+
+// SetEventFlusher: 集計前のイベント書き込みフラッシュを設定 (timeout は待ち時間の上限)
+func (s *GameSessionService) SetEventFlusher(f EventFlusher, timeout time.Duration) {
+	s.flusher = f
+	s.flushWait = timeout
+}
+
+// SetGameSessionRepository: ラウンド一覧 / ラウンドごとの結果に使うラウンドの記録を設定
+func (s *GameSessionService) SetGameSessionRepository(repo repository.GameSessionRepository) {
+	s.sessions = repo
+}
+
+// SetEventService: 一時停止 / 再開時に使うイベントサービスを設定 (保留した押下の反映、統計ストリームへの通知)
+func (s *GameSessionService) SetEventService(events *EventService) {
+	s.events = events
+}
+
+// SetTriggerRepository: 結果サマリーの発動タイムラインに使う発動履歴を設定
+func (s *GameSessionService) SetTriggerRepository(repo repository.TriggerRepository) {
+	s.triggers = repo
+}
+
+// StartGame: Unity からの開始通知時に呼ぶ。waiting のルームでは次のラウンドを開始し、カウンタとレベルをリセットする。
+// ラウンド番号を返す (一時停止からの再開・重複した通知では同じラウンドのまま)。
+func (s *GameSessionService) StartGame(roomID string) (int, error) {
+	round, started, err := s.roomService.MarkInGame(roomID)
+	if err != nil {
+		return 0, err
+	}
+	if started {
+		// 前のラウンドが終了処理を経ずに終わった場合に備え、開始時にもリセットしておく
+		s.resetCounters(roomID)
+		s.logger.Info("round started", slog.String("room_id", roomID), slog.Int("round", round))
+	} else {
+		// 一時停止中に game_start で再開した場合も保留分を反映する (保留が無ければ何もしない)
+		s.applyBufferedPushes(roomID)
+	}
+	return round, nil
+}
+
+// PauseGame: Unity からの一時停止通知時に呼ぶ。以後の押下はルーム設定 pause_mode に従って拒否 / 保留される。
+func (s *GameSessionService) PauseGame(roomID string) error {
+	paused, err := s.roomService.MarkPaused(roomID)
+	if err != nil {
+		return err
+	}
+	if paused && s.events != nil {
+		// 統計ストリームの購読者に状態の変化を伝える
+		s.events.PublishViewerCount(roomID)
+	}
+	return nil
+}
+
+// ResumeGame: Unity からの再開通知時に呼ぶ。ゲーム中へ戻し、一時停止中に保留した押下をまとめて反映する。
+func (s *GameSessionService) ResumeGame(roomID string) error {
+	resumed, err := s.roomService.MarkResumed(roomID)
+	if err != nil {
+		return err
+	}
+	if resumed {
+		s.applyBufferedPushes(roomID)
+	}
+	return nil
 }
 
-// EndGame: Unity からの終了通知時に呼ぶ。集計→ルーム終了→Unity へ結果送信までを担う。
+// applyBufferedPushes: 保留中の押下をカウンタへ反映 (失敗してもゲームは続行するためログのみ)
+func (s *GameSessionService) applyBufferedPushes(roomID string) {
+	if s.events == nil {
+		return
+	}
+	room, err := s.roomService.GetRoom(roomID)
+	if err != nil || room == nil {
+		s.logger.Warn("apply buffered pushes: get room failed", slog.String("room_id", roomID), slog.Any("error", err))
+		return
+	}
+	if _, err := s.events.ApplyBufferedPushes(room); err != nil {
+		s.logger.Error("apply buffered pushes failed", slog.String("room_id", roomID), slog.Any("error", err))
+	}
+}
+
+// EndGame: Unity からの終了通知時に呼ぶ。集計→ラウンド終了 (ルームは waiting に戻る)→Unity へ結果送信までを担う。
 func (s *GameSessionService) EndGame(roomID string) (*model.RoomResultSummary, error) {
 	room, err := s.roomService.GetRoom(roomID)
 	if err != nil {
@@ -37,42 +134,59 @@ func (s *GameSessionService) EndGame(roomID string) (*model.RoomResultSummary, e
 	if room == nil {
 		return nil, errors.New("room not found")
 	}
-	if room.Status == "ended" {
+	// ラウンド中でなければ (重複した game_end など) 直近のラウンドの結果を返す
+	if room.Status != model.RoomStateInGame && room.Status != model.RoomStatePaused {
 		return s.GetRoomResult(roomID)
 	}
+	round := room.CurrentRound
 
-	summary, err := s.buildRoomSummary(roomID)
+	// 書き込みキューに残っている押下を先に DB へ反映させ、集計から漏れないようにする
+	// 期限内に終わらなくても終了処理は続行する（集計は反映済みの分のみ）
+	if s.flusher != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.flushWait)
+		if err := s.flusher.FlushRoom(ctx, roomID); err != nil {
+			s.logger.Warn("flush pending events failed", slog.String("room_id", roomID), slog.Any("error", err))
+		}
+		cancel()
+	}
+
+	summary, err := s.buildRoomSummary(roomID, round)
 	if err != nil {
 		return nil, err
 	}
 	endedAt := time.Now()
-	if err := s.roomService.MarkEnded(roomID, endedAt); err != nil {
+	if err := s.roomService.Transition(roomID, room.Status, model.RoomStateWaiting, endedAt, model.RoomTransitionGameEnd); err != nil {
+		// 同時に届いた game_end が先にラウンドを終了させていれば、その結果を返す (サマリーの二重送信を防ぐ)
+		if errors.Is(err, ErrRoomStateConflict) {
+			if cur, gerr := s.roomService.GetRoom(roomID); gerr == nil && cur.CurrentRound == round && cur.Status != model.RoomStateInGame && cur.Status != model.RoomStatePaused {
+				return s.GetRoundResult(roomID, round)
+			}
+		}
 		return nil, err
 	}
-	summary.RoomID = roomID
 	summary.EndedAt = endedAt
 
-	// Redis カウンタは終了時にリセットしておく（失敗しても致命的ではないためログのみ）
-	for _, et := range model.ListEventTypes() {
-		if err := s.counter.Reset(roomID, string(et)); err != nil {
-			s.logger.Warn("reset counter failed", slog.String("room_id", roomID), slog.String("event_type", string(et)), slog.Any("error", err))
-		}
-	}
+	// Redis カウンタとレベルはラウンド終了時にリセットしておく（失敗しても致命的ではないためログのみ）
+	s.resetCounters(roomID)
 
 	// Unity へ終了サマリーを送信
 	if s.wsSender != nil {
 		teamTops := s.buildTeamTop(summary)
-		payload := map[string]interface{}{
-			"type":          "game_end_summary",
-			"top_by_button": summary.TopByEvent,
-			"top_overall":   summary.TopOverall,
-			"team_tops": map[string]interface{}{
-				"skill": s.eventTopToPayload(teamTops.TopSkill),
-				"enemy": s.eventTopToPayload(teamTops.TopEnemy),
-				"all":   s.eventTopToPayload(teamTops.TopAll),
+		topByButton := make(map[string]protocol.ViewerTop, len(summary.TopByEvent))
+		for et, top := range summary.TopByEvent {
+			topByButton[string(et)] = *toViewerTop(&top)
+		}
+		msg := protocol.GameEndSummary{
+			Round:       round,
+			TopByButton: topByButton,
+			TopOverall:  toViewerTop(summary.TopOverall),
+			TeamTops: protocol.TeamTops{
+				Skill: toViewerTop(teamTops.TopSkill),
+				Enemy: toViewerTop(teamTops.TopEnemy),
+				All:   toViewerTop(teamTops.TopAll),
 			},
 		}
-		if err := s.wsSender.SendEventToUnity(roomID, payload); err != nil {
+		if err := s.wsSender.SendToUnity(roomID, msg); err != nil {
 			s.logger.Warn("failed to send end summary to unity", slog.String("room_id", roomID), slog.Any("error", err))
 		}
 	}
@@ -80,7 +194,34 @@ func (s *GameSessionService) EndGame(roomID string) (*model.RoomResultSummary, e
 	return summary, nil
 }
 
-// GetRoomResult: 終了済みルームの集計結果を取得
+// resetCounters: ルームのカウンタとレベルをリセット (ラウンドの開始 / 終了時)
+func (s *GameSessionService) resetCounters(roomID string) {
+	for _, et := range s.catalog.Types() {
+		if err := s.counter.Reset(roomID, string(et)); err != nil {
+			s.logger.Warn("reset counter failed", slog.String("room_id", roomID), slog.String("event_type", string(et)), slog.Any("error", err))
+		}
+	}
+}
+
+// LastFinishedRound: 結果を返せる直近のラウンド (進行中のラウンドは含めない。無ければ 0)
+func (s *GameSessionService) LastFinishedRound(room *model.Room) int {
+	if room.Status == model.RoomStateInGame || room.Status == model.RoomStatePaused ||
+		(room.Status == model.RoomStateDisconnected && room.ResumeStatus != nil &&
+			(*room.ResumeStatus == model.RoomStateInGame || *room.ResumeStatus == model.RoomStatePaused)) {
+		return room.CurrentRound - 1
+	}
+	return room.CurrentRound
+}
+
+// ListRounds: ルームのラウンド一覧 (ラウンド順)
+func (s *GameSessionService) ListRounds(roomID string) ([]model.GameSession, error) {
+	if s.sessions == nil {
+		return []model.GameSession{}, nil
+	}
+	return s.sessions.ListByRoom(roomID)
+}
+
+// GetRoomResult: 直近に終了したラウンドの集計結果を取得
 func (s *GameSessionService) GetRoomResult(roomID string) (*model.RoomResultSummary, error) {
 	room, err := s.roomService.GetRoom(roomID)
 	if err != nil {
@@ -89,35 +230,65 @@ func (s *GameSessionService) GetRoomResult(roomID string) (*model.RoomResultSumm
 	if room == nil {
 		return nil, errors.New("room not found")
 	}
-	summary, err := s.buildRoomSummary(roomID)
+	round := s.LastFinishedRound(room)
+	if round < 1 {
+		return nil, ErrRoundNotFound
+	}
+	return s.GetRoundResult(roomID, round)
+}
+
+// GetRoundResult: 指定ラウンドの集計結果を取得 (進行中のラウンドは ErrRoundInProgress)
+func (s *GameSessionService) GetRoundResult(roomID string, round int) (*model.RoomResultSummary, error) {
+	if round < 1 {
+		return nil, ErrRoundNotFound
+	}
+	var endedAt *time.Time
+	var startedAt time.Time
+	if s.sessions != nil {
+		session, err := s.sessions.Get(roomID, round)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			return nil, ErrRoundNotFound
+		}
+		if session.EndedAt == nil {
+			return nil, ErrRoundInProgress
+		}
+		startedAt, endedAt = session.StartedAt, session.EndedAt
+	}
+	summary, err := s.buildRoomSummary(roomID, round)
 	if err != nil {
 		return nil, err
 	}
-	summary.RoomID = roomID
-	if room.EndedAt != nil {
-		summary.EndedAt = *room.EndedAt
+	summary.StartedAt = startedAt
+	if endedAt != nil {
+		summary.EndedAt = *endedAt
 	} else {
 		summary.EndedAt = time.Now()
 	}
 	return summary, nil
 }
 
-// GetViewerSummary: 終了後に視聴者へ返す個別内訳
-func (s *GameSessionService) GetViewerSummary(roomID, viewerID string) (*model.ViewerSummary, error) {
+// GetViewerSummary: ラウンド終了後に視聴者へ返す個別内訳
+func (s *GameSessionService) GetViewerSummary(roomID string, round int, viewerID string) (*model.ViewerSummary, error) {
 	if viewerID == "" {
 		return nil, fmt.Errorf("viewer_id required")
 	}
-	rows, err := s.eventRepo.ListViewerEventCounts(roomID, viewerID)
+	rows, err := s.eventRepo.ListViewerEventCounts(roomID, round, viewerID)
 	if err != nil {
 		return nil, err
 	}
-	counts := make(map[model.EventType]int, len(rows))
-	total := 0
+	counts := make(map[model.EventType]int64, len(rows))
+	total := int64(0)
 	for _, row := range rows {
+		if !s.catalog.Contains(row.EventType) {
+			continue
+		}
 		counts[row.EventType] = row.Count
 		total += row.Count
 	}
-	for _, et := range model.ListEventTypes() {
+	for _, et := range s.catalog.Types() {
 		if _, ok := counts[et]; !ok {
 			counts[et] = 0
 		}
@@ -128,32 +299,34 @@ func (s *GameSessionService) GetViewerSummary(roomID, viewerID string) (*model.V
 			namePtr = cloneStringPointer(viewer.Name)
 		}
 	}
-	return &model.ViewerSummary{ViewerID: viewerID, ViewerName: namePtr, Counts: counts, Total: total}, nil
+	return &model.ViewerSummary{Round: round, ViewerID: viewerID, ViewerName: namePtr, Counts: counts, Total: total}, nil
 }
 
-// buildRoomSummary: DB の events をもとに終了サマリーを構築（EndedAt は呼び出し側で設定）
-func (s *GameSessionService) buildRoomSummary(roomID string) (*model.RoomResultSummary, error) {
-	aggs, err := s.eventRepo.ListEventViewerCounts(roomID)
+// buildRoomSummary: DB の events をもとにラウンドの終了サマリーを構築（StartedAt / EndedAt は呼び出し側で設定）
+func (s *GameSessionService) buildRoomSummary(roomID string, round int) (*model.RoomResultSummary, error) {
+	aggs, err := s.eventRepo.ListEventViewerCounts(roomID, round)
 	if err != nil {
 		return nil, err
 	}
-	eventTotals, err := s.eventRepo.ListEventTotals(roomID)
+	eventTotals, err := s.eventRepo.ListEventTotals(roomID, round)
 	if err != nil {
 		return nil, err
 	}
-	viewerTotals, err := s.eventRepo.ListViewerTotals(roomID)
+	viewerTotals, err := s.eventRepo.ListViewerTotals(roomID, round)
 	if err != nil {
 		return nil, err
 	}
 
-	topByEvent := make(map[model.EventType]model.EventTop, len(model.ListEventTypes()))
-	for _, et := range model.ListEventTypes() {
+	eventTypes := s.catalog.Types()
+	topByEvent := make(map[model.EventType]model.EventTop, len(eventTypes))
+	for _, et := range eventTypes {
 		topByEvent[et] = model.EventTop{ViewerID: "", ViewerName: nil, Count: 0}
 	}
 
 	var topOverall *model.EventTop
 	for _, agg := range aggs {
-		if agg.ViewerID == "" {
+		// カタログから外れたイベント種別の過去データは集計対象外
+		if agg.ViewerID == "" || !s.catalog.Contains(agg.EventType) {
 			continue
 		}
 		current := topByEvent[agg.EventType]
@@ -166,23 +339,66 @@ func (s *GameSessionService) buildRoomSummary(roomID string) (*model.RoomResultS
 		}
 	}
 
-	totalMap := make(map[model.EventType]int, len(model.ListEventTypes()))
-	for _, et := range model.ListEventTypes() {
+	totalMap := make(map[model.EventType]int64, len(eventTypes))
+	for _, et := range eventTypes {
 		totalMap[et] = 0
 	}
 	for _, total := range eventTotals {
+		if !s.catalog.Contains(total.EventType) {
+			continue
+		}
 		totalMap[total.EventType] = total.Count
 	}
 
+	timeline, err := s.buildTriggerTimeline(roomID, round)
+	if err != nil {
+		return nil, err
+	}
+
 	return &model.RoomResultSummary{
-		RoomID:       roomID,
-		TopByEvent:   topByEvent,
-		TopOverall:   topOverall,
-		EventTotals:  totalMap,
-		ViewerTotals: viewerTotals,
+		RoomID:          roomID,
+		Round:           round,
+		TopByEvent:      topByEvent,
+		TopOverall:      topOverall,
+		EventTotals:     totalMap,
+		ViewerTotals:    viewerTotals,
+		TriggerTimeline: timeline,
 	}, nil
 }
 
+// buildTriggerTimeline: 発動履歴をイベント種別ごとにまとめる（カタログ順・発動がない種別も空で含める）
+func (s *GameSessionService) buildTriggerTimeline(roomID string, round int) ([]model.EventTriggerTimeline, error) {
+	eventTypes := s.catalog.Types()
+	byType := make(map[model.EventType]*model.EventTriggerTimeline, len(eventTypes))
+	timeline := make([]model.EventTriggerTimeline, len(eventTypes))
+	for i, et := range eventTypes {
+		timeline[i] = model.EventTriggerTimeline{EventType: et, Triggers: []model.TriggerTimelineEntry{}}
+		byType[et] = &timeline[i]
+	}
+	if s.triggers == nil {
+		return timeline, nil
+	}
+	recs, err := s.triggers.ListByRoom(roomID, round)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		t, ok := byType[rec.EventType]
+		if !ok {
+			continue
+		}
+		t.Total += rec.Multiplier
+		t.Triggers = append(t.Triggers, model.TriggerTimelineEntry{
+			At:         rec.SentAt,
+			Level:      rec.Level,
+			Multiplier: rec.Multiplier,
+			ViewerID:   cloneStringPointer(rec.ViewerID),
+			ViewerName: cloneStringPointer(rec.ViewerName),
+		})
+	}
+	return timeline, nil
+}
+
 func cloneStringPointer(src *string) *string {
 	if src == nil {
 		return nil
@@ -208,22 +424,19 @@ func (s *GameSessionService) buildTeamTop(summary *model.RoomResultSummary) mode
 
 	return model.TeamTopSummary{
 		TopSkill: fetch(func(et model.EventType) bool {
-			return et == model.SKILL1 || et == model.SKILL2 || et == model.SKILL3
+			return s.catalog.Category(et) == model.EventCategorySkill
 		}),
 		TopEnemy: fetch(func(et model.EventType) bool {
-			return et == model.ENEMY1 || et == model.ENEMY2 || et == model.ENEMY3
+			return s.catalog.Category(et) == model.EventCategoryEnemy
 		}),
 		TopAll: summary.TopOverall,
 	}
 }
 
-func (s *GameSessionService) eventTopToPayload(top *model.EventTop) map[string]interface{} {
+// toViewerTop: 最多押下者を Unity 向けの形式に変換 (nil はそのまま nil)
+func toViewerTop(top *model.EventTop) *protocol.ViewerTop {
 	if top == nil {
 		return nil
 	}
-	return map[string]interface{}{
-		"viewer_id":   top.ViewerID,
-		"viewer_name": top.ViewerName,
-		"count":       top.Count,
-	}
+	return &protocol.ViewerTop{ViewerID: top.ViewerID, ViewerName: top.ViewerName, Count: top.Count}
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/internal/repository"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// streamerAPIKeyPrefix: API キーの接頭辞 (ログやリポジトリに紛れた場合に見分けやすくする)
+const streamerAPIKeyPrefix = "sk_"
+
+// streamerAPIKeyPrefixLen: 一覧表示用に保存するキー先頭部分の長さ (接頭辞を含む)
+const streamerAPIKeyPrefixLen = len(streamerAPIKeyPrefix) + 8
+
+var (
+	// ErrInvalidAPIKey: API キーが存在しない / 失効済み / アカウントが無効
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyNotFound: 失効対象のキーが無い (他の配信者のキー / 失効済みを含む)
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidStreamerName: 配信者名が空 / 長すぎる
+	ErrInvalidStreamerName = errors.New("name must be 1-255 characters")
+)
+
+// StreamerAPIKeyIssueResult: 発行した API キー (Key は平文で、この時にしか得られない)
+type StreamerAPIKeyIssueResult struct {
+	model.StreamerAPIKey
+	Key string `json:"key"`
+}
+
+// StreamerService: 配信者アカウントの登録と API キーによる認証
+// API キーは DB に SHA-256 のみ保存し、REST API (Authorization: Bearer) と Unity の WebSocket 接続で共通に使う。
+type StreamerService struct {
+	repo        repository.StreamerRepository
+	signupToken []byte
+}
+
+// NewStreamerService: 生成 (signupToken が空なら新規登録を受け付けない)
+func NewStreamerService(repo repository.StreamerRepository, signupToken string) *StreamerService {
+	return &StreamerService{repo: repo, signupToken: []byte(signupToken)}
+}
+
+// CanRegister: 新規登録用トークンが一致するか (トークン未設定なら常に false)
+func (s *StreamerService) CanRegister(token string) bool {
+	if len(s.signupToken) == 0 || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), s.signupToken) == 1
+}
+
+// Register: 配信者を作成し、最初の API キーを発行する
+func (s *StreamerService) Register(name string) (*model.Streamer, *StreamerAPIKeyIssueResult, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 255 {
+		return nil, nil, ErrInvalidStreamerName
+	}
+	now := time.Now()
+	streamer := &model.Streamer{ID: ulid.MustNew(ulid.Timestamp(now), rand.Reader).String(), Name: name, CreatedAt: now}
+	issued, err := newStreamerAPIKey(streamer.ID, "default", now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.Create(streamer, &issued.StreamerAPIKey); err != nil {
+		return nil, nil, err
+	}
+	return streamer, issued, nil
+}
+
+// Get: 配信者を取得 (存在しなければ nil)
+func (s *StreamerService) Get(id string) (*model.Streamer, error) {
+	return s.repo.Get(id)
+}
+
+// Authenticate: API キーから配信者を特定する (無効なキーは ErrInvalidAPIKey)
+func (s *StreamerService) Authenticate(key string) (*model.Streamer, error) {
+	if !strings.HasPrefix(key, streamerAPIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	streamer, err := s.repo.Authenticate(hashStreamerAPIKey(key), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if streamer == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return streamer, nil
+}
+
+// IssueAPIKey: 追加の API キーを発行 (Unity 用 / 管理画面用などで分けて失効できるようにする)
+func (s *StreamerService) IssueAPIKey(streamerID, label string) (*StreamerAPIKeyIssueResult, error) {
+	label = strings.TrimSpace(label)
+	if utf8.RuneCountInString(label) > 255 {
+		return nil, errors.New("label must be at most 255 characters")
+	}
+	issued, err := newStreamerAPIKey(streamerID, label, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateAPIKey(&issued.StreamerAPIKey); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// ListAPIKeys: 配信者の API キー一覧 (失効済みを含む、平文は含まない)
+func (s *StreamerService) ListAPIKeys(streamerID string) ([]model.StreamerAPIKey, error) {
+	return s.repo.ListAPIKeys(streamerID)
+}
+
+// RevokeAPIKey: 配信者自身の API キーを失効させる
+func (s *StreamerService) RevokeAPIKey(streamerID, keyID string) error {
+	ok, err := s.repo.RevokeAPIKey(streamerID, keyID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Owns: ルームが配信者のものか
+func (s *StreamerService) Owns(room *model.Room, streamerID string) bool {
+	return room != nil && streamerID != "" && room.StreamerID == streamerID
+}
+
+// newStreamerAPIKey: ランダムな API キーを生成 (保存用のハッシュと先頭部分を含む)
+func newStreamerAPIKey(streamerID, label string, now time.Time) (*StreamerAPIKeyIssueResult, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	key := streamerAPIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return &StreamerAPIKeyIssueResult{
+		StreamerAPIKey: model.StreamerAPIKey{
+			ID:         ulid.MustNew(ulid.Timestamp(now), rand.Reader).String(),
+			StreamerID: streamerID,
+			KeyHash:    hashStreamerAPIKey(key),
+			KeyPrefix:  key[:streamerAPIKeyPrefixLen],
+			Label:      label,
+			CreatedAt:  now,
+		},
+		Key: key,
+	}, nil
+}
+
+func hashStreamerAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
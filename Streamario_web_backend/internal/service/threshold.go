@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"streamerrio-backend/internal/model"
+	"streamerrio-backend/pkg/counter"
+)
+
+// defaultViewerMultipliers: 視聴者数帯ごとの既定倍率テーブル (ルーム設定で上書き可能)
+var defaultViewerMultipliers = []model.ViewerMultiplierStep{
+	{MaxViewers: 5, Multiplier: 1.0},
+	{MaxViewers: 10, Multiplier: 1.2},
+	{MaxViewers: 20, Multiplier: 1.5},
+	{MaxViewers: 50, Multiplier: 2.0},
+	{MaxViewers: 100, Multiplier: 3.0},
+	{MaxViewers: 150, Multiplier: 4.0},
+	{MaxViewers: 200, Multiplier: 5.0},
+	{MaxViewers: 300, Multiplier: 6.0},
+	{MaxViewers: 0, Multiplier: 7.0},
+}
+
+// roomThresholds: ルーム設定 (Room.Settings) を反映した閾値計算パラメータ
+type roomThresholds struct {
+	strategy    ThresholdStrategy
+	pushRate    float64
+	overrides   map[model.EventType]model.ThresholdOverride
+	multipliers []model.ViewerMultiplierStep
+	limit       *model.TriggerLimit
+}
+
+// resolveRoomThresholds: ルーム設定を解釈し、未指定部分は既定値で補完する
+// 設定が壊れている場合もイベント処理は止めず、既定値で継続する。
+func (s *EventService) resolveRoomThresholds(room *model.Room) roomThresholds {
+	rt := roomThresholds{strategy: stepStrategy{}, multipliers: defaultViewerMultipliers}
+	if room == nil {
+		return rt
+	}
+	settings, err := model.ParseRoomSettings(room.Settings)
+	if err != nil {
+		s.logger.Warn("invalid room settings, using defaults", slog.String("room_id", room.ID), slog.Any("error", err))
+		return rt
+	}
+	if strategy, err := NewThresholdStrategy(settings.ThresholdStrategy, settings.ThresholdParams); err != nil {
+		s.logger.Warn("invalid threshold strategy, using step", slog.String("room_id", room.ID), slog.Any("error", err))
+	} else {
+		rt.strategy = strategy
+	}
+	rt.overrides = settings.EventThresholds
+	rt.limit = settings.TriggerLimit
+	if len(settings.ViewerMultipliers) > 0 {
+		rt.multipliers = settings.ViewerMultipliers
+	}
+	// 押下レートは rate 戦略のときだけ取得する (取得失敗時は 0 回/秒 扱い)
+	if _, ok := rt.strategy.(rateStrategy); ok {
+		if rate, err := s.counter.GetPushRate(room.ID, pushRateWindow); err == nil {
+			rt.pushRate = rate
+		} else {
+			s.logger.Warn("get push rate failed", slog.String("room_id", room.ID), slog.Any("error", err))
+		}
+	}
+	return rt
+}
+
+// rule: 実効設定に戦略の倍率を掛けたレベル1の閾値とクールダウン / ルーム上限をカウンタ用の判定ルールにまとめ、倍率と共に返す
+func (rt roomThresholds) rule(cfg model.EventConfig, viewerCount int) (counter.TriggerRule, float64) {
+	mult := rt.strategy.Multiplier(ThresholdInput{
+		Config:      cfg,
+		ViewerCount: viewerCount,
+		PushRate:    rt.pushRate,
+		Multipliers: rt.multipliers,
+	})
+	rule := counter.TriggerRule{
+		Threshold:       int64(clampThreshold(cfg, float64(cfg.BaseThreshold)*mult)),
+		LevelMultiplier: cfg.LevelMultiplier,
+		Cooldown:        time.Duration(cfg.CooldownMs) * time.Millisecond,
+	}
+	if rt.limit != nil {
+		rule.RoomLimit = int64(rt.limit.MaxTriggers)
+		rule.RoomWindow = time.Duration(rt.limit.WindowMs) * time.Millisecond
+	}
+	return rule, mult
+}
+
+// threshold: 指定レベルの閾値と戦略の倍率を返す
+// level が 2 以上の場合はクランプ後の値に LevelMultiplier^(level-1) を掛ける (MaxThreshold を超えうる)。
+func (rt roomThresholds) threshold(cfg model.EventConfig, viewerCount int, level int) (int, float64) {
+	rule, mult := rt.rule(cfg, viewerCount)
+	return int(rule.ThresholdAt(int64(level))), mult
+}
+
+// apply: カタログ設定にルーム単位の上書きを適用した実効設定を返す
+func (rt roomThresholds) apply(cfg model.EventConfig) model.EventConfig {
+	ov, ok := rt.overrides[cfg.EventType]
+	if !ok {
+		return cfg
+	}
+	if ov.BaseThreshold != nil {
+		cfg.BaseThreshold = *ov.BaseThreshold
+	}
+	if ov.MinThreshold != nil {
+		cfg.MinThreshold = *ov.MinThreshold
+	}
+	if ov.MaxThreshold != nil {
+		cfg.MaxThreshold = *ov.MaxThreshold
+	}
+	if ov.CooldownMs != nil {
+		cfg.CooldownMs = *ov.CooldownMs
+	}
+	return cfg
+}
+
+// clampThreshold: 倍率適用後の値を切り上げ、上下限でクランプ
+func clampThreshold(cfg model.EventConfig, raw float64) int {
+	val := int(math.Ceil(raw))
+	if val < cfg.MinThreshold {
+		val = cfg.MinThreshold
+	}
+	if val > cfg.MaxThreshold {
+		val = cfg.MaxThreshold
+	}
+	return val
+}
+
+// viewerMultiplier: 倍率テーブルから視聴者数帯に該当する倍率を引く
+// テーブル末尾の上限を超えた場合は末尾の倍率を使う。
+func viewerMultiplier(steps []model.ViewerMultiplierStep, v int) float64 {
+	if len(steps) == 0 {
+		return 1.0
+	}
+	for _, step := range steps {
+		if step.MaxViewers == 0 || v <= step.MaxViewers {
+			return step.Multiplier
+		}
+	}
+	return steps[len(steps)-1].Multiplier
+}
+
+// ValidateRoomSettings: ルーム設定の閾値戦略 / 閾値上書き / 倍率テーブル / 発動上限を検証
+func (s *EventService) ValidateRoomSettings(settings model.RoomSettings) error {
+	if _, err := NewThresholdStrategy(settings.ThresholdStrategy, settings.ThresholdParams); err != nil {
+		return err
+	}
+	if p := settings.ThresholdParams; p != nil {
+		for name, v := range map[string]*float64{"linear_slope": p.LinearSlope, "log_factor": p.LogFactor, "rate_factor": p.RateFactor} {
+			if v != nil && (*v < 0 || math.IsNaN(*v) || math.IsInf(*v, 0)) {
+				return fmt.Errorf("threshold_params.%s must be a non-negative number", name)
+			}
+		}
+	}
+	for et, ov := range settings.EventThresholds {
+		cfg, ok := s.catalog.Config(et)
+		if !ok {
+			return fmt.Errorf("unknown event type %q", et)
+		}
+		for name, v := range map[string]*int{"base_threshold": ov.BaseThreshold, "min_threshold": ov.MinThreshold, "max_threshold": ov.MaxThreshold} {
+			if v != nil && *v <= 0 {
+				return fmt.Errorf("%s: %s must be greater than 0", et, name)
+			}
+		}
+		if ov.CooldownMs != nil && *ov.CooldownMs < 0 {
+			return fmt.Errorf("%s: cooldown_ms must not be negative", et)
+		}
+		effective := roomThresholds{overrides: settings.EventThresholds}.apply(cfg)
+		if effective.MaxThreshold < effective.MinThreshold {
+			return fmt.Errorf("%s: max_threshold must be >= min_threshold", et)
+		}
+	}
+	prevMax := 0
+	for i, step := range settings.ViewerMultipliers {
+		if step.Multiplier <= 0 {
+			return fmt.Errorf("viewer_multipliers[%d]: multiplier must be greater than 0", i)
+		}
+		if step.MaxViewers < 0 {
+			return fmt.Errorf("viewer_multipliers[%d]: max_viewers must not be negative", i)
+		}
+		if step.MaxViewers == 0 {
+			if i != len(settings.ViewerMultipliers)-1 {
+				return fmt.Errorf("viewer_multipliers[%d]: unbounded step (max_viewers=0) must be last", i)
+			}
+			continue
+		}
+		if step.MaxViewers <= prevMax {
+			return fmt.Errorf("viewer_multipliers[%d]: max_viewers must be strictly increasing", i)
+		}
+		prevMax = step.MaxViewers
+	}
+	if l := settings.TriggerLimit; l != nil && (l.MaxTriggers <= 0 || l.WindowMs <= 0) {
+		return fmt.Errorf("trigger_limit: max_triggers and window_ms must be greater than 0")
+	}
+	switch settings.PauseMode {
+	case "", model.PauseModeReject, model.PauseModeBuffer:
+	default:
+		return fmt.Errorf("pause_mode must be %q or %q", model.PauseModeReject, model.PauseModeBuffer)
+	}
+	return nil
+}
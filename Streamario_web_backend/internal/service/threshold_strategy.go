@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"streamerrio-backend/internal/model"
+)
+
+// 組み込みの閾値戦略名 (rooms.settings.threshold_strategy に保存される)
+const (
+	ThresholdStrategyStep   = "step"   // 視聴者数帯ごとの倍率テーブル (従来方式)
+	ThresholdStrategyLinear = "linear" // 視聴者数に比例して倍率を上げる
+	ThresholdStrategyLog    = "log"    // 視聴者数の対数で倍率を上げる (大人数で緩やか)
+	ThresholdStrategyFixed  = "fixed"  // 視聴者数に関係なく BaseThreshold 固定
+	ThresholdStrategyRate   = "rate"   // ルーム全体の押下レート (回/秒) に応じて倍率を上げる
+)
+
+// 戦略パラメータの既定値 (rooms.settings.threshold_params で上書き可能)
+const (
+	defaultLinearSlope = 0.05 // 視聴者1人増えるごとに +0.05 倍 (101人で 6.0 倍)
+	defaultLogFactor   = 1.0  // 1 + ln(視聴者数) 倍 (100人で約 5.6 倍)
+	defaultRateFactor  = 0.1  // 押下レート 1回/秒 ごとに +0.1 倍
+
+	// pushRateWindow: rate 戦略で参照する押下レートの計測窓
+	pushRateWindow = 10 * time.Second
+)
+
+// ThresholdInput: 閾値戦略に渡す計算材料
+type ThresholdInput struct {
+	Config      model.EventConfig            // ルーム上書き適用済みのイベント設定
+	ViewerCount int                          // アクティブ視聴者数
+	PushRate    float64                      // ルーム全体の直近押下レート (回/秒, rate 戦略のみ算出)
+	Multipliers []model.ViewerMultiplierStep // ルームの倍率テーブル (step 戦略で使用)
+}
+
+// ThresholdStrategy: BaseThreshold に掛ける倍率を決める戦略
+// 倍率適用後の値は呼び出し側で MinThreshold〜MaxThreshold にクランプされる。
+type ThresholdStrategy interface {
+	Name() string
+	Multiplier(in ThresholdInput) float64
+}
+
+type stepStrategy struct{}
+
+func (stepStrategy) Name() string { return ThresholdStrategyStep }
+func (stepStrategy) Multiplier(in ThresholdInput) float64 {
+	return viewerMultiplier(in.Multipliers, in.ViewerCount)
+}
+
+type linearStrategy struct{ slope float64 }
+
+func (linearStrategy) Name() string { return ThresholdStrategyLinear }
+func (s linearStrategy) Multiplier(in ThresholdInput) float64 {
+	return 1.0 + s.slope*float64(in.ViewerCount-1)
+}
+
+type logStrategy struct{ factor float64 }
+
+func (logStrategy) Name() string { return ThresholdStrategyLog }
+func (s logStrategy) Multiplier(in ThresholdInput) float64 {
+	return 1.0 + s.factor*math.Log(float64(in.ViewerCount))
+}
+
+type fixedStrategy struct{}
+
+func (fixedStrategy) Name() string                         { return ThresholdStrategyFixed }
+func (fixedStrategy) Multiplier(in ThresholdInput) float64 { return 1.0 }
+
+type rateStrategy struct{ factor float64 }
+
+func (rateStrategy) Name() string { return ThresholdStrategyRate }
+func (s rateStrategy) Multiplier(in ThresholdInput) float64 {
+	return 1.0 + s.factor*in.PushRate
+}
+
+// ThresholdStrategyNames: 利用可能な戦略名一覧 (昇順)
+func ThresholdStrategyNames() []string {
+	names := []string{ThresholdStrategyStep, ThresholdStrategyLinear, ThresholdStrategyLog, ThresholdStrategyFixed, ThresholdStrategyRate}
+	sort.Strings(names)
+	return names
+}
+
+// NewThresholdStrategy: 戦略名とパラメータから戦略を生成 (空文字は step)
+func NewThresholdStrategy(name string, params *model.ThresholdParams) (ThresholdStrategy, error) {
+	if params == nil {
+		params = &model.ThresholdParams{}
+	}
+	switch name {
+	case "", ThresholdStrategyStep:
+		return stepStrategy{}, nil
+	case ThresholdStrategyLinear:
+		return linearStrategy{slope: floatOr(params.LinearSlope, defaultLinearSlope)}, nil
+	case ThresholdStrategyLog:
+		return logStrategy{factor: floatOr(params.LogFactor, defaultLogFactor)}, nil
+	case ThresholdStrategyFixed:
+		return fixedStrategy{}, nil
+	case ThresholdStrategyRate:
+		return rateStrategy{factor: floatOr(params.RateFactor, defaultRateFactor)}, nil
+	default:
+		return nil, fmt.Errorf("unknown threshold strategy %q", name)
+	}
+}
+
+func floatOr(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"streamerrio-backend/internal/model"
+)
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestThresholdStrategy_Multiplier(t *testing.T) {
+	cases := []struct {
+		name     string
+		strategy string
+		params   *model.ThresholdParams
+		viewers  int
+		rate     float64
+		want     float64
+	}{
+		{"step 1 viewer", ThresholdStrategyStep, nil, 1, 0, 1.0},
+		{"step band upper edge", ThresholdStrategyStep, nil, 10, 0, 1.2},
+		{"step next band", ThresholdStrategyStep, nil, 11, 0, 1.5},
+		{"step unbounded tail", ThresholdStrategyStep, nil, 1000, 0, 7.0},
+		{"empty name is step", "", nil, 60, 0, 3.0},
+		{"linear 1 viewer", ThresholdStrategyLinear, nil, 1, 0, 1.0},
+		{"linear default slope", ThresholdStrategyLinear, nil, 21, 0, 2.0},
+		{"linear custom slope", ThresholdStrategyLinear, &model.ThresholdParams{LinearSlope: float64Ptr(0.5)}, 5, 0, 3.0},
+		{"log 1 viewer", ThresholdStrategyLog, nil, 1, 0, 1.0},
+		{"log default factor", ThresholdStrategyLog, nil, 100, 0, 1 + math.Log(100)},
+		{"log custom factor", ThresholdStrategyLog, &model.ThresholdParams{LogFactor: float64Ptr(0.5)}, 100, 0, 1 + 0.5*math.Log(100)},
+		{"fixed ignores viewers", ThresholdStrategyFixed, nil, 500, 0, 1.0},
+		{"fixed ignores rate", ThresholdStrategyFixed, nil, 1, 30, 1.0},
+		{"rate idle", ThresholdStrategyRate, nil, 50, 0, 1.0},
+		{"rate default factor", ThresholdStrategyRate, nil, 1, 10, 2.0},
+		{"rate custom factor", ThresholdStrategyRate, &model.ThresholdParams{RateFactor: float64Ptr(0.5)}, 1, 4, 3.0},
+		{"rate ignores viewers", ThresholdStrategyRate, nil, 1000, 5, 1.5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewThresholdStrategy(tc.strategy, tc.params)
+			if err != nil {
+				t.Fatalf("NewThresholdStrategy failed: %v", err)
+			}
+			got := s.Multiplier(ThresholdInput{ViewerCount: tc.viewers, PushRate: tc.rate, Multipliers: defaultViewerMultipliers})
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("expected multiplier %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewThresholdStrategy_Unknown(t *testing.T) {
+	if _, err := NewThresholdStrategy("exponential", nil); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestViewerMultiplier_CustomTable(t *testing.T) {
+	// 末尾が上限付きの場合、超えた視聴者数は末尾の倍率
+	steps := []model.ViewerMultiplierStep{{MaxViewers: 3, Multiplier: 1.0}, {MaxViewers: 10, Multiplier: 2.5}}
+	cases := []struct {
+		viewers int
+		want    float64
+	}{
+		{1, 1.0}, {3, 1.0}, {4, 2.5}, {10, 2.5}, {11, 2.5},
+	}
+	for _, tc := range cases {
+		if got := viewerMultiplier(steps, tc.viewers); got != tc.want {
+			t.Errorf("viewers=%d: expected %v, got %v", tc.viewers, tc.want, got)
+		}
+	}
+	if got := viewerMultiplier(nil, 100); got != 1.0 {
+		t.Errorf("empty table: expected 1.0, got %v", got)
+	}
+}
+
+func TestClampThreshold(t *testing.T) {
+	cfg := model.EventConfig{MinThreshold: 3, MaxThreshold: 50}
+	cases := []struct {
+		raw  float64
+		want int
+	}{
+		{0, 3},
+		{2.1, 3},
+		{3, 3},
+		{4.2, 5}, // 切り上げ
+		{49.01, 50},
+		{50, 50},
+		{120, 50},
+	}
+	for _, tc := range cases {
+		if got := clampThreshold(cfg, tc.raw); got != tc.want {
+			t.Errorf("raw=%v: expected %d, got %d", tc.raw, tc.want, got)
+		}
+	}
+}
+
+func TestRoomThresholds_Rule(t *testing.T) {
+	// skill1: base 5 / min 3 / max 50 / level_multiplier 1.3
+	cfg, ok := DefaultEventCatalog().Config(model.SKILL1)
+	if !ok {
+		t.Fatal("skill1 missing from default catalog")
+	}
+	cases := []struct {
+		name     string
+		strategy ThresholdStrategy
+		rate     float64
+		viewers  int
+		level    int
+		want     int
+	}{
+		{"step small room", stepStrategy{}, 0, 3, 1, 5},
+		{"step large room", stepStrategy{}, 0, 120, 1, 20},
+		{"step clamped to max", stepStrategy{}, 0, 1000, 1, 35},
+		{"linear", linearStrategy{slope: defaultLinearSlope}, 0, 21, 1, 10},
+		{"linear clamped to max", linearStrategy{slope: defaultLinearSlope}, 0, 1001, 1, 50},
+		{"log clamped to max", logStrategy{factor: defaultLogFactor}, 0, 1000000, 1, 50},
+		{"fixed", fixedStrategy{}, 0, 1000, 1, 5},
+		{"rate", rateStrategy{factor: defaultRateFactor}, 20, 1, 1, 15},
+		{"level 2 may exceed max", linearStrategy{slope: defaultLinearSlope}, 0, 1001, 2, 65},
+		{"level 3", fixedStrategy{}, 0, 1, 3, 9}, // ceil(5 × 1.3²) = ceil(8.45)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := roomThresholds{strategy: tc.strategy, pushRate: tc.rate, multipliers: defaultViewerMultipliers}
+			if got, _ := rt.threshold(cfg, tc.viewers, tc.level); got != tc.want {
+				t.Errorf("expected threshold %d, got %d", tc.want, got)
+			}
+		})
+	}
+
+	// ルーム上書きの min_threshold でも下限クランプされる
+	minOverride := 8
+	rt := roomThresholds{
+		strategy:    fixedStrategy{},
+		multipliers: defaultViewerMultipliers,
+		overrides:   map[model.EventType]model.ThresholdOverride{model.SKILL1: {MinThreshold: &minOverride}},
+	}
+	if got, _ := rt.threshold(rt.apply(cfg), 1, 1); got != 8 {
+		t.Errorf("min override: expected threshold 8, got %d", got)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"streamerrio-backend/internal/model"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestValidateRoomSettings(t *testing.T) {
+	s := NewEventService(nil, nil, nil, nil, nil)
+	cases := []struct {
+		name     string
+		settings model.RoomSettings
+		wantErr  bool
+	}{
+		{"empty", model.RoomSettings{}, false},
+		{"strategy with params", model.RoomSettings{ThresholdStrategy: ThresholdStrategyLinear, ThresholdParams: &model.ThresholdParams{LinearSlope: float64Ptr(0.2)}}, false},
+		{"zero factor", model.RoomSettings{ThresholdStrategy: ThresholdStrategyRate, ThresholdParams: &model.ThresholdParams{RateFactor: float64Ptr(0)}}, false},
+		{"threshold override", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {BaseThreshold: intPtr(10), MinThreshold: intPtr(5), MaxThreshold: intPtr(20), CooldownMs: intPtr(0)},
+		}}, false},
+		{"max override equal to catalog min", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {MaxThreshold: intPtr(3)},
+		}}, false},
+		{"viewer multipliers", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{
+			{MaxViewers: 10, Multiplier: 1.0}, {MaxViewers: 50, Multiplier: 2.0}, {MaxViewers: 0, Multiplier: 3.0},
+		}}, false},
+		{"bounded tail", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{{MaxViewers: 10, Multiplier: 0.5}}}, false},
+		{"trigger limit", model.RoomSettings{TriggerLimit: &model.TriggerLimit{MaxTriggers: 3, WindowMs: 10000}}, false},
+		{"pause mode reject", model.RoomSettings{PauseMode: model.PauseModeReject}, false},
+		{"pause mode buffer", model.RoomSettings{PauseMode: model.PauseModeBuffer}, false},
+
+		{"unknown strategy", model.RoomSettings{ThresholdStrategy: "exponential"}, true},
+		{"negative slope", model.RoomSettings{ThresholdParams: &model.ThresholdParams{LinearSlope: float64Ptr(-0.1)}}, true},
+		{"NaN factor", model.RoomSettings{ThresholdParams: &model.ThresholdParams{LogFactor: float64Ptr(math.NaN())}}, true},
+		{"infinite factor", model.RoomSettings{ThresholdParams: &model.ThresholdParams{RateFactor: float64Ptr(math.Inf(1))}}, true},
+		{"unknown event type", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			"skill99": {BaseThreshold: intPtr(10)},
+		}}, true},
+		{"zero base", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {BaseThreshold: intPtr(0)},
+		}}, true},
+		{"negative min", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {MinThreshold: intPtr(-1)},
+		}}, true},
+		{"negative cooldown", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {CooldownMs: intPtr(-1)},
+		}}, true},
+		{"max below min", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {MinThreshold: intPtr(20), MaxThreshold: intPtr(10)},
+		}}, true},
+		{"max below catalog min", model.RoomSettings{EventThresholds: map[model.EventType]model.ThresholdOverride{
+			model.SKILL1: {MaxThreshold: intPtr(2)},
+		}}, true},
+		{"zero multiplier", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{{MaxViewers: 10, Multiplier: 0}}}, true},
+		{"negative max viewers", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{{MaxViewers: -1, Multiplier: 1}}}, true},
+		{"unbounded step not last", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{
+			{MaxViewers: 0, Multiplier: 1.0}, {MaxViewers: 10, Multiplier: 2.0},
+		}}, true},
+		{"max viewers not increasing", model.RoomSettings{ViewerMultipliers: []model.ViewerMultiplierStep{
+			{MaxViewers: 10, Multiplier: 1.0}, {MaxViewers: 10, Multiplier: 2.0},
+		}}, true},
+		{"trigger limit without window", model.RoomSettings{TriggerLimit: &model.TriggerLimit{MaxTriggers: 3}}, true},
+		{"trigger limit zero max", model.RoomSettings{TriggerLimit: &model.TriggerLimit{WindowMs: 1000}}, true},
+		{"unknown pause mode", model.RoomSettings{PauseMode: "drop"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.ValidateRoomSettings(tc.settings)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -1,13 +1,26 @@
 package counter
 
+import "time"
+
 // Counter: イベント回数 & 視聴者アクティビティを抽象化するインタフェース
 // すべてのメソッドは並行安全であること (goroutine から同時呼び出し想定)
 type Counter interface {
     Increment(roomID, eventType string, value int64) (int64, error)        // カウントをvalueだけ増やして現在のカウントを返す
     Get(roomID, eventType string) (int64, error)              // 現在カウント取得
     GetMulti(roomID string, eventTypes []string) (map[string]int64, error) // 複数イベントカウント一括取得
-    Reset(roomID, eventType string) error                     // カウントリセット(閾値到達後など)
-    SetExcess(roomID, eventType string, excess int64) error   // 閾値超過分をカウントに設定（超過分を捨てない）
+    Reset(roomID, eventType string) error                     // カウントとレベルをリセット(ゲーム終了時など、保留中の押下も破棄)
+    DeleteRoom(roomID string, eventTypes []string) error      // 終了したルームのキー (カウント / レベル / クールダウン / 発動履歴 / 視聴者 / 保留 / 書き込み待ち) をすべて削除
+    IncrementAndCheck(roomID, eventType string, value int64, rule TriggerRule) (*TriggerResult, error) // 加算・閾値判定・クールダウン/ルーム上限判定・超過分持ち越し・レベル加算を原子的に実行
+    GetCooldowns(roomID string, eventTypes []string) (map[string]time.Duration, error) // 複数イベントのクールダウン残り時間一括取得 (クールダウン外は0)
+    GetLevels(roomID string, eventTypes []string) (map[string]int64, error) // 複数イベントの現在レベル一括取得 (未発動は1)
     UpdateViewerActivity(roomID, viewerID string) error       // 視聴者アクティビティ更新(最終時刻記録)
     GetActiveViewerCount(roomID string) (int64, error)        // 一定期間内のアクティブ視聴者数
+    TakeTokens(key string, bucket TokenBucket, n int64) (*TakeResult, error) // トークンバケットから最大 n 個払い出す (不足分は払い出さない)
+    AddPendingWrites(roomID string, delta int64) error         // DB 書き込み待ちイベント数を増減 (インスタンス間で共有)
+    GetPendingWrites(roomID string) (int64, error)             // DB 書き込み待ちイベント数 (負値は0)
+    AddBufferedPushes(roomID string, counts map[string]int64) error // 一時停止中の押下をイベント種別ごとに保留 (カウンタには加算しない)
+    GetBufferedPushes(roomID string) (map[string]int64, error)     // 保留中の押下数 (イベント種別ごと)
+    TakeBufferedPushes(roomID string) (map[string]int64, error)    // 保留中の押下を取り出して空にする (原子的、再開時に一度だけ反映させる)
+    RecordPushes(roomID string, value int64) error            // ルーム全体の押下数を秒単位バケットに記録
+    GetPushRate(roomID string, window time.Duration) (float64, error) // 直近 window の平均押下レート (回/秒)
 }
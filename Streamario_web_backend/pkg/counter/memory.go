@@ -7,18 +7,38 @@ import (
 
 // memoryCounter: プロトタイプ/テスト用のインメモリ実装 (再起動で消える)
 type memoryCounter struct {
-	mu      sync.RWMutex
-	counts  map[string]map[string]int64 // roomID -> eventType -> count
-	viewers map[string]map[string]int64 // roomID -> viewerID -> lastUnix(秒)
-	window  time.Duration               // アクティブ判定窓
+	mu        sync.RWMutex
+	counts    map[string]map[string]int64     // roomID -> eventType -> count
+	levels    map[string]map[string]int64     // roomID -> eventType -> 発動回数 (レベル - 1)
+	cooldowns map[string]map[string]time.Time // roomID -> eventType -> クールダウン終了時刻
+	triggers  map[string][]time.Time          // roomID -> 発動時刻 (ルーム上限の計測用)
+	viewers   map[string]map[string]int64     // roomID -> viewerID -> lastUnix(秒)
+	pushes    map[string]map[int64]int64      // roomID -> unix秒 -> 押下数
+	buckets   map[string]*memoryBucket        // レート制限キー -> トークンバケット
+	pending   map[string]int64                // roomID -> DB 書き込み待ちイベント数
+	buffered  map[string]map[string]int64     // roomID -> eventType -> 一時停止中に保留した押下数
+	window    time.Duration                   // アクティブ判定窓
+}
+
+// memoryBucket: トークンバケットの状態
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
 }
 
 // NewMemoryCounter: インメモリ実装生成 (5分窓)
 func NewMemoryCounter() Counter {
 	return &memoryCounter{
-		counts:  make(map[string]map[string]int64),
-		viewers: make(map[string]map[string]int64),
-		window:  5 * time.Minute,
+		counts:    make(map[string]map[string]int64),
+		levels:    make(map[string]map[string]int64),
+		cooldowns: make(map[string]map[string]time.Time),
+		triggers:  make(map[string][]time.Time),
+		viewers:   make(map[string]map[string]int64),
+		pushes:    make(map[string]map[int64]int64),
+		buckets:   make(map[string]*memoryBucket),
+		pending:   make(map[string]int64),
+		buffered:  make(map[string]map[string]int64),
+		window:    5 * time.Minute,
 	}
 }
 
@@ -59,25 +79,135 @@ func (m *memoryCounter) GetMulti(roomID string, eventTypes []string) (map[string
 	return result, nil
 }
 
-// Reset: 指定イベント種別カウントを0クリア
+// Reset: 指定イベント種別のカウントを0クリアし、レベルを初期値に戻す
 func (m *memoryCounter) Reset(roomID, eventType string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if evMap, ok := m.counts[roomID]; ok {
 		evMap[eventType] = 0
 	}
+	if lvMap, ok := m.levels[roomID]; ok {
+		delete(lvMap, eventType)
+	}
+	if cdMap, ok := m.cooldowns[roomID]; ok {
+		delete(cdMap, eventType)
+	}
+	if bufMap, ok := m.buffered[roomID]; ok {
+		delete(bufMap, eventType)
+	}
+	return nil
+}
+
+// DeleteRoom: ルームの状態をすべて削除 (インメモリ実装では eventTypes に関係なくルーム単位で消す)
+func (m *memoryCounter) DeleteRoom(roomID string, eventTypes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counts, roomID)
+	delete(m.levels, roomID)
+	delete(m.cooldowns, roomID)
+	delete(m.triggers, roomID)
+	delete(m.viewers, roomID)
+	delete(m.pushes, roomID)
+	delete(m.pending, roomID)
+	delete(m.buffered, roomID)
 	return nil
 }
 
-// SetExcess: 閾値超過分をカウントに設定（超過分を捨てない）
-func (m *memoryCounter) SetExcess(roomID, eventType string, excess int64) error {
+// IncrementAndCheck: ミューテックス下で加算→閾値判定→超過分持ち越し→レベル加算をまとめて実行
+// 複数レベル分の閾値を一度に超えた場合は、クールダウン / ルーム上限の範囲で超えた回数だけ発動させる。
+func (m *memoryCounter) IncrementAndCheck(roomID, eventType string, value int64, rule TriggerRule) (*TriggerResult, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if _, ok := m.counts[roomID]; !ok {
 		m.counts[roomID] = make(map[string]int64)
 	}
-	m.counts[roomID][eventType] = excess
-	return nil
+	if _, ok := m.levels[roomID]; !ok {
+		m.levels[roomID] = make(map[string]int64)
+	}
+	if _, ok := m.cooldowns[roomID]; !ok {
+		m.cooldowns[roomID] = make(map[string]time.Time)
+	}
+	now := time.Now()
+	count := m.counts[roomID][eventType] + value
+	fired := m.levels[roomID][eventType]
+	res := &TriggerResult{Threshold: rule.ThresholdAt(fired + 1)}
+
+	// 計測窓外の発動履歴を捨てる
+	log := m.triggers[roomID]
+	if rule.roomLimited() {
+		cutoff := now.Add(-rule.RoomWindow)
+		kept := log[:0]
+		for _, at := range log {
+			if at.After(cutoff) {
+				kept = append(kept, at)
+			}
+		}
+		log = kept
+	}
+	wait := time.Duration(0)
+	if until, ok := m.cooldowns[roomID][eventType]; ok && rule.Cooldown > 0 && until.After(now) {
+		wait = until.Sub(now)
+	}
+	maxTriggers := rule.maxTriggers()
+	for th := res.Threshold; count >= th && res.Triggers < maxTriggers; th = rule.ThresholdAt(fired + 1) {
+		if wait > 0 {
+			res.Throttled = true
+			break
+		}
+		if rule.roomLimited() && int64(len(log)) >= rule.RoomLimit {
+			res.Throttled = true
+			wait = log[0].Add(rule.RoomWindow).Sub(now)
+			break
+		}
+		count -= th
+		res.Consumed += th
+		res.Triggers++
+		fired++
+		if rule.roomLimited() {
+			log = append(log, now)
+		}
+		if rule.Cooldown > 0 {
+			m.cooldowns[roomID][eventType] = now.Add(rule.Cooldown)
+			wait = rule.Cooldown
+		}
+	}
+	m.triggers[roomID] = log
+	m.counts[roomID][eventType] = count
+	m.levels[roomID][eventType] = fired
+	res.Triggered = res.Triggers > 0
+	res.Count = count
+	res.Level = fired + 1
+	res.CooldownRemaining = wait
+	return res, nil
+}
+
+// GetCooldowns: 複数イベントのクールダウン残り時間一括取得
+func (m *memoryCounter) GetCooldowns(roomID string, eventTypes []string) (map[string]time.Duration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	result := make(map[string]time.Duration, len(eventTypes))
+	cdMap := m.cooldowns[roomID]
+	for _, et := range eventTypes {
+		if until, ok := cdMap[et]; ok && until.After(now) {
+			result[et] = until.Sub(now)
+		} else {
+			result[et] = 0
+		}
+	}
+	return result, nil
+}
+
+// GetLevels: 複数イベントの現在レベル一括取得
+func (m *memoryCounter) GetLevels(roomID string, eventTypes []string) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string]int64, len(eventTypes))
+	lvMap := m.levels[roomID]
+	for _, et := range eventTypes {
+		result[et] = lvMap[et] + 1
+	}
+	return result, nil
 }
 
 // UpdateViewerActivity: 視聴者最終アクセス時刻を更新
@@ -110,3 +240,112 @@ func (m *memoryCounter) GetActiveViewerCount(roomID string) (int64, error) {
 	}
 	return c, nil
 }
+
+// AddPendingWrites: 書き込み待ち数を増減 (0以下になったら削除)
+func (m *memoryCounter) AddPendingWrites(roomID string, delta int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[roomID] += delta
+	if m.pending[roomID] <= 0 {
+		delete(m.pending, roomID)
+	}
+	return nil
+}
+
+// GetPendingWrites: 書き込み待ち数取得
+func (m *memoryCounter) GetPendingWrites(roomID string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pending[roomID], nil
+}
+
+// AddBufferedPushes: 保留中の押下数に加算
+func (m *memoryCounter) AddBufferedPushes(roomID string, counts map[string]int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for et, n := range counts {
+		if n <= 0 {
+			continue
+		}
+		if _, ok := m.buffered[roomID]; !ok {
+			m.buffered[roomID] = make(map[string]int64)
+		}
+		m.buffered[roomID][et] += n
+	}
+	return nil
+}
+
+// GetBufferedPushes: 保留中の押下数を取得
+func (m *memoryCounter) GetBufferedPushes(roomID string) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string]int64, len(m.buffered[roomID]))
+	for et, n := range m.buffered[roomID] {
+		result[et] = n
+	}
+	return result, nil
+}
+
+// TakeBufferedPushes: 保留中の押下数を取り出して削除
+func (m *memoryCounter) TakeBufferedPushes(roomID string) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := m.buffered[roomID]
+	delete(m.buffered, roomID)
+	if result == nil {
+		result = make(map[string]int64)
+	}
+	return result, nil
+}
+
+// RecordPushes: 現在秒のバケットに押下数を加算 (保持期間を過ぎたバケットは削除)
+func (m *memoryCounter) RecordPushes(roomID string, value int64) error {
+	now := time.Now().Unix()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buckets, ok := m.pushes[roomID]
+	if !ok {
+		buckets = make(map[int64]int64)
+		m.pushes[roomID] = buckets
+	}
+	buckets[now] += value
+	for sec := range buckets {
+		if now-sec > int64(pushRateRetention/time.Second) {
+			delete(buckets, sec)
+		}
+	}
+	return nil
+}
+
+// GetPushRate: 直近 window 秒のバケットを合計して平均レートを返す
+func (m *memoryCounter) GetPushRate(roomID string, window time.Duration) (float64, error) {
+	secs := pushRateSeconds(window)
+	now := time.Now().Unix()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var total int64
+	for sec, c := range m.pushes[roomID] {
+		if now-sec < secs {
+			total += c
+		}
+	}
+	return float64(total) / float64(secs), nil
+}
+
+// TakeTokens: トークンバケットから最大 n 個払い出す (制限無効時は全量許可)
+func (m *memoryCounter) TakeTokens(key string, bucket TokenBucket, n int64) (*TakeResult, error) {
+	if !bucket.Enabled() || n <= 0 {
+		return &TakeResult{Granted: n}, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(bucket.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	tokens, res := takeFromBucket(bucket, refillTokens(bucket, b.tokens, now.Sub(b.updated)), n)
+	b.tokens, b.updated = tokens, now
+	return res, nil
+}
@@ -0,0 +1,257 @@
+package counter
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// runConcurrentIncrementAndCheck: workers × perWorker 回、1ずつ同時に加算して発動回数を集計する
+func runConcurrentIncrementAndCheck(t *testing.T, c Counter, roomID, eventType string, rule TriggerRule, workers, perWorker int) int64 {
+	t.Helper()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		triggers int64
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				res, err := c.IncrementAndCheck(roomID, eventType, 1, rule)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if res != nil {
+					triggers += res.Triggers
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", firstErr)
+	}
+	return triggers
+}
+
+// assertIncrementAndCheckConcurrent: 同時実行しても発動回数と持ち越しカウントが直列実行と一致することを検証
+func assertIncrementAndCheckConcurrent(t *testing.T, c Counter, roomID string) {
+	t.Helper()
+
+	// 固定閾値: 200回押下 / 閾値10 → ちょうど20回発動し、カウントは0
+	triggers := runConcurrentIncrementAndCheck(t, c, roomID, "skill1", TriggerRule{Threshold: 10, LevelMultiplier: 1.0}, 20, 10)
+	if triggers != 20 {
+		t.Errorf("fixed threshold: expected 20 triggers, got %d", triggers)
+	}
+	if got, _ := c.Get(roomID, "skill1"); got != 0 {
+		t.Errorf("fixed threshold: expected count 0, got %d", got)
+	}
+
+	// レベル倍率2.0: 閾値 5,10,20,40 (計75) で4回発動し、残り25が持ち越される
+	triggers = runConcurrentIncrementAndCheck(t, c, roomID, "enemy1", TriggerRule{Threshold: 5, LevelMultiplier: 2.0}, 10, 10)
+	if triggers != 4 {
+		t.Errorf("level multiplier: expected 4 triggers, got %d", triggers)
+	}
+	if got, _ := c.Get(roomID, "enemy1"); got != 25 {
+		t.Errorf("level multiplier: expected count 25, got %d", got)
+	}
+	levels, err := c.GetLevels(roomID, []string{"enemy1"})
+	if err != nil {
+		t.Fatalf("GetLevels failed: %v", err)
+	}
+	if levels["enemy1"] != 5 {
+		t.Errorf("level multiplier: expected level 5, got %d", levels["enemy1"])
+	}
+}
+
+func TestMemoryCounter_IncrementAndCheckConcurrent(t *testing.T) {
+	assertIncrementAndCheckConcurrent(t, NewMemoryCounter(), "room-test")
+}
+
+func TestMemoryCounter_IncrementAndCheckCarriesExcess(t *testing.T) {
+	c := NewMemoryCounter()
+	rule := TriggerRule{Threshold: 5, LevelMultiplier: 1.5}
+
+	res, err := c.IncrementAndCheck("room-test", "skill1", 7, rule)
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if !res.Triggered || res.Count != 2 || res.Level != 2 || res.Threshold != 5 {
+		t.Fatalf("unexpected result after first trigger: %+v", res)
+	}
+
+	// レベル2の閾値は ceil(5 × 1.5) = 8
+	res, err = c.IncrementAndCheck("room-test", "skill1", 5, rule)
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if res.Triggered || res.Count != 7 || res.Threshold != 8 {
+		t.Fatalf("unexpected result below threshold: %+v", res)
+	}
+
+	// Reset でカウントとレベルが初期化される
+	if err := c.Reset("room-test", "skill1"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	levels, _ := c.GetLevels("room-test", []string{"skill1"})
+	if got, _ := c.Get("room-test", "skill1"); got != 0 || levels["skill1"] != 1 {
+		t.Errorf("expected count 0 and level 1 after reset, got count=%d level=%d", got, levels["skill1"])
+	}
+}
+
+func TestMemoryCounter_IncrementAndCheckMultipleTriggers(t *testing.T) {
+	c := NewMemoryCounter()
+
+	// 閾値 5 → 10 → 20: 40回押下で 5+10+20=35 を消費して3回発動、残り5
+	res, err := c.IncrementAndCheck("room-test", "enemy1", 40, TriggerRule{Threshold: 5, LevelMultiplier: 2.0})
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if res.Triggers != 3 || res.Consumed != 35 || res.Count != 5 || res.Level != 4 || res.Threshold != 5 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestMemoryCounter_IncrementAndCheckMaxTriggers(t *testing.T) {
+	c := NewMemoryCounter()
+
+	// 閾値 1 固定: 250回押下でも1回の呼び出しで発動するのは上限の100回まで、残りは持ち越し
+	rule := TriggerRule{Threshold: 1, LevelMultiplier: 1.0}
+	res, err := c.IncrementAndCheck("room-test", "enemy1", 250, rule)
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if res.Triggers != DefaultMaxTriggers || res.Consumed != DefaultMaxTriggers || res.Count != 150 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	rule.MaxTriggers = 200
+	res, err = c.IncrementAndCheck("room-test", "enemy1", 0, rule)
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if res.Triggers != 150 || res.Count != 0 || res.Level != 251 {
+		t.Fatalf("unexpected result after carry-over: %+v", res)
+	}
+}
+
+func TestMemoryCounter_IncrementAndCheckCooldownAndRoomLimit(t *testing.T) {
+	c := NewMemoryCounter()
+
+	// クールダウン中は閾値を超えても発動せず、カウントは持ち越される
+	rule := TriggerRule{Threshold: 5, LevelMultiplier: 1.0, Cooldown: time.Hour}
+	res, err := c.IncrementAndCheck("room-test", "skill1", 12, rule)
+	if err != nil {
+		t.Fatalf("IncrementAndCheck failed: %v", err)
+	}
+	if res.Triggers != 1 || !res.Throttled || res.Count != 7 || res.CooldownRemaining <= 0 {
+		t.Fatalf("unexpected result with cooldown: %+v", res)
+	}
+	cooldowns, _ := c.GetCooldowns("room-test", []string{"skill1", "skill2"})
+	if cooldowns["skill1"] <= 0 || cooldowns["skill2"] != 0 {
+		t.Errorf("unexpected cooldowns: %v", cooldowns)
+	}
+
+	// ルーム上限: 窓内2回まで。別イベント種別でも上限は共有される
+	limit := TriggerRule{Threshold: 1, LevelMultiplier: 1.0, RoomLimit: 2, RoomWindow: time.Hour}
+	res, _ = c.IncrementAndCheck("room-limit", "enemy1", 3, limit)
+	if res.Triggers != 2 || !res.Throttled || res.Count != 1 {
+		t.Fatalf("unexpected result with room limit: %+v", res)
+	}
+	res, _ = c.IncrementAndCheck("room-limit", "enemy2", 1, limit)
+	if res.Triggered || !res.Throttled || res.CooldownRemaining <= 0 {
+		t.Fatalf("expected room limit to throttle other event types: %+v", res)
+	}
+}
+
+func TestMemoryCounter_TakeTokens(t *testing.T) {
+	c := NewMemoryCounter()
+	bucket := TokenBucket{Rate: 1, Burst: 10}
+
+	// 容量までは全量、超えた分は払い出さず補充待ち時間を返す
+	res, err := c.TakeTokens("viewer:a:push", bucket, 15)
+	if err != nil {
+		t.Fatalf("TakeTokens failed: %v", err)
+	}
+	if res.Granted != 10 || res.Remaining != 0 || res.RetryAfter <= 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	res, _ = c.TakeTokens("viewer:a:push", bucket, 1)
+	if res.Granted != 0 {
+		t.Fatalf("expected empty bucket, got %+v", res)
+	}
+
+	// バケットはキーごとに独立
+	res, _ = c.TakeTokens("viewer:b:push", bucket, 1)
+	if res.Granted != 1 {
+		t.Fatalf("expected independent bucket, got %+v", res)
+	}
+}
+
+func TestMemoryCounter_BufferedPushes(t *testing.T) {
+	c := NewMemoryCounter()
+
+	// 保留中の押下はカウンタに加算されない
+	if err := c.AddBufferedPushes("room-1", map[string]int64{"skill1": 3, "enemy1": 0}); err != nil {
+		t.Fatalf("AddBufferedPushes failed: %v", err)
+	}
+	_ = c.AddBufferedPushes("room-1", map[string]int64{"skill1": 2, "skill2": 1})
+	if got, _ := c.Get("room-1", "skill1"); got != 0 {
+		t.Fatalf("expected buffered pushes not to be counted, got %d", got)
+	}
+	buffered, _ := c.GetBufferedPushes("room-1")
+	if buffered["skill1"] != 5 || buffered["skill2"] != 1 || len(buffered) != 2 {
+		t.Fatalf("unexpected buffered pushes: %v", buffered)
+	}
+
+	// 取り出しは一度だけ
+	taken, err := c.TakeBufferedPushes("room-1")
+	if err != nil {
+		t.Fatalf("TakeBufferedPushes failed: %v", err)
+	}
+	if taken["skill1"] != 5 || taken["skill2"] != 1 {
+		t.Fatalf("unexpected taken pushes: %v", taken)
+	}
+	if again, _ := c.TakeBufferedPushes("room-1"); len(again) != 0 {
+		t.Fatalf("expected empty buffer after take, got %v", again)
+	}
+
+	// Reset で保留中の押下も破棄される
+	_ = c.AddBufferedPushes("room-1", map[string]int64{"skill1": 4})
+	_ = c.Reset("room-1", "skill1")
+	if buffered, _ := c.GetBufferedPushes("room-1"); len(buffered) != 0 {
+		t.Fatalf("expected reset to drop buffered pushes, got %v", buffered)
+	}
+}
+
+func TestMemoryCounter_DeleteRoom(t *testing.T) {
+	c := NewMemoryCounter()
+	_, _ = c.IncrementAndCheck("room-1", "skill1", 12, TriggerRule{Threshold: 10, LevelMultiplier: 1.0})
+	_ = c.UpdateViewerActivity("room-1", "viewer-a")
+	_ = c.AddBufferedPushes("room-1", map[string]int64{"skill1": 2})
+	_, _ = c.Increment("room-2", "skill1", 3)
+
+	if err := c.DeleteRoom("room-1", []string{"skill1"}); err != nil {
+		t.Fatalf("DeleteRoom failed: %v", err)
+	}
+	if got, _ := c.Get("room-1", "skill1"); got != 0 {
+		t.Errorf("expected count 0 after delete, got %d", got)
+	}
+	if levels, _ := c.GetLevels("room-1", []string{"skill1"}); levels["skill1"] != 1 {
+		t.Errorf("expected level 1 after delete, got %d", levels["skill1"])
+	}
+	if viewers, _ := c.GetActiveViewerCount("room-1"); viewers != 0 {
+		t.Errorf("expected no active viewers after delete, got %d", viewers)
+	}
+	if buffered, _ := c.GetBufferedPushes("room-1"); len(buffered) != 0 {
+		t.Errorf("expected no buffered pushes after delete, got %v", buffered)
+	}
+	// 他のルームには影響しない
+	if got, _ := c.Get("room-2", "skill1"); got != 3 {
+		t.Errorf("expected other room untouched, got %d", got)
+	}
+}
@@ -0,0 +1,18 @@
+package counter
+
+import "time"
+
+// pushRateRetention: 押下レート計測用バケットの保持期間 (GetPushRate の window 上限)
+const pushRateRetention = time.Minute
+
+// pushRateSeconds: 計測窓を秒数に丸める (1秒〜保持期間)
+func pushRateSeconds(window time.Duration) int64 {
+	secs := int64(window / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	if max := int64(pushRateRetention / time.Second); secs > max {
+		secs = max
+	}
+	return secs
+}
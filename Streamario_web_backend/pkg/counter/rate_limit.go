@@ -0,0 +1,58 @@
+package counter
+
+import (
+	"math"
+	"time"
+)
+
+// TokenBucket: トークンバケット方式のレート制限設定
+type TokenBucket struct {
+	Rate  float64 // 1秒あたりの補充トークン数 (0以下は制限なし)
+	Burst int64   // バケット容量 (瞬間的に許容する最大数)
+}
+
+// Enabled: 制限が有効か
+func (b TokenBucket) Enabled() bool {
+	return b.Rate > 0 && b.Burst > 0
+}
+
+// ttl: バケットが満タンに戻るまでの時間 (これを過ぎた状態は破棄してよい)
+func (b TokenBucket) ttl() time.Duration {
+	return time.Duration(math.Ceil(float64(b.Burst)/b.Rate*1000))*time.Millisecond + time.Second
+}
+
+// TakeResult: TakeTokens の結果
+type TakeResult struct {
+	Granted    int64         // 払い出したトークン数 (要求数以下)
+	Remaining  int64         // 払い出し後にバケットに残ったトークン数 (切り捨て)
+	RetryAfter time.Duration // 要求数に満たなかった場合、不足分が補充されるまでの時間
+}
+
+// refillTokens: 経過時間分を補充したトークン数 (容量で頭打ち)
+func refillTokens(b TokenBucket, tokens float64, elapsed time.Duration) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * b.Rate
+	}
+	return math.Min(tokens, float64(b.Burst))
+}
+
+// takeFromBucket: 補充済みトークンから最大 n 個払い出し、残量と結果を返す (memory / Redis で共通の計算)
+func takeFromBucket(b TokenBucket, tokens float64, n int64) (float64, *TakeResult) {
+	granted := int64(math.Floor(tokens))
+	if granted > n {
+		granted = n
+	}
+	if granted < 0 {
+		granted = 0
+	}
+	tokens -= float64(granted)
+	res := &TakeResult{Granted: granted, Remaining: int64(math.Floor(tokens))}
+	if granted < n {
+		need := float64(n-granted) - tokens
+		if max := float64(b.Burst) - tokens; need > max {
+			need = max
+		}
+		res.RetryAfter = time.Duration(math.Ceil(need/b.Rate*1000)) * time.Millisecond
+	}
+	return tokens, res
+}
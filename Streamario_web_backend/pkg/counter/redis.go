@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -28,9 +29,30 @@ func NewRedisCounter(rdb *redis.Client, logger *slog.Logger) Counter {
 func (rc *redisCounter) keyCount(roomID, eventType string) string {
 	return fmt.Sprintf("room:%s:cnt:%s", roomID, eventType)
 }
+func (rc *redisCounter) keyLevel(roomID, eventType string) string {
+	return fmt.Sprintf("room:%s:lvl:%s", roomID, eventType)
+}
+func (rc *redisCounter) keyCooldown(roomID, eventType string) string {
+	return fmt.Sprintf("room:%s:cd:%s", roomID, eventType)
+}
+func (rc *redisCounter) keyTriggers(roomID string) string {
+	return fmt.Sprintf("room:%s:triggers", roomID)
+}
+func (rc *redisCounter) keyBucket(key string) string {
+	return fmt.Sprintf("ratelimit:%s", key)
+}
+func (rc *redisCounter) keyPendingWrites(roomID string) string {
+	return fmt.Sprintf("room:%s:pending_writes", roomID)
+}
+func (rc *redisCounter) keyBuffered(roomID string) string {
+	return fmt.Sprintf("room:%s:buffered", roomID)
+}
 func (rc *redisCounter) keyViewers(roomID string) string {
 	return fmt.Sprintf("room:%s:viewers", roomID)
 }
+func (rc *redisCounter) keyPushRate(roomID string, unixSec int64) string {
+	return fmt.Sprintf("room:%s:rate:%d", roomID, unixSec)
+}
 
 // Increment: Redis　IncrByでvalueだけ加算し現在値返却
 func (rc *redisCounter) Increment(roomID, eventType string, value int64) (int64, error) {
@@ -122,7 +144,7 @@ func (rc *redisCounter) GetMulti(roomID string, eventTypes []string) (map[string
 	return result, nil
 }
 
-// Reset: カウントキー削除
+// Reset: カウント / レベル / クールダウンのキーと保留中の押下を削除
 func (rc *redisCounter) Reset(roomID, eventType string) error {
 	key := rc.keyCount(roomID, eventType)
 	logger := rc.logger.With(
@@ -132,7 +154,11 @@ func (rc *redisCounter) Reset(roomID, eventType string) error {
 		slog.String("key", key),
 	)
 	start := time.Now()
-	if err := rc.rdb.Del(context.Background(), key).Err(); err != nil {
+	ctx := context.Background()
+	pipe := rc.rdb.TxPipeline()
+	pipe.Del(ctx, key, rc.keyLevel(roomID, eventType), rc.keyCooldown(roomID, eventType))
+	pipe.HDel(ctx, rc.keyBuffered(roomID), eventType)
+	if _, err := pipe.Exec(ctx); err != nil {
 		logger.Warn("redis.del failed", slog.Any("error", err))
 		return err
 	}
@@ -140,25 +166,213 @@ func (rc *redisCounter) Reset(roomID, eventType string) error {
 	return nil
 }
 
-// SetExcess: 閾値超過分をカウントに設定（超過分を捨てない）
-func (rc *redisCounter) SetExcess(roomID, eventType string, excess int64) error {
-	key := rc.keyCount(roomID, eventType)
+// DeleteRoom: ルームのキーをまとめて DEL (押下レートのバケットは保持期間で自然に消える)
+func (rc *redisCounter) DeleteRoom(roomID string, eventTypes []string) error {
+	keys := []string{rc.keyTriggers(roomID), rc.keyViewers(roomID), rc.keyBuffered(roomID), rc.keyPendingWrites(roomID)}
+	for _, et := range eventTypes {
+		keys = append(keys, rc.keyCount(roomID, et), rc.keyLevel(roomID, et), rc.keyCooldown(roomID, et))
+	}
 	logger := rc.logger.With(
-		slog.String("op", "set_excess"),
+		slog.String("op", "delete_room"),
 		slog.String("room_id", roomID),
-		slog.String("event_type", eventType),
-		slog.String("key", key),
-		slog.Int64("excess", excess),
+		slog.Int("key_count", len(keys)),
 	)
 	start := time.Now()
-	if err := rc.rdb.Set(context.Background(), key, excess, 0).Err(); err != nil {
-		logger.Error("redis.set failed", slog.Any("error", err))
+	deleted, err := rc.rdb.Del(context.Background(), keys...).Result()
+	if err != nil {
+		logger.Warn("redis.del failed", slog.Any("error", err))
 		return err
 	}
-	logger.Debug("redis.set", slog.Int64("excess", excess), slog.Duration("elapsed", time.Since(start)))
+	logger.Debug("redis.del", slog.Int64("deleted", deleted), slog.Duration("elapsed", time.Since(start)))
 	return nil
 }
 
+// incrementAndCheckScript: INCRBY → 閾値判定 → クールダウン / ルーム上限判定 → 超過分持ち越し → レベル INCR を1スクリプトで実行
+// 複数レベル分の閾値を超えた場合は、クールダウン / ルーム上限の範囲で超えた回数だけ発動する (1回の呼び出しで最大 ARGV[9] 回)。
+// カウントは消費分を DECRBY で減らす (Lua の数値を SET すると大きな値が指数表記で保存され、以後 INCRBY できなくなるため)。
+// KEYS[1]=カウント, KEYS[2]=発動回数, KEYS[3]=クールダウン, KEYS[4]=ルーム発動履歴(ZSET)
+// ARGV[1]=加算値, ARGV[2]=レベル1の閾値, ARGV[3]=レベル倍率, ARGV[4]=クールダウン(ms),
+// ARGV[5]=ルーム上限, ARGV[6]=ルーム計測窓(ms), ARGV[7]=現在時刻(ms), ARGV[8]=イベント種別, ARGV[9]=発動回数の上限
+// 閾値計算は TriggerRule.ThresholdAt と同じ式。
+// 戻り値は {カウント, 発動回数(今回), 累計発動回数, 最初の閾値, 消費カウント, 次に発動できるまでの残り(ms), 見送りフラグ}
+var incrementAndCheckScript = redis.NewScript(`
+local count = redis.call('INCRBY', KEYS[1], ARGV[1])
+local fired = tonumber(redis.call('GET', KEYS[2]) or '0')
+local base = tonumber(ARGV[2])
+local mult = tonumber(ARGV[3])
+local cooldown = tonumber(ARGV[4])
+local limit = tonumber(ARGV[5])
+local window = tonumber(ARGV[6])
+local now = tonumber(ARGV[7])
+local max_triggers = tonumber(ARGV[9])
+if base < 1 then base = 1 end
+local function threshold_at(n)
+  if n > 0 and mult > 0 then
+    return math.ceil(base * math.pow(mult, n))
+  end
+  return base
+end
+local limited = limit > 0 and window > 0
+local used = 0
+if limited then
+  redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', now - window)
+  used = redis.call('ZCARD', KEYS[4])
+end
+local wait = 0
+if cooldown > 0 then
+  wait = redis.call('PTTL', KEYS[3])
+  if wait < 0 then wait = 0 end
+end
+local first = threshold_at(fired)
+local th = first
+local triggers = 0
+local consumed = 0
+local throttled = 0
+while count >= th and triggers < max_triggers do
+  if wait > 0 then
+    throttled = 1
+    break
+  end
+  if limited and used >= limit then
+    throttled = 1
+    local oldest = redis.call('ZRANGE', KEYS[4], 0, 0, 'WITHSCORES')
+    if oldest[2] then
+      wait = tonumber(oldest[2]) + window - now
+    end
+    break
+  end
+  count = count - th
+  consumed = consumed + th
+  triggers = triggers + 1
+  fired = fired + 1
+  if limited then
+    redis.call('ZADD', KEYS[4], now, ARGV[8] .. ':' .. fired .. ':' .. now)
+    used = used + 1
+  end
+  if cooldown > 0 then
+    wait = cooldown
+  end
+  th = threshold_at(fired)
+end
+if triggers > 0 then
+  redis.call('DECRBY', KEYS[1], string.format('%d', consumed))
+  redis.call('SET', KEYS[2], string.format('%d', fired))
+  if cooldown > 0 then
+    redis.call('SET', KEYS[3], '1', 'PX', cooldown)
+  end
+  if limited then
+    redis.call('PEXPIRE', KEYS[4], window)
+  end
+end
+return {count, triggers, fired, first, consumed, wait, throttled}
+`)
+
+// IncrementAndCheck: Lua スクリプトで加算と閾値判定を原子的に実行 (複数インスタンスでも発動は1回のみ)
+func (rc *redisCounter) IncrementAndCheck(roomID, eventType string, value int64, rule TriggerRule) (*TriggerResult, error) {
+	countKey := rc.keyCount(roomID, eventType)
+	logger := rc.logger.With(
+		slog.String("op", "increment_and_check"),
+		slog.String("room_id", roomID),
+		slog.String("event_type", eventType),
+		slog.String("key", countKey),
+	)
+	var roomLimit, roomWindow int64
+	if rule.roomLimited() {
+		roomLimit, roomWindow = rule.RoomLimit, rule.RoomWindow.Milliseconds()
+	}
+	start := time.Now()
+	vals, err := incrementAndCheckScript.Run(context.Background(), rc.rdb,
+		[]string{countKey, rc.keyLevel(roomID, eventType), rc.keyCooldown(roomID, eventType), rc.keyTriggers(roomID)},
+		value, rule.Threshold, strconv.FormatFloat(rule.LevelMultiplier, 'g', -1, 64),
+		rule.Cooldown.Milliseconds(), roomLimit, roomWindow, start.UnixMilli(), eventType, rule.maxTriggers(),
+	).Int64Slice()
+	if err != nil {
+		logger.Error("redis.evalsha failed", slog.Any("error", err))
+		return nil, err
+	}
+	if len(vals) != 7 {
+		return nil, fmt.Errorf("unexpected script result length %d", len(vals))
+	}
+	res := &TriggerResult{
+		Count:             vals[0],
+		Triggers:          vals[1],
+		Triggered:         vals[1] > 0,
+		Level:             vals[2] + 1,
+		Threshold:         vals[3],
+		Consumed:          vals[4],
+		CooldownRemaining: time.Duration(vals[5]) * time.Millisecond,
+		Throttled:         vals[6] == 1,
+	}
+	logger.Debug("redis.evalsha", slog.Int64("value", res.Count), slog.Int64("triggers", res.Triggers), slog.Int64("level", res.Level), slog.Bool("throttled", res.Throttled), slog.Duration("elapsed", time.Since(start)))
+	return res, nil
+}
+
+// GetCooldowns: 複数イベントのクールダウン残り時間を PTTL のパイプラインで一括取得
+func (rc *redisCounter) GetCooldowns(roomID string, eventTypes []string) (map[string]time.Duration, error) {
+	result := make(map[string]time.Duration, len(eventTypes))
+	if len(eventTypes) == 0 {
+		return result, nil
+	}
+	logger := rc.logger.With(
+		slog.String("op", "get_cooldowns"),
+		slog.String("room_id", roomID),
+		slog.Int("count", len(eventTypes)),
+	)
+	start := time.Now()
+	ctx := context.Background()
+	pipe := rc.rdb.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(eventTypes))
+	for i, et := range eventTypes {
+		cmds[i] = pipe.PTTL(ctx, rc.keyCooldown(roomID, et))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("redis.pttl failed", slog.Any("error", err))
+		return nil, err
+	}
+	for i, et := range eventTypes {
+		// キーなし (-2) / TTLなし (-1) はクールダウン外
+		if d := cmds[i].Val(); d > 0 {
+			result[et] = d
+		} else {
+			result[et] = 0
+		}
+	}
+	logger.Debug("redis.pttl", slog.Duration("elapsed", time.Since(start)))
+	return result, nil
+}
+
+// GetLevels: 複数イベントのレベルを MGET で一括取得 (キーが無ければレベル1)
+func (rc *redisCounter) GetLevels(roomID string, eventTypes []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(eventTypes))
+	if len(eventTypes) == 0 {
+		return result, nil
+	}
+	keys := make([]string, len(eventTypes))
+	for i, et := range eventTypes {
+		keys[i] = rc.keyLevel(roomID, et)
+	}
+	logger := rc.logger.With(
+		slog.String("op", "get_levels"),
+		slog.String("room_id", roomID),
+		slog.Int("count", len(keys)),
+	)
+	start := time.Now()
+	vals, err := rc.rdb.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		logger.Error("redis.mget failed", slog.Any("error", err))
+		return nil, err
+	}
+	logger.Debug("redis.mget", slog.Duration("elapsed", time.Since(start)))
+	for i, val := range vals {
+		var triggers int64
+		if v, ok := val.(string); ok {
+			fmt.Sscanf(v, "%d", &triggers)
+		}
+		result[eventTypes[i]] = triggers + 1
+	}
+	return result, nil
+}
+
 // UpdateViewerActivity: ZSET に時刻をスコアとして追加し古い視聴者をクリーン
 func (rc *redisCounter) UpdateViewerActivity(roomID, viewerID string) error {
 	key := rc.keyViewers(roomID)
@@ -206,3 +420,237 @@ func (rc *redisCounter) GetActiveViewerCount(roomID string) (int64, error) {
 	logger.Debug("redis.zcount", slog.Int64("count", count), slog.Duration("elapsed", time.Since(start)), slog.Int64("cutoff", cutoff))
 	return count, nil
 }
+
+// RecordPushes: 秒単位バケット (room:{id}:rate:{unix秒}) に加算し、保持期間で自動失効させる
+func (rc *redisCounter) RecordPushes(roomID string, value int64) error {
+	key := rc.keyPushRate(roomID, time.Now().Unix())
+	logger := rc.logger.With(
+		slog.String("op", "record_pushes"),
+		slog.String("room_id", roomID),
+		slog.String("key", key),
+	)
+	start := time.Now()
+	pipe := rc.rdb.TxPipeline()
+	pipe.IncrBy(context.Background(), key, value)
+	pipe.Expire(context.Background(), key, pushRateRetention)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		logger.Error("redis.incrby failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("redis.incrby", slog.Int64("value", value), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// pendingWritesTTL: 書き込み待ち数キーの保持期間 (書き込み途中でインスタンスが落ちた場合の残骸を消す)
+const pendingWritesTTL = 10 * time.Minute
+
+// AddPendingWrites: INCRBY + EXPIRE をパイプラインで実行
+func (rc *redisCounter) AddPendingWrites(roomID string, delta int64) error {
+	key := rc.keyPendingWrites(roomID)
+	logger := rc.logger.With(
+		slog.String("op", "add_pending_writes"),
+		slog.String("room_id", roomID),
+		slog.String("key", key),
+		slog.Int64("delta", delta),
+	)
+	start := time.Now()
+	ctx := context.Background()
+	pipe := rc.rdb.TxPipeline()
+	pipe.IncrBy(ctx, key, delta)
+	pipe.Expire(ctx, key, pendingWritesTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("redis.incrby failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("redis.incrby", slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// GetPendingWrites: 書き込み待ち数取得 (キー無し / 負値は0)
+func (rc *redisCounter) GetPendingWrites(roomID string) (int64, error) {
+	key := rc.keyPendingWrites(roomID)
+	val, err := rc.rdb.Get(context.Background(), key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		rc.logger.Error("redis.get failed", slog.String("op", "get_pending_writes"), slog.String("room_id", roomID), slog.String("key", key), slog.Any("error", err))
+		return 0, err
+	}
+	if val < 0 {
+		return 0, nil
+	}
+	return val, nil
+}
+
+// bufferedPushesTTL: 保留中の押下の保持期間 (再開されないまま放置されたルームの残骸を消す)
+const bufferedPushesTTL = 6 * time.Hour
+
+// AddBufferedPushes: HINCRBY + EXPIRE をパイプラインで実行
+func (rc *redisCounter) AddBufferedPushes(roomID string, counts map[string]int64) error {
+	key := rc.keyBuffered(roomID)
+	logger := rc.logger.With(
+		slog.String("op", "add_buffered_pushes"),
+		slog.String("room_id", roomID),
+		slog.String("key", key),
+	)
+	start := time.Now()
+	ctx := context.Background()
+	pipe := rc.rdb.TxPipeline()
+	queued := 0
+	for et, n := range counts {
+		if n <= 0 {
+			continue
+		}
+		pipe.HIncrBy(ctx, key, et, n)
+		queued++
+	}
+	if queued == 0 {
+		return nil
+	}
+	pipe.Expire(ctx, key, bufferedPushesTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("redis.hincrby failed", slog.Any("error", err))
+		return err
+	}
+	logger.Debug("redis.hincrby", slog.Int("event_types", queued), slog.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// GetBufferedPushes: HGETALL で保留中の押下数を取得
+func (rc *redisCounter) GetBufferedPushes(roomID string) (map[string]int64, error) {
+	key := rc.keyBuffered(roomID)
+	vals, err := rc.rdb.HGetAll(context.Background(), key).Result()
+	if err != nil {
+		rc.logger.Error("redis.hgetall failed", slog.String("op", "get_buffered_pushes"), slog.String("room_id", roomID), slog.String("key", key), slog.Any("error", err))
+		return nil, err
+	}
+	return parseBufferedPushes(vals), nil
+}
+
+// TakeBufferedPushes: HGETALL + DEL を MULTI で実行 (同時に再開処理が走っても反映は一度だけ)
+func (rc *redisCounter) TakeBufferedPushes(roomID string) (map[string]int64, error) {
+	key := rc.keyBuffered(roomID)
+	logger := rc.logger.With(
+		slog.String("op", "take_buffered_pushes"),
+		slog.String("room_id", roomID),
+		slog.String("key", key),
+	)
+	start := time.Now()
+	ctx := context.Background()
+	pipe := rc.rdb.TxPipeline()
+	get := pipe.HGetAll(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("redis.hgetall+del failed", slog.Any("error", err))
+		return nil, err
+	}
+	result := parseBufferedPushes(get.Val())
+	logger.Debug("redis.hgetall+del", slog.Int("event_types", len(result)), slog.Duration("elapsed", time.Since(start)))
+	return result, nil
+}
+
+// parseBufferedPushes: HGETALL の結果を数値に変換 (不正な値は無視)
+func parseBufferedPushes(vals map[string]string) map[string]int64 {
+	result := make(map[string]int64, len(vals))
+	for et, raw := range vals {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			continue
+		}
+		result[et] = n
+	}
+	return result
+}
+
+// GetPushRate: 直近 window 秒分のバケットを MGET で合計して平均レートを返す
+func (rc *redisCounter) GetPushRate(roomID string, window time.Duration) (float64, error) {
+	secs := pushRateSeconds(window)
+	now := time.Now().Unix()
+	keys := make([]string, 0, secs)
+	for i := int64(0); i < secs; i++ {
+		keys = append(keys, rc.keyPushRate(roomID, now-i))
+	}
+	logger := rc.logger.With(
+		slog.String("op", "get_push_rate"),
+		slog.String("room_id", roomID),
+		slog.Int64("window_sec", secs),
+	)
+	start := time.Now()
+	vals, err := rc.rdb.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		logger.Error("redis.mget failed", slog.Any("error", err))
+		return 0, err
+	}
+	var total int64
+	for _, val := range vals {
+		if v, ok := val.(string); ok {
+			var iv int64
+			fmt.Sscanf(v, "%d", &iv)
+			total += iv
+		}
+	}
+	logger.Debug("redis.mget", slog.Int64("total", total), slog.Duration("elapsed", time.Since(start)))
+	return float64(total) / float64(secs), nil
+}
+
+// takeTokensScript: トークンバケット (HASH: tokens / ts) を補充してから最大 n 個払い出す
+// KEYS[1]=バケット / ARGV[1]=補充レート(個/秒), ARGV[2]=容量, ARGV[3]=要求数, ARGV[4]=現在時刻(ms), ARGV[5]=TTL(ms)
+// 計算は takeFromBucket と同じ。戻り値は {払い出し数, 残量(切り捨て), 不足分の補充待ち(ms)}
+var takeTokensScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = tokens + (now - ts) / 1000 * rate
+end
+if tokens > burst then tokens = burst end
+local granted = math.floor(tokens)
+if granted > n then granted = n end
+if granted < 0 then granted = 0 end
+tokens = tokens - granted
+local retry = 0
+if granted < n then
+  local need = (n - granted) - tokens
+  if need > burst - tokens then need = burst - tokens end
+  retry = math.ceil(need / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {granted, math.floor(tokens), retry}
+`)
+
+// TakeTokens: Lua スクリプトでトークンバケットを原子的に更新 (複数インスタンスで共有)
+func (rc *redisCounter) TakeTokens(key string, bucket TokenBucket, n int64) (*TakeResult, error) {
+	if !bucket.Enabled() || n <= 0 {
+		return &TakeResult{Granted: n}, nil
+	}
+	redisKey := rc.keyBucket(key)
+	logger := rc.logger.With(
+		slog.String("op", "take_tokens"),
+		slog.String("key", redisKey),
+		slog.Int64("requested", n),
+	)
+	start := time.Now()
+	vals, err := takeTokensScript.Run(context.Background(), rc.rdb, []string{redisKey},
+		strconv.FormatFloat(bucket.Rate, 'g', -1, 64), bucket.Burst, n, start.UnixMilli(), bucket.ttl().Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		logger.Error("redis.evalsha failed", slog.Any("error", err))
+		return nil, err
+	}
+	if len(vals) != 3 {
+		return nil, fmt.Errorf("unexpected script result length %d", len(vals))
+	}
+	res := &TakeResult{Granted: vals[0], Remaining: vals[1], RetryAfter: time.Duration(vals[2]) * time.Millisecond}
+	logger.Debug("redis.evalsha", slog.Int64("granted", res.Granted), slog.Duration("elapsed", time.Since(start)))
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisClient: REDIS_URL (未指定時は localhost:6379) に接続し、到達できなければテストをスキップ
+func newTestRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = "redis://localhost:6379/0"
+	}
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		opt = &redis.Options{Addr: url}
+	}
+	rdb := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
+		t.Skipf("redis not available (%s): %v", url, err)
+	}
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func TestRedisCounter_IncrementAndCheckConcurrent(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	c := NewRedisCounter(rdb, logger)
+
+	roomID := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		for _, et := range []string{"skill1", "enemy1"} {
+			_ = c.Reset(roomID, et)
+		}
+	})
+	assertIncrementAndCheckConcurrent(t, c, roomID)
+}
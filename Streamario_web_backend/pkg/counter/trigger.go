@@ -0,0 +1,61 @@
+package counter
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultMaxTriggers: 1回の IncrementAndCheck で発動する回数の既定上限
+// 倍率 1.0 で閾値が小さいと大量の押下で判定ループが長くなり、Redis (単一スレッド) を塞ぐため上限を設ける。
+const DefaultMaxTriggers = 100
+
+// TriggerRule: IncrementAndCheck に渡す閾値判定ルール
+type TriggerRule struct {
+	Threshold       int64   // レベル1の閾値 (視聴者数などを反映済み、1以上)
+	LevelMultiplier float64 // 発動ごとに閾値へ掛ける倍率 (0以下は 1.0 扱い)
+
+	Cooldown   time.Duration // 発動後、同じイベント種別が再発動できるまでの時間 (0 はクールダウンなし)
+	RoomLimit  int64         // ルーム全体で RoomWindow 内に発動できる回数の上限 (0 は無制限)
+	RoomWindow time.Duration // RoomLimit の計測窓
+
+	MaxTriggers int64 // 1回の呼び出しで発動する回数の上限 (0 以下は DefaultMaxTriggers。超えた分はカウントに持ち越し、次の呼び出しで発動する)
+}
+
+// maxTriggers: 1回の呼び出しで発動する回数の上限
+func (r TriggerRule) maxTriggers() int64 {
+	if r.MaxTriggers <= 0 {
+		return DefaultMaxTriggers
+	}
+	return r.MaxTriggers
+}
+
+// roomLimited: ルーム全体の発動回数制限が有効か
+func (r TriggerRule) roomLimited() bool {
+	return r.RoomLimit > 0 && r.RoomWindow > 0
+}
+
+// ThresholdAt: 指定レベルの閾値 = ceil(Threshold × LevelMultiplier^(level-1))
+// Redis の Lua スクリプトも同じ式で計算する。
+func (r TriggerRule) ThresholdAt(level int64) int64 {
+	th := r.Threshold
+	if th < 1 {
+		th = 1
+	}
+	if level <= 1 || r.LevelMultiplier <= 0 {
+		return th
+	}
+	return int64(math.Ceil(float64(th) * math.Pow(r.LevelMultiplier, float64(level-1))))
+}
+
+// TriggerResult: IncrementAndCheck の結果
+type TriggerResult struct {
+	Count     int64 // 処理後のカウント (発動時は閾値超過分を持ち越した値)
+	Triggered bool  // この呼び出しで発動したか
+	Triggers  int64 // この呼び出しで発動した回数 (複数レベル分の閾値を超えた場合は2以上)
+	Level     int64 // 処理後のレベル (1 + 累計発動回数)
+	Threshold int64 // 呼び出し時点のレベルの閾値 (最初に判定した閾値)
+	Consumed  int64 // 発動によって消費したカウント (発動した各レベルの閾値の合計)
+
+	Throttled         bool          // 閾値に達しているがクールダウン / ルーム上限で発動を見送った (カウントは持ち越し)
+	CooldownRemaining time.Duration // 次に発動できるまでの残り時間 (クールダウン中 / ルーム上限到達時)
+}
@@ -70,7 +70,7 @@ type cloudLoggingHandler struct {
 	opts     *slog.HandlerOptions
 	attrs    []slog.Attr
 	metadata cloudMetadata
-	mu       sync.Mutex
+	mu       *sync.Mutex // WithAttrs で複製したハンドラ間で共有する
 }
 
 type cloudMetadata struct {
@@ -79,7 +79,7 @@ type cloudMetadata struct {
 }
 
 func newCloudLoggingHandler(w io.Writer, opts *slog.HandlerOptions, meta cloudMetadata) slog.Handler {
-	return &cloudLoggingHandler{writer: w, opts: opts, metadata: meta}
+	return &cloudLoggingHandler{writer: w, opts: opts, metadata: meta, mu: &sync.Mutex{}}
 }
 
 func (h *cloudLoggingHandler) Enabled(_ context.Context, level slog.Level) bool {
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// エラー応答 (type: error) の code
+const (
+	ErrCodeMalformed          = "malformed"           // JSON として読めない
+	ErrCodeMissingType        = "missing_type"        // type が無い
+	ErrCodeUnknownType        = "unknown_type"        // 未登録の type
+	ErrCodeUnsupportedVersion = "unsupported_version" // サーバより新しい version
+	ErrCodeInvalidMessage     = "invalid_message"     // フィールドの型違い / 必須フィールド不足
+	ErrCodeUnexpectedType     = "unexpected_type"     // 登録済みだがこの方向では受け付けない type
+	ErrCodeInvalidState       = "invalid_state"       // 現在のルーム状態では受け付けない (終了済みルームへの game_start など)
+)
+
+// DecodeError: Decode の失敗理由 (Reply でそのままエラー応答にできる)
+type DecodeError struct {
+	Code string
+	Type Type // 読み取れた場合のみ
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s (type=%s): %v", e.Code, e.Type, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Code, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Reply: 送信元へ返すエラー応答
+func (e *DecodeError) Reply() Error {
+	return Error{Code: e.Code, Message: e.Err.Error(), RefType: string(e.Type)}
+}
+
+// Fields: メッセージを type / version 付きの JSON オブジェクト (map) に変換
+// 送信前に配送用フィールド (seq など) を追加する場合に使う。
+func Fields(msg Message) (map[string]interface{}, error) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields["type"] = string(msg.MessageType())
+	fields["version"] = Version
+	return fields, nil
+}
+
+// Encode: メッセージを type / version 付きの JSON に変換
+func Encode(msg Message) ([]byte, error) {
+	fields, err := Fields(msg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// Decode: JSON を読み取り、共通フィールドと type に対応するメッセージを返す
+// 定義にないフィールドは無視する (後方互換のため)。失敗時は *DecodeError を返す。
+func Decode(data []byte) (Header, Message, error) {
+	var h Header
+	if !json.Valid(data) {
+		return h, nil, &DecodeError{Code: ErrCodeMalformed, Err: fmt.Errorf("invalid json")}
+	}
+	var base struct {
+		Type    Type `json:"type"`
+		Version int  `json:"version"`
+	}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return h, nil, &DecodeError{Code: ErrCodeInvalidMessage, Err: err}
+	}
+	h.Type, h.Version = base.Type, base.Version
+	if h.Type == "" {
+		return h, nil, &DecodeError{Code: ErrCodeMissingType, Err: fmt.Errorf("type is required")}
+	}
+	e, ok := registry[h.Type]
+	if !ok {
+		return h, nil, &DecodeError{Code: ErrCodeUnknownType, Type: h.Type, Err: fmt.Errorf("unknown message type")}
+	}
+	if h.Version > Version {
+		return h, nil, &DecodeError{Code: ErrCodeUnsupportedVersion, Type: h.Type, Err: fmt.Errorf("version %d is newer than %d", h.Version, Version)}
+	}
+	if h.Version == 0 {
+		h.Version = Version
+	}
+	if err := json.Unmarshal(data, &h.Delivery); err != nil {
+		return h, nil, &DecodeError{Code: ErrCodeInvalidMessage, Type: h.Type, Err: err}
+	}
+	msg := e.new()
+	if err := json.Unmarshal(data, msg); err != nil {
+		return h, nil, &DecodeError{Code: ErrCodeInvalidMessage, Type: h.Type, Err: err}
+	}
+	if v, ok := msg.(validator); ok {
+		if err := v.validate(); err != nil {
+			return h, nil, &DecodeError{Code: ErrCodeInvalidMessage, Type: h.Type, Err: err}
+		}
+	}
+	return h, msg, nil
+}
+
+// DecodeDirection: Decode に加えて送信方向を確認 (逆方向専用のメッセージは ErrCodeUnexpectedType)
+func DecodeDirection(data []byte, dir Direction) (Header, Message, error) {
+	h, msg, err := Decode(data)
+	if err != nil {
+		return h, nil, err
+	}
+	if d := DirectionOf(h.Type); d != DirectionBoth && d != dir {
+		return h, nil, &DecodeError{Code: ErrCodeUnexpectedType, Type: h.Type, Err: fmt.Errorf("message type is not sent %s", dir)}
+	}
+	return h, msg, nil
+}
@@ -0,0 +1,152 @@
+package protocol
+
+import "errors"
+
+// --- Backend → Unity ---
+
+// RoomCreated: 新規接続時に払い出したルーム
+// RoomToken は同じ room_id で再接続する際の所有証明。
+type RoomCreated struct {
+	RoomID    string `json:"room_id"`
+	RoomToken string `json:"room_token,omitempty"`
+}
+
+func (RoomCreated) MessageType() Type       { return TypeRoomCreated }
+func (m RoomCreated) MessageRoomID() string { return m.RoomID }
+func (m RoomCreated) validate() error       { return requireRoomID(m.RoomID) }
+
+// RoomReady: 再接続を受け付けたルーム
+type RoomReady struct {
+	RoomID string `json:"room_id"`
+}
+
+func (RoomReady) MessageType() Type       { return TypeRoomReady }
+func (m RoomReady) MessageRoomID() string { return m.RoomID }
+func (m RoomReady) validate() error       { return requireRoomID(m.RoomID) }
+
+// GameEvent: 閾値到達による演出の発動
+type GameEvent struct {
+	RoomID       string  `json:"room_id"`
+	EventType    string  `json:"event_type"`
+	TriggerCount int     `json:"trigger_count"` // 到達時点のカウント
+	ViewerCount  int     `json:"viewer_count"`
+	ViewerName   *string `json:"viewer_name"` // 閾値を超える押下をした視聴者
+	Level        int     `json:"level"`       // 最後に発動したレベル
+	Multiplier   int     `json:"multiplier"`  // 発動回数 (Unity はこの回数分エフェクトを実行)
+	TriggerID    int64   `json:"trigger_id,omitempty"`
+}
+
+func (GameEvent) MessageType() Type       { return TypeGameEvent }
+func (m GameEvent) MessageRoomID() string { return m.RoomID }
+func (m GameEvent) validate() error {
+	if err := requireRoomID(m.RoomID); err != nil {
+		return err
+	}
+	if m.EventType == "" {
+		return errors.New("event_type is required")
+	}
+	return nil
+}
+
+// ViewerCountUpdate: 視聴者数の更新
+type ViewerCountUpdate struct {
+	RoomID      string `json:"room_id"`
+	ViewerCount int    `json:"viewer_count"`
+}
+
+func (ViewerCountUpdate) MessageType() Type       { return TypeViewerCountUpdate }
+func (m ViewerCountUpdate) MessageRoomID() string { return m.RoomID }
+func (m ViewerCountUpdate) validate() error       { return requireRoomID(m.RoomID) }
+
+// ViewerTop: 最多押下者
+type ViewerTop struct {
+	ViewerID   string  `json:"viewer_id"`
+	ViewerName *string `json:"viewer_name"`
+	Count      int64   `json:"count"`
+}
+
+// TeamTops: カテゴリごとの最多押下者 (該当者がいなければ null)
+type TeamTops struct {
+	Skill *ViewerTop `json:"skill"`
+	Enemy *ViewerTop `json:"enemy"`
+	All   *ViewerTop `json:"all"`
+}
+
+// GameEndSummary: ラウンド終了時の集計結果
+type GameEndSummary struct {
+	Round       int                  `json:"round"`         // 終了したラウンド (ルーム内で 1 から連番)
+	TopByButton map[string]ViewerTop `json:"top_by_button"` // イベント種別ごとの最多押下者
+	TopOverall  *ViewerTop           `json:"top_overall"`
+	TeamTops    TeamTops             `json:"team_tops"`
+}
+
+func (GameEndSummary) MessageType() Type { return TypeGameEndSummary }
+
+// Error: 受信したメッセージを処理できなかった理由
+type Error struct {
+	Code    string `json:"code"`               // ErrCode*
+	Message string `json:"message"`            // 人が読むための説明
+	RefType string `json:"ref_type,omitempty"` // 原因となったメッセージの type (分かる場合)
+}
+
+func (Error) MessageType() Type { return TypeError }
+
+// --- Unity → Backend ---
+
+// GameStart: ゲーム開始通知
+type GameStart struct{}
+
+func (GameStart) MessageType() Type { return TypeGameStart }
+
+// GameEnd: ゲーム終了通知
+type GameEnd struct{}
+
+func (GameEnd) MessageType() Type { return TypeGameEnd }
+
+// GamePause: ゲーム一時停止通知 (停止中の押下はルーム設定 pause_mode に従って拒否 / 保留する)
+type GamePause struct{}
+
+func (GamePause) MessageType() Type { return TypeGamePause }
+
+// GameResume: ゲーム再開通知 (保留していた押下はこの時点でまとめて反映する)
+type GameResume struct{}
+
+func (GameResume) MessageType() Type { return TypeGameResume }
+
+// Ack: 受信確認
+// Seq のメッセージを処理済みにする。EventID があれば Redis Streams 上の位置も EventID まで処理済みにする (累積)。
+type Ack struct {
+	Seq     uint64 `json:"seq,omitempty"`
+	EventID string `json:"event_id,omitempty"`
+}
+
+func (Ack) MessageType() Type { return TypeAck }
+func (m Ack) validate() error {
+	if m.Seq == 0 && m.EventID == "" {
+		return errors.New("seq or event_id is required")
+	}
+	return nil
+}
+
+// --- 双方向 ---
+
+// Ping: 死活確認 (受信側は TS をそのまま返す Pong で応答する)
+type Ping struct {
+	TS int64 `json:"ts,omitempty"` // 送信時刻 (Unix ミリ秒)
+}
+
+func (Ping) MessageType() Type { return TypePing }
+
+// Pong: Ping への応答
+type Pong struct {
+	TS int64 `json:"ts,omitempty"` // Ping の TS
+}
+
+func (Pong) MessageType() Type { return TypePong }
+
+func requireRoomID(roomID string) error {
+	if roomID == "" {
+		return errors.New("room_id is required")
+	}
+	return nil
+}
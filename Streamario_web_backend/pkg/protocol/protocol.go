@@ -0,0 +1,114 @@
+// Package protocol: Unity ↔ Backend 間の WebSocket メッセージ定義
+//
+// すべてのメッセージは JSON オブジェクトで、共通フィールド type / version を持つ。
+// Backend → Unity のメッセージには配送用に seq などのフィールド (Delivery) が付く。
+// Unity 側向けの JSON Schema は JSONSchema で出力する (docs/protocol.schema.json)。
+package protocol
+
+//go:generate go run ../../cmd/protocol-schema -o ../../docs/protocol.schema.json
+
+// Version: プロトコルの版 (互換性のない変更をしたら上げる)
+// version を省略したメッセージは現行版として扱う。
+const Version = 1
+
+// Type: メッセージタイプ (JSON の type フィールド)
+type Type string
+
+const (
+	// Backend → Unity
+
+	TypeRoomCreated       Type = "room_created"        // 新規接続時のルーム通知
+	TypeRoomReady         Type = "room_ready"          // 再接続時のルーム通知
+	TypeGameEvent         Type = "game_event"          // 閾値到達による演出の発動
+	TypeViewerCountUpdate Type = "viewer_count_update" // 視聴者数の更新
+	TypeGameEndSummary    Type = "game_end_summary"    // ゲーム終了時の集計結果
+	TypeError             Type = "error"               // 受信メッセージを処理できなかった
+
+	// Unity → Backend
+
+	TypeGameStart  Type = "game_start"  // ゲーム開始通知
+	TypeGameEnd    Type = "game_end"    // ゲーム終了通知
+	TypeGamePause  Type = "game_pause"  // ゲーム一時停止通知
+	TypeGameResume Type = "game_resume" // ゲーム再開通知
+	TypeAck        Type = "ack"         // 受信確認
+
+	// 双方向
+
+	TypePing Type = "ping" // 死活確認
+	TypePong Type = "pong" // ping への応答
+)
+
+// Direction: メッセージの送信方向
+type Direction string
+
+const (
+	DirectionToUnity   Direction = "backend_to_unity"
+	DirectionToBackend Direction = "unity_to_backend"
+	DirectionBoth      Direction = "both"
+)
+
+// Message: Unity とやり取りするメッセージ本体 (type / version は Encode が付ける)
+type Message interface {
+	MessageType() Type
+}
+
+// RoomMessage: 宛先ルームを持つメッセージ (Pub/Sub 経由で配信先を決めるのに使う)
+type RoomMessage interface {
+	Message
+	MessageRoomID() string
+}
+
+// validator: 必須フィールドなどの検証を持つメッセージ (Decode で呼ぶ)
+type validator interface {
+	validate() error
+}
+
+// Header: 全メッセージ共通のフィールド
+type Header struct {
+	Type    Type `json:"type"`
+	Version int  `json:"version"`
+	Delivery
+}
+
+// Delivery: Backend → Unity の送信時に付く配送情報
+type Delivery struct {
+	Seq      uint64 `json:"seq,omitempty"`      // 接続内で単調増加する番号 (ack で返す)
+	EventID  string `json:"event_id,omitempty"` // Redis Streams 上の ID (再接続時の再送位置)
+	Resend   bool   `json:"resend,omitempty"`   // ack が無かったための再送
+	Replayed bool   `json:"replayed,omitempty"` // 再接続時の取りこぼし分の再送
+	Relayed  bool   `json:"relayed,omitempty"`  // 管理者 / 配信者が relay で送ったもの
+}
+
+// entry: メッセージタイプの登録情報
+type entry struct {
+	direction Direction
+	new       func() Message
+}
+
+// registry: Decode / JSON Schema の対象となるメッセージタイプ
+var registry = map[Type]entry{
+	TypeRoomCreated:       {DirectionToUnity, func() Message { return &RoomCreated{} }},
+	TypeRoomReady:         {DirectionToUnity, func() Message { return &RoomReady{} }},
+	TypeGameEvent:         {DirectionToUnity, func() Message { return &GameEvent{} }},
+	TypeViewerCountUpdate: {DirectionToUnity, func() Message { return &ViewerCountUpdate{} }},
+	TypeGameEndSummary:    {DirectionToUnity, func() Message { return &GameEndSummary{} }},
+	TypeError:             {DirectionToUnity, func() Message { return &Error{} }},
+	TypeGameStart:         {DirectionToBackend, func() Message { return &GameStart{} }},
+	TypeGameEnd:           {DirectionToBackend, func() Message { return &GameEnd{} }},
+	TypeGamePause:         {DirectionToBackend, func() Message { return &GamePause{} }},
+	TypeGameResume:        {DirectionToBackend, func() Message { return &GameResume{} }},
+	TypeAck:               {DirectionToBackend, func() Message { return &Ack{} }},
+	TypePing:              {DirectionBoth, func() Message { return &Ping{} }},
+	TypePong:              {DirectionBoth, func() Message { return &Pong{} }},
+}
+
+// Known: 登録済みのメッセージタイプか
+func Known(t Type) bool {
+	_, ok := registry[t]
+	return ok
+}
+
+// DirectionOf: メッセージタイプの送信方向 (未登録なら空)
+func DirectionOf(t Type) Direction {
+	return registry[t].direction
+}
@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	name := "alice"
+	in := GameEvent{RoomID: "room-1", EventType: "skill1", TriggerCount: 5, ViewerCount: 3, ViewerName: &name, Level: 2, Multiplier: 1, TriggerID: 42}
+	data, err := Encode(in)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	h, msg, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if h.Type != TypeGameEvent || h.Version != Version {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	out, ok := msg.(*GameEvent)
+	if !ok {
+		t.Fatalf("unexpected message type: %T", msg)
+	}
+	if out.RoomID != in.RoomID || out.EventType != in.EventType || out.TriggerID != in.TriggerID || out.ViewerName == nil || *out.ViewerName != name {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestDecode_DeliveryFields(t *testing.T) {
+	h, _, err := Decode([]byte(`{"type":"game_event","room_id":"r","event_type":"skill1","seq":7,"event_id":"1-0","resend":true}`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if h.Seq != 7 || h.EventID != "1-0" || !h.Resend {
+		t.Fatalf("unexpected delivery fields: %+v", h.Delivery)
+	}
+	// version 省略は現行版として扱う
+	if h.Version != Version {
+		t.Fatalf("expected default version %d, got %d", Version, h.Version)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		code string
+	}{
+		{"malformed", `{"type":`, ErrCodeMalformed},
+		{"missing type", `{"room_id":"r"}`, ErrCodeMissingType},
+		{"unknown type", `{"type":"launch_missiles"}`, ErrCodeUnknownType},
+		{"newer version", `{"type":"game_start","version":99}`, ErrCodeUnsupportedVersion},
+		{"wrong field type", `{"type":"ack","seq":"one"}`, ErrCodeInvalidMessage},
+		{"missing required", `{"type":"ack"}`, ErrCodeInvalidMessage},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Decode([]byte(tc.data))
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("expected DecodeError, got %v", err)
+			}
+			if de.Code != tc.code {
+				t.Fatalf("expected code %s, got %s", tc.code, de.Code)
+			}
+			if reply := de.Reply(); reply.Code != tc.code || reply.Message == "" {
+				t.Fatalf("unexpected reply: %+v", reply)
+			}
+		})
+	}
+}
+
+func TestDecodeDirection(t *testing.T) {
+	if _, _, err := DecodeDirection([]byte(`{"type":"game_end"}`), DirectionToBackend); err != nil {
+		t.Fatalf("game_end from unity should be accepted: %v", err)
+	}
+	if _, _, err := DecodeDirection([]byte(`{"type":"ping","ts":1}`), DirectionToBackend); err != nil {
+		t.Fatalf("ping should be accepted in both directions: %v", err)
+	}
+	_, _, err := DecodeDirection([]byte(`{"type":"room_created","room_id":"r"}`), DirectionToBackend)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Code != ErrCodeUnexpectedType {
+		t.Fatalf("expected unexpected_type, got %v", err)
+	}
+}
+
+// 生成済みの docs/protocol.schema.json がメッセージ定義と一致していること (go generate ./pkg/protocol で更新)
+func TestJSONSchema_UpToDate(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema failed: %v", err)
+	}
+	committed, err := os.ReadFile("../../docs/protocol.schema.json")
+	if err != nil {
+		t.Fatalf("read committed schema: %v", err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(committed), bytes.TrimSpace(schema)) {
+		t.Fatal("docs/protocol.schema.json is out of date; run go generate ./pkg/protocol")
+	}
+}
@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// schemaID: 出力する JSON Schema の $id
+const schemaID = "https://streamerio.example/schemas/unity-protocol.json"
+
+// JSONSchema: 登録済みメッセージの JSON Schema (draft-07) を出力
+// Unity 側の型定義・検証用。メッセージ定義を変えたら go generate で docs/protocol.schema.json を更新する。
+func JSONSchema() ([]byte, error) {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+
+	defs := make(map[string]interface{}, len(types))
+	oneOf := make([]interface{}, 0, len(types))
+	for _, name := range types {
+		t := Type(name)
+		e := registry[t]
+		def := objectSchema(reflect.TypeOf(e.new()).Elem())
+		props := def["properties"].(map[string]interface{})
+		props["type"] = map[string]interface{}{"const": name}
+		props["version"] = map[string]interface{}{"type": "integer", "minimum": 1, "maximum": Version}
+		// 配送用フィールドは Backend → Unity のメッセージにのみ付く
+		if e.direction != DirectionToBackend {
+			for k, v := range objectSchema(reflect.TypeOf(Delivery{}))["properties"].(map[string]interface{}) {
+				if _, exists := props[k]; !exists {
+					props[k] = v
+				}
+			}
+		}
+		def["required"] = append([]string{"type"}, def["required"].([]string)...)
+		def["x-direction"] = string(e.direction)
+		defs[name] = def
+		oneOf = append(oneOf, map[string]interface{}{"$ref": "#/definitions/" + name})
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         schemaID,
+		"title":       "Streamerio Unity WebSocket protocol",
+		"x-version":   Version,
+		"oneOf":       oneOf,
+		"definitions": defs,
+	}, "", "  ")
+}
+
+// objectSchema: 構造体の JSON Schema (omitempty とポインタ以外のフィールドを必須とする)
+func objectSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := typeSchema(t.Elem())
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+		return s
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return objectSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
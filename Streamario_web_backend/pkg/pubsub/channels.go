@@ -5,8 +5,9 @@ package pubsub
 // タイポ防止と一元管理のため、チャネル名は必ずこの定数を使用すること
 
 const (
-	// ChannelGameEvents: ゲームイベント通知チャネル
-	// REST API → WebSocket → Unity への閾値到達イベント配信に使用
+	// ChannelGameEvents: 全ルーム共通のゲームイベント通知チャネル
+	// ルーム宛てのイベントは RoomEventsChannel で配信する (購読側がルーム単位で絞り込めるように)
+	// Payload は RoomEventsChannel と同じ
 	//
 	// Payload 例:
 	//   {
@@ -14,7 +15,10 @@ const (
 	//     "room_id": "01HXXX...",
 	//     "event_type": "skill1",
 	//     "trigger_count": 5,
-	//     "viewer_count": 12
+	//     "viewer_count": 12,
+	//     "level": 1,
+	//     "multiplier": 1,
+	//     "trigger_id": 42
 	//   }
 	ChannelGameEvents = "game_events"
 
@@ -28,4 +32,22 @@ const (
 	//     "ended_at": "2025-10-05T12:00:00Z"
 	//   }
 	ChannelGameEnd = "game_end_notifications"
+
+	// ChannelEventFlush: イベント書き込みキューのフラッシュ要求チャネル
+	// ゲーム終了時に WebSocket サーバーが送信し、各 API サーバーがキュー内のイベントを即時に DB へ書き込む
+	//
+	// Payload 例:
+	//   {
+	//     "type": "event_flush",
+	//     "room_id": "01HXXX..."
+	//   }
+	ChannelEventFlush = "event_flush_requests"
 )
+
+// RoomEventsChannel: ルーム単位のゲームイベント通知チャネル (room:{id}:events)
+// REST API → WebSocket → Unity への閾値到達イベント / 視聴者数更新の配信に使用し、
+// そのルームの Unity 接続を持つ WebSocket サーバーと SSE 購読者のいる API サーバーだけが購読する。
+// Payload は ChannelGameEvents の例を参照
+func RoomEventsChannel(roomID string) string {
+	return "room:" + roomID + ":events"
+}
@@ -12,6 +12,10 @@ type PubSub interface {
 	// context がキャンセルされるまでブロックし続ける
 	Subscribe(ctx context.Context, channel string, handler MessageHandler) error
 
+	// Multiplex: 購読チャネルを後から追加/削除できる購読を開始
+	// 受信ループは別 goroutine で動き、ctx のキャンセルまたは Subscription.Close で終了する
+	Multiplex(ctx context.Context, handler MessageHandler) (Subscription, error)
+
 	// Close: リソースをクリーンアップ
 	Close() error
 }
@@ -19,3 +23,43 @@ type PubSub interface {
 // MessageHandler: メッセージ受信時のコールバック関数
 // エラーを返した場合はログに記録されるが、購読は継続する
 type MessageHandler func(channel string, message []byte) error
+
+// Subscription: 1本の接続で複数チャネルを購読する (ルーム単位チャネルの動的な購読に使用)
+type Subscription interface {
+	// Subscribe: チャネルを購読対象に追加 (購読済みのチャネルは無視)
+	Subscribe(ctx context.Context, channels ...string) error
+
+	// Unsubscribe: チャネルを購読対象から外す
+	Unsubscribe(ctx context.Context, channels ...string) error
+
+	// Close: 購読を終了し、受信ループの停止を待つ
+	Close() error
+}
+
+// Message: ID 付きの受信メッセージ (Durable 実装で使用)
+type Message struct {
+	ID      string // ストリーム内の ID (Ack / Replay の位置に使う)
+	Channel string
+	Payload []byte
+}
+
+// MessageWithIDHandler: ID 付きメッセージ受信時のコールバック関数
+type MessageWithIDHandler func(msg Message) error
+
+// Durable: 配信の取りこぼしを補える PubSub (Redis Streams 実装)
+// 購読が途切れていた間のメッセージを Replay で取り直し、consumer ごとの処理済み位置を Ack で記録する。
+type Durable interface {
+	PubSub
+
+	// MultiplexMessages: Multiplex と同じだが、ハンドラにメッセージ ID を渡す
+	MultiplexMessages(ctx context.Context, handler MessageWithIDHandler) (Subscription, error)
+
+	// Replay: afterID より後のメッセージを古い順に最大 limit 件返す (afterID が空なら保持している先頭から)
+	Replay(ctx context.Context, channel, afterID string, limit int64) ([]Message, error)
+
+	// Ack: consumer が channel の id まで処理したことを記録 (累積 ack)
+	Ack(ctx context.Context, channel, consumer, id string) error
+
+	// LastAcked: consumer が最後に ack した ID (未 ack は空文字)
+	LastAcked(ctx context.Context, channel, consumer string) (string, error)
+}
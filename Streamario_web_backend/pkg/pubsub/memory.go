@@ -7,11 +7,23 @@ import (
 	"sync"
 )
 
+// memoryMessage: 購読者へ渡す1件 (Multiplex では受信チャネル名も必要)
+type memoryMessage struct {
+	channel string
+	payload []byte
+}
+
+// memorySubscriber: 1購読分の受信バッファ (Multiplex では複数チャネルに登録される)
+type memorySubscriber struct {
+	ch     chan memoryMessage
+	closed bool // m.mu で保護
+}
+
 // memoryPubSub: テスト/開発用のインメモリ実装
 // 同一プロセス内でのみ動作し、複数サーバー間では機能しない
 type memoryPubSub struct {
 	mu          sync.RWMutex
-	subscribers map[string][]chan []byte // channel -> subscriber channels
+	subscribers map[string]map[*memorySubscriber]struct{} // channel -> subscribers
 	logger      *slog.Logger
 	closed      bool
 }
@@ -22,7 +34,7 @@ func NewMemoryPubSub(logger *slog.Logger) PubSub {
 		logger = slog.Default()
 	}
 	return &memoryPubSub{
-		subscribers: make(map[string][]chan []byte),
+		subscribers: make(map[string]map[*memorySubscriber]struct{}),
 		logger:      logger,
 	}
 }
@@ -53,9 +65,9 @@ func (m *memoryPubSub) Publish(ctx context.Context, channel string, message []by
 	copy(msgCopy, message)
 
 	delivered := 0
-	for _, sub := range subs {
+	for sub := range subs {
 		select {
-		case sub <- msgCopy:
+		case sub.ch <- memoryMessage{channel: channel, payload: msgCopy}:
 			delivered++
 		case <-ctx.Done():
 			return ctx.Err()
@@ -80,7 +92,7 @@ func (m *memoryPubSub) Subscribe(ctx context.Context, channel string, handler Me
 	)
 
 	// 購読用チャネルを作成（バッファサイズ100）
-	msgChan := make(chan []byte, 100)
+	sub := &memorySubscriber{ch: make(chan memoryMessage, 100)}
 
 	// 購読者リストに追加
 	m.mu.Lock()
@@ -88,8 +100,7 @@ func (m *memoryPubSub) Subscribe(ctx context.Context, channel string, handler Me
 		m.mu.Unlock()
 		return fmt.Errorf("pubsub is closed")
 	}
-	m.subscribers[channel] = append(m.subscribers[channel], msgChan)
-	subIndex := len(m.subscribers[channel]) - 1
+	m.addLocked(channel, sub)
 	m.mu.Unlock()
 
 	logger.Info("subscription established")
@@ -98,39 +109,58 @@ func (m *memoryPubSub) Subscribe(ctx context.Context, channel string, handler Me
 	defer func() {
 		m.mu.Lock()
 		defer m.mu.Unlock()
+		m.removeLocked(channel, sub)
+		m.closeSubscriberLocked(sub)
+		logger.Info("subscription closed")
+	}()
 
-		// 購読者リストから削除
-		if subs, exists := m.subscribers[channel]; exists && subIndex < len(subs) {
-			// チャネルをクローズ
-			close(msgChan)
+	return m.receive(ctx, sub, handler, logger)
+}
 
-			// スライスから削除（順序は保持しない高速削除）
-			subs[subIndex] = subs[len(subs)-1]
-			m.subscribers[channel] = subs[:len(subs)-1]
+// Multiplex: 購読チャネルを動的に追加/削除できる購読を開始
+func (m *memoryPubSub) Multiplex(ctx context.Context, handler MessageHandler) (Subscription, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("pubsub is closed")
+	}
+	m.mu.Unlock()
 
-			// 購読者がいなくなったらチャネルエントリ自体を削除
-			if len(m.subscribers[channel]) == 0 {
-				delete(m.subscribers, channel)
-			}
-		}
-		logger.Info("subscription closed")
+	ctx, cancel := context.WithCancel(ctx)
+	s := &memorySubscription{
+		ps:       m,
+		sub:      &memorySubscriber{ch: make(chan memoryMessage, 100)},
+		channels: make(map[string]struct{}),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	logger := m.logger.With(slog.String("op", "multiplex"))
+	go func() {
+		defer close(s.done)
+		_ = m.receive(ctx, s.sub, handler, logger)
+		s.release()
 	}()
+	logger.Info("subscription established")
+	return s, nil
+}
 
-	// メッセージループ
+// receive: ctx のキャンセルまたはチャネルクローズまでメッセージをハンドラへ渡す
+func (m *memoryPubSub) receive(ctx context.Context, sub *memorySubscriber, handler MessageHandler, logger *slog.Logger) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 
-		case msg, ok := <-msgChan:
+		case msg, ok := <-sub.ch:
 			if !ok {
 				return fmt.Errorf("subscription channel closed")
 			}
 
 			// ハンドラを呼び出し
-			if err := handler(channel, msg); err != nil {
+			if err := handler(msg.channel, msg.payload); err != nil {
 				logger.Error("message handler error",
-					slog.Int("payload_size", len(msg)),
+					slog.String("channel", msg.channel),
+					slog.Int("payload_size", len(msg.payload)),
 					slog.Any("error", err),
 				)
 				// エラーが発生しても購読は継続
@@ -139,6 +169,34 @@ func (m *memoryPubSub) Subscribe(ctx context.Context, channel string, handler Me
 	}
 }
 
+func (m *memoryPubSub) addLocked(channel string, sub *memorySubscriber) {
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[*memorySubscriber]struct{})
+	}
+	m.subscribers[channel][sub] = struct{}{}
+}
+
+func (m *memoryPubSub) removeLocked(channel string, sub *memorySubscriber) {
+	subs, exists := m.subscribers[channel]
+	if !exists {
+		return
+	}
+	delete(subs, sub)
+	// 購読者がいなくなったらチャネルエントリ自体を削除
+	if len(subs) == 0 {
+		delete(m.subscribers, channel)
+	}
+}
+
+// closeSubscriberLocked: 受信チャネルを一度だけクローズ
+func (m *memoryPubSub) closeSubscriberLocked(sub *memorySubscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+}
+
 // Close: すべての購読を終了し、リソースをクリーンアップ
 func (m *memoryPubSub) Close() error {
 	m.mu.Lock()
@@ -152,8 +210,8 @@ func (m *memoryPubSub) Close() error {
 
 	// すべての購読チャネルをクローズ
 	for channel, subs := range m.subscribers {
-		for _, sub := range subs {
-			close(sub)
+		for sub := range subs {
+			m.closeSubscriberLocked(sub)
 		}
 		delete(m.subscribers, channel)
 	}
@@ -161,3 +219,52 @@ func (m *memoryPubSub) Close() error {
 	m.logger.Info("pubsub closed")
 	return nil
 }
+
+// memorySubscription: Multiplex の購読 (1つの受信バッファを複数チャネルに登録)
+type memorySubscription struct {
+	ps       *memoryPubSub
+	sub      *memorySubscriber
+	channels map[string]struct{} // ps.mu で保護
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (s *memorySubscription) Subscribe(ctx context.Context, channels ...string) error {
+	s.ps.mu.Lock()
+	defer s.ps.mu.Unlock()
+	if s.ps.closed || s.sub.closed {
+		return fmt.Errorf("subscription closed")
+	}
+	for _, ch := range channels {
+		s.channels[ch] = struct{}{}
+		s.ps.addLocked(ch, s.sub)
+	}
+	return nil
+}
+
+func (s *memorySubscription) Unsubscribe(ctx context.Context, channels ...string) error {
+	s.ps.mu.Lock()
+	defer s.ps.mu.Unlock()
+	for _, ch := range channels {
+		delete(s.channels, ch)
+		s.ps.removeLocked(ch, s.sub)
+	}
+	return nil
+}
+
+func (s *memorySubscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// release: 全チャネルから外して受信バッファを閉じる (受信ループ終了時)
+func (s *memorySubscription) release() {
+	s.ps.mu.Lock()
+	defer s.ps.mu.Unlock()
+	for ch := range s.channels {
+		s.ps.removeLocked(ch, s.sub)
+	}
+	s.channels = make(map[string]struct{})
+	s.ps.closeSubscriberLocked(s.sub)
+}
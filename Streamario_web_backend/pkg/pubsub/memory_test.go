@@ -158,3 +158,61 @@ func TestMemoryPubSub_Close(t *testing.T) {
 		t.Error("expected error after close, got nil")
 	}
 }
+
+func TestMemoryPubSub_MultiplexRoomChannels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(nil, &slog.HandlerOptions{Level: slog.LevelError}))
+	ps := NewMemoryPubSub(logger)
+	defer ps.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var receivedMu sync.Mutex
+	received := map[string][]string{}
+	handler := func(ch string, msg []byte) error {
+		receivedMu.Lock()
+		defer receivedMu.Unlock()
+		received[ch] = append(received[ch], string(msg))
+		return nil
+	}
+
+	sub, err := ps.Multiplex(ctx, handler)
+	if err != nil {
+		t.Fatalf("Multiplex failed: %v", err)
+	}
+	roomA := RoomEventsChannel("room-a")
+	roomB := RoomEventsChannel("room-b")
+	if err := sub.Subscribe(ctx, roomA, roomB); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	_ = ps.Publish(ctx, roomA, []byte("a1"))
+	_ = ps.Publish(ctx, roomB, []byte("b1"))
+	_ = ps.Publish(ctx, RoomEventsChannel("room-c"), []byte("c1")) // 購読していないルーム
+	time.Sleep(100 * time.Millisecond)
+
+	if err := sub.Unsubscribe(ctx, roomB); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	_ = ps.Publish(ctx, roomA, []byte("a2"))
+	_ = ps.Publish(ctx, roomB, []byte("b2"))
+	time.Sleep(100 * time.Millisecond)
+
+	if err := sub.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// 購読終了後の Publish は誰にも届かない
+	_ = ps.Publish(ctx, roomA, []byte("a3"))
+
+	receivedMu.Lock()
+	defer receivedMu.Unlock()
+	if got := received[roomA]; len(got) != 2 || got[0] != "a1" || got[1] != "a2" {
+		t.Errorf("room-a: expected [a1 a2], got %v", got)
+	}
+	if got := received[roomB]; len(got) != 1 || got[0] != "b1" {
+		t.Errorf("room-b: expected [b1], got %v", got)
+	}
+	if got := received[RoomEventsChannel("room-c")]; len(got) != 0 {
+		t.Errorf("room-c: expected no messages, got %v", got)
+	}
+}
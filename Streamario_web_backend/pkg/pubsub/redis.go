@@ -70,9 +70,34 @@ func (r *redisPubSub) Subscribe(ctx context.Context, channel string, handler Mes
 
 	logger.Info("subscription established")
 
-	// メッセージ受信チャネル
-	ch := pubsub.Channel()
+	return r.receive(ctx, pubsub.Channel(), handler, logger)
+}
+
+// Multiplex: チャネルなしで購読接続を作り、Subscription 経由で SUBSCRIBE / UNSUBSCRIBE する
+// 購読チャネル数に関わらず Redis 接続は1本で済む。
+func (r *redisPubSub) Multiplex(ctx context.Context, handler MessageHandler) (Subscription, error) {
+	logger := r.logger.With(slog.String("op", "multiplex"))
+	ctx, cancel := context.WithCancel(ctx)
+	s := &redisSubscription{
+		ps:     r.rdb.Subscribe(ctx),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+	ch := s.ps.Channel()
+	go func() {
+		defer close(s.done)
+		_ = r.receive(ctx, ch, handler, logger)
+		if err := s.ps.Close(); err != nil {
+			logger.Warn("redis.pubsub close failed", slog.Any("error", err))
+		}
+	}()
+	logger.Info("subscription established")
+	return s, nil
+}
 
+// receive: ctx のキャンセルまたはチャネルクローズまでメッセージをハンドラへ渡す
+func (r *redisPubSub) receive(ctx context.Context, ch <-chan *redis.Message, handler MessageHandler, logger *slog.Logger) error {
 	// メッセージループ
 	for {
 		select {
@@ -112,3 +137,41 @@ func (r *redisPubSub) Subscribe(ctx context.Context, channel string, handler Mes
 func (r *redisPubSub) Close() error {
 	return nil
 }
+
+// redisSubscription: Multiplex の購読 (go-redis の PubSub を1本保持)
+type redisSubscription struct {
+	ps     *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *slog.Logger
+}
+
+func (s *redisSubscription) Subscribe(ctx context.Context, channels ...string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	if err := s.ps.Subscribe(ctx, channels...); err != nil {
+		s.logger.Error("redis.subscribe failed", slog.Any("channels", channels), slog.Any("error", err))
+		return fmt.Errorf("redis subscribe failed: %w", err)
+	}
+	s.logger.Debug("redis.subscribe", slog.Any("channels", channels))
+	return nil
+}
+
+func (s *redisSubscription) Unsubscribe(ctx context.Context, channels ...string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	if err := s.ps.Unsubscribe(ctx, channels...); err != nil {
+		s.logger.Error("redis.unsubscribe failed", slog.Any("channels", channels), slog.Any("error", err))
+		return fmt.Errorf("redis unsubscribe failed: %w", err)
+	}
+	s.logger.Debug("redis.unsubscribe", slog.Any("channels", channels))
+	return nil
+}
+
+func (s *redisSubscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
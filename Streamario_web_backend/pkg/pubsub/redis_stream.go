@@ -0,0 +1,401 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamOptions: Redis Streams 実装の保持設定
+type StreamOptions struct {
+	MaxLen int64         // チャネルごとに保持する最大件数 (XADD MAXLEN ~)
+	TTL    time.Duration // 最後の Publish / Ack からストリームを保持する期間
+	Block  time.Duration // XREAD の最大待ち時間 (購読チャネルの変更が反映されるまでの上限)
+}
+
+func (o StreamOptions) withDefaults() StreamOptions {
+	if o.MaxLen <= 0 {
+		o.MaxLen = 1000
+	}
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.Block <= 0 {
+		o.Block = time.Second
+	}
+	return o
+}
+
+// streamKey: チャネルに対応するストリームキー
+func streamKey(channel string) string {
+	return "stream:" + channel
+}
+
+// streamAckKey: チャネルの consumer ごとの ack 済み ID (HASH)
+func streamAckKey(channel string) string {
+	return "stream:" + channel + ":acks"
+}
+
+// ackScript: ack 済み ID を前進させる (古い ID での上書きは無視)
+// KEYS[1]: ack HASH, ARGV: consumer, id, ttl_ms
+// 戻り値: 1=更新 / 0=既に同じか新しい ID を ack 済み
+var ackScript = redis.NewScript(`
+local nm, ns = string.match(ARGV[2], '^(%d+)%-(%d+)$')
+if not nm then
+  return redis.error_reply('invalid stream id')
+end
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if cur then
+  local cm, cs = string.match(cur, '^(%d+)%-(%d+)$')
+  if cm then
+    cm, cs, nm, ns = tonumber(cm), tonumber(cs), tonumber(nm), tonumber(ns)
+    if nm < cm or (nm == cm and ns <= cs) then
+      return 0
+    end
+  end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// redisStreamPubSub: Redis Streams を利用した永続化付き実装
+// チャネルごとのストリーム (stream:{channel}) に XADD し、購読側は XREAD で読む。
+// 購読が途切れている間のメッセージも MaxLen / TTL の範囲で残り、Replay で取り直せる。
+type redisStreamPubSub struct {
+	rdb    *redis.Client
+	opts   StreamOptions
+	logger *slog.Logger
+}
+
+// NewRedisStreamPubSub: Redis Streams 実装を生成
+func NewRedisStreamPubSub(rdb *redis.Client, opts StreamOptions, logger *slog.Logger) Durable {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &redisStreamPubSub{
+		rdb:    rdb,
+		opts:   opts.withDefaults(),
+		logger: logger,
+	}
+}
+
+// Publish: XADD (MAXLEN ~ で古いものを間引く) し、ストリームの TTL を延長
+func (r *redisStreamPubSub) Publish(ctx context.Context, channel string, message []byte) error {
+	logger := r.logger.With(
+		slog.String("op", "publish"),
+		slog.String("channel", channel),
+		slog.Int("message_size", len(message)),
+	)
+
+	start := time.Now()
+	key := streamKey(channel)
+	pipe := r.rdb.TxPipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: r.opts.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"payload": message},
+	})
+	pipe.Expire(ctx, key, r.opts.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("redis.xadd failed", slog.Any("error", err))
+		return fmt.Errorf("redis xadd failed: %w", err)
+	}
+
+	logger.Debug("redis.xadd",
+		slog.String("id", add.Val()),
+		slog.Duration("elapsed", time.Since(start)),
+	)
+	return nil
+}
+
+// Subscribe: チャネルを購読し、購読開始以降のメッセージを受信 (ctx キャンセルまでブロック)
+func (r *redisStreamPubSub) Subscribe(ctx context.Context, channel string, handler MessageHandler) error {
+	sub, err := r.Multiplex(ctx, handler)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	if err := sub.Subscribe(ctx, channel); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Multiplex: MultiplexMessages のメッセージ ID を使わない版
+func (r *redisStreamPubSub) Multiplex(ctx context.Context, handler MessageHandler) (Subscription, error) {
+	return r.MultiplexMessages(ctx, func(msg Message) error {
+		return handler(msg.Channel, msg.Payload)
+	})
+}
+
+// MultiplexMessages: 購読チャネルを動的に変更できる購読を開始 (XREAD BLOCK のループを別 goroutine で実行)
+func (r *redisStreamPubSub) MultiplexMessages(ctx context.Context, handler MessageWithIDHandler) (Subscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &redisStreamSubscription{
+		ps:      r,
+		offsets: make(map[string]string),
+		changed: make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		logger:  r.logger.With(slog.String("op", "multiplex")),
+	}
+	go func() {
+		defer close(s.done)
+		s.run(ctx, handler)
+	}()
+	s.logger.Info("subscription established")
+	return s, nil
+}
+
+// Replay: afterID より後のメッセージを古い順に最大 limit 件返す (afterID が空なら保持している先頭から)
+func (r *redisStreamPubSub) Replay(ctx context.Context, channel, afterID string, limit int64) ([]Message, error) {
+	logger := r.logger.With(
+		slog.String("op", "replay"),
+		slog.String("channel", channel),
+		slog.String("after_id", afterID),
+	)
+	start := "-"
+	if afterID != "" {
+		start = "(" + afterID // 排他的な開始位置
+	}
+	if limit <= 0 {
+		limit = r.opts.MaxLen
+	}
+	t0 := time.Now()
+	entries, err := r.rdb.XRangeN(ctx, streamKey(channel), start, "+", limit).Result()
+	if err != nil {
+		logger.Error("redis.xrange failed", slog.Any("error", err))
+		return nil, fmt.Errorf("redis xrange failed: %w", err)
+	}
+	msgs := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		if msg, ok := streamMessage(channel, e); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	logger.Debug("redis.xrange", slog.Int("count", len(msgs)), slog.Duration("elapsed", time.Since(t0)))
+	return msgs, nil
+}
+
+// Ack: consumer が channel の id まで処理したことを記録 (累積 ack。古い ID では巻き戻らない)
+func (r *redisStreamPubSub) Ack(ctx context.Context, channel, consumer, id string) error {
+	logger := r.logger.With(
+		slog.String("op", "ack"),
+		slog.String("channel", channel),
+		slog.String("consumer", consumer),
+		slog.String("id", id),
+	)
+	if _, err := ackScript.Run(ctx, r.rdb, []string{streamAckKey(channel)}, consumer, id, r.opts.TTL.Milliseconds()).Result(); err != nil {
+		logger.Error("redis.eval failed", slog.Any("error", err))
+		return fmt.Errorf("redis ack failed: %w", err)
+	}
+	return nil
+}
+
+// LastAcked: consumer が最後に ack した ID (未 ack は空文字)
+func (r *redisStreamPubSub) LastAcked(ctx context.Context, channel, consumer string) (string, error) {
+	id, err := r.rdb.HGet(ctx, streamAckKey(channel), consumer).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		r.logger.Error("redis.hget failed", slog.String("op", "last_acked"), slog.String("channel", channel), slog.Any("error", err))
+		return "", fmt.Errorf("redis hget failed: %w", err)
+	}
+	return id, nil
+}
+
+// Close: リソースをクリーンアップ (Redis Client は外部管理のため何もしない)
+func (r *redisStreamPubSub) Close() error {
+	return nil
+}
+
+// streamMessage: XREAD / XRANGE の1件を Message に変換 (payload がないものは無視)
+func streamMessage(channel string, e redis.XMessage) (Message, bool) {
+	var payload []byte
+	switch v := e.Values["payload"].(type) {
+	case string:
+		payload = []byte(v)
+	case []byte:
+		payload = v
+	default:
+		return Message{}, false
+	}
+	return Message{ID: e.ID, Channel: channel, Payload: payload}, true
+}
+
+// redisStreamSubscription: 購読中チャネルごとの読み取り位置を保持して XREAD を繰り返す
+type redisStreamSubscription struct {
+	ps      *redisStreamPubSub
+	mu      sync.Mutex
+	offsets map[string]string // channel -> 最後に読んだ ID
+	changed chan struct{}     // 購読チャネルの変更通知 (購読なしで待機中のループを起こす)
+	cancel  context.CancelFunc
+	done    chan struct{}
+	logger  *slog.Logger
+}
+
+// Subscribe: 現在の末尾を読み取り位置にして購読を追加 (購読済みのチャネルは位置を維持)
+func (s *redisStreamSubscription) Subscribe(ctx context.Context, channels ...string) error {
+	for _, ch := range channels {
+		s.mu.Lock()
+		_, exists := s.offsets[ch]
+		s.mu.Unlock()
+		if exists {
+			continue
+		}
+		last, err := s.ps.rdb.XRevRangeN(ctx, streamKey(ch), "+", "-", 1).Result()
+		if err != nil {
+			s.logger.Error("redis.xrevrange failed", slog.String("channel", ch), slog.Any("error", err))
+			return fmt.Errorf("redis xrevrange failed: %w", err)
+		}
+		offset := "0-0"
+		if len(last) > 0 {
+			offset = last[0].ID
+		}
+		s.mu.Lock()
+		if _, exists := s.offsets[ch]; !exists {
+			s.offsets[ch] = offset
+		}
+		s.mu.Unlock()
+	}
+	s.notify()
+	return nil
+}
+
+func (s *redisStreamSubscription) Unsubscribe(ctx context.Context, channels ...string) error {
+	s.mu.Lock()
+	for _, ch := range channels {
+		delete(s.offsets, ch)
+	}
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+func (s *redisStreamSubscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *redisStreamSubscription) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run: 購読中のストリームを XREAD BLOCK で読み続ける
+// 購読チャネルの変更は次の XREAD から反映される (最大で Block 分遅れる)。
+func (s *redisStreamSubscription) run(ctx context.Context, handler MessageWithIDHandler) {
+	backoff := 100 * time.Millisecond
+	for {
+		s.mu.Lock()
+		channels := make([]string, 0, len(s.offsets))
+		for ch := range s.offsets {
+			channels = append(channels, ch)
+		}
+		args := make([]string, 0, len(channels)*2)
+		for _, ch := range channels {
+			args = append(args, streamKey(ch))
+		}
+		for _, ch := range channels {
+			args = append(args, s.offsets[ch])
+		}
+		s.mu.Unlock()
+
+		if len(channels) == 0 {
+			select {
+			case <-ctx.Done():
+				s.logger.Info("subscription cancelled", slog.Any("reason", ctx.Err()))
+				return
+			case <-s.changed:
+				continue
+			}
+		}
+
+		res, err := s.ps.rdb.XRead(ctx, &redis.XReadArgs{Streams: args, Count: 100, Block: s.ps.opts.Block}).Result()
+		if ctx.Err() != nil {
+			s.logger.Info("subscription cancelled", slog.Any("reason", ctx.Err()))
+			return
+		}
+		if errors.Is(err, redis.Nil) {
+			continue // タイムアウト (新着なし)
+		}
+		if err != nil {
+			s.logger.Error("redis.xread failed", slog.Any("error", err), slog.Duration("retry_in", backoff))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 100 * time.Millisecond
+
+		keyToChannel := make(map[string]string, len(channels))
+		for _, ch := range channels {
+			keyToChannel[streamKey(ch)] = ch
+		}
+		for _, stream := range res {
+			ch := keyToChannel[stream.Stream]
+			for _, e := range stream.Messages {
+				s.mu.Lock()
+				_, subscribed := s.offsets[ch]
+				if subscribed {
+					s.offsets[ch] = e.ID
+				}
+				s.mu.Unlock()
+				if !subscribed {
+					break // 読み取り中に購読解除された
+				}
+				msg, ok := streamMessage(ch, e)
+				if !ok {
+					continue
+				}
+				start := time.Now()
+				if err := handler(msg); err != nil {
+					s.logger.Error("message handler error",
+						slog.String("channel", ch),
+						slog.String("id", msg.ID),
+						slog.Int("payload_size", len(msg.Payload)),
+						slog.Any("error", err),
+						slog.Duration("elapsed", time.Since(start)),
+					)
+					// エラーが発生しても購読は継続する
+				}
+			}
+		}
+	}
+}
+
+// バックエンド名 (PUBSUB_BACKEND)
+const (
+	BackendStream = "stream" // Redis Streams (Durable)
+	BackendPubSub = "pubsub" // Redis PUBLISH / SUBSCRIBE
+)
+
+// NewRedisBackend: バックエンド名に応じた Redis 実装を生成
+// API サーバーと WebSocket サーバーは同じバックエンドを使うこと (混在するとメッセージが届かない)。
+func NewRedisBackend(backend string, rdb *redis.Client, opts StreamOptions, logger *slog.Logger) (PubSub, error) {
+	switch backend {
+	case BackendStream, "":
+		return NewRedisStreamPubSub(rdb, opts, logger), nil
+	case BackendPubSub:
+		return NewRedisPubSub(rdb, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown pubsub backend: %s", backend)
+	}
+}
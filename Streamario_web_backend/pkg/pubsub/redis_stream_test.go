@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisClient: REDIS_URL (未指定時は localhost:6379) に接続し、到達できなければテストをスキップ
+func newTestRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = "redis://localhost:6379/0"
+	}
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		opt = &redis.Options{Addr: url}
+	}
+	rdb := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
+		t.Skipf("redis not available (%s): %v", url, err)
+	}
+	return rdb
+}
+
+func TestRedisStreamPubSub_ReplayAndAck(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	defer rdb.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	ps := NewRedisStreamPubSub(rdb, StreamOptions{MaxLen: 100, TTL: time.Minute, Block: 100 * time.Millisecond}, logger)
+
+	ctx := context.Background()
+	channel := RoomEventsChannel(fmt.Sprintf("test-%d", time.Now().UnixNano()))
+	defer rdb.Del(ctx, streamKey(channel), streamAckKey(channel))
+
+	for i := 1; i <= 3; i++ {
+		if err := ps.Publish(ctx, channel, []byte(fmt.Sprintf("m%d", i))); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	// 未 ack なら保持している全件を再送
+	last, err := ps.LastAcked(ctx, channel, "unity")
+	if err != nil || last != "" {
+		t.Fatalf("expected no ack, got %q err=%v", last, err)
+	}
+	msgs, err := ps.Replay(ctx, channel, last, 0)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(msgs) != 3 || string(msgs[0].Payload) != "m1" {
+		t.Fatalf("expected 3 messages from m1, got %+v", msgs)
+	}
+
+	// 2件目まで ack すると3件目だけが残る。古い ID では巻き戻らない
+	if err := ps.Ack(ctx, channel, "unity", msgs[1].ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if err := ps.Ack(ctx, channel, "unity", msgs[0].ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	last, _ = ps.LastAcked(ctx, channel, "unity")
+	if last != msgs[1].ID {
+		t.Fatalf("expected last acked %s, got %s", msgs[1].ID, last)
+	}
+	rest, err := ps.Replay(ctx, channel, last, 0)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(rest) != 1 || string(rest[0].Payload) != "m3" {
+		t.Fatalf("expected only m3 after ack, got %+v", rest)
+	}
+}
+
+func TestRedisStreamPubSub_MultiplexDeliversNewMessages(t *testing.T) {
+	rdb := newTestRedisClient(t)
+	defer rdb.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	ps := NewRedisStreamPubSub(rdb, StreamOptions{MaxLen: 100, TTL: time.Minute, Block: 100 * time.Millisecond}, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	channel := RoomEventsChannel(fmt.Sprintf("test-%d", time.Now().UnixNano()))
+	defer rdb.Del(context.Background(), streamKey(channel))
+
+	// 購読前のメッセージはライブ購読には流れない (Replay の対象)
+	_ = ps.Publish(ctx, channel, []byte("before"))
+
+	var mu sync.Mutex
+	var got []Message
+	sub, err := ps.MultiplexMessages(ctx, func(msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MultiplexMessages failed: %v", err)
+	}
+	defer sub.Close()
+	if err := sub.Subscribe(ctx, channel); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	_ = ps.Publish(ctx, channel, []byte("after"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || string(got[0].Payload) != "after" || got[0].ID == "" || got[0].Channel != channel {
+		t.Fatalf("expected only the message published after subscribe, got %+v", got)
+	}
+}